@@ -131,10 +131,12 @@ func init() {
 	proto.RegisterType((*ProductID)(nil), "ecommerce.ProductID")
 }
 
-func init() { proto.RegisterFile("product_info.proto", fileDescriptor_9a4d768ec9cb4951) }
+func init() {
+	proto.RegisterFile("product_info.proto", fileDescriptor_9a4d768ec9cb4951)
+}
 
 var fileDescriptor_9a4d768ec9cb4951 = []byte{
-	// 199 bytes of a gzipped FileDescriptorProto
+	// 196 bytes of a gzipped FileDescriptorProto
 	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0x2a, 0x28, 0xca, 0x4f,
 	0x29, 0x4d, 0x2e, 0x89, 0xcf, 0xcc, 0x4b, 0xcb, 0xd7, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2,
 	0x4c, 0x4d, 0xce, 0xcf, 0xcd, 0x4d, 0x2d, 0x4a, 0x4e, 0x55, 0x4a, 0xe5, 0x62, 0x0f, 0x80, 0x28,
@@ -146,17 +148,17 @@ var fileDescriptor_9a4d768ec9cb4951 = []byte{
 	0x62, 0x4e, 0x69, 0x2a, 0xd4, 0x2e, 0x08, 0xc7, 0xa8, 0x96, 0x8b, 0x1b, 0xa6, 0x24, 0x2f, 0x2d,
 	0x5f, 0xc8, 0x8c, 0x8b, 0x2b, 0x31, 0x25, 0x05, 0xe6, 0x36, 0x21, 0x3d, 0xb8, 0x93, 0xf5, 0xa0,
 	0x62, 0x52, 0x22, 0x98, 0x62, 0x9e, 0x2e, 0x20, 0x7d, 0xe9, 0xa9, 0x25, 0x30, 0x7d, 0x58, 0xd5,
-	0x48, 0x61, 0x31, 0x2d, 0x89, 0x0d, 0x1c, 0x34, 0xc6, 0x80, 0x00, 0x00, 0x00, 0xff, 0xff, 0x9b,
-	0x1f, 0xc5, 0x58, 0x30, 0x01, 0x00, 0x00,
+	0x48, 0x61, 0x31, 0x2d, 0x89, 0x0d, 0x1c, 0x34, 0xc6, 0x80, 0x01, 0x00, 0x9b, 0x1f, 0xc5, 0x58,
+	0x30, 0x01, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ context.Context
-var _ grpc.ClientConn
+var _ grpc.ClientConnInterface
 
 // This is a compile-time assertion to ensure that this generated file
 // is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+const _ = grpc.SupportPackageIsVersion6
 
 // ProductInfoClient is the client API for ProductInfo service.
 //
@@ -167,10 +169,10 @@ type ProductInfoClient interface {
 }
 
 type productInfoClient struct {
-	cc *grpc.ClientConn
+	cc grpc.ClientConnInterface
 }
 
-func NewProductInfoClient(cc *grpc.ClientConn) ProductInfoClient {
+func NewProductInfoClient(cc grpc.ClientConnInterface) ProductInfoClient {
 	return &productInfoClient{cc}
 }
 
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log"
+	"time"
+)
+
+// 截止时间预算，与 ch05 订单服务的 budget.go 相同：剩余时间不足 minBudget 时不执行处理函数，直接返回 DeadlineExceeded；
+// 本跳的预算和实际耗时写入响应 trailer 的 budgetTrailerKey 中，由上游转发给它的调用方
+const budgetTrailerKey = "x-deadline-budget"
+
+var minBudget = flag.Duration("min-budget", 20*time.Millisecond, "calls fail fast with DeadlineExceeded when less time than this remains")
+
+// remaining 返回 ctx 的剩余时间，没有截止时间时 ok 为 false
+func remaining(ctx context.Context) (d time.Duration, ok bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+func formatBudget(d time.Duration, ok bool) string {
+	if !ok {
+		return "none"
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// 一元拦截器：预算不足时不执行处理函数，结束时将本跳的记录写入 trailer，
+// 例如 "productinfo:50051 /ecommerce.ProductInfo/getProduct budget=948ms used=35µs code=OK"
+func budgetUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	start := time.Now()
+	method, _ := grpc.Method(ctx) // 与客户端调用的路径相同，生成代码中的 FullMethod 大小写不同
+	d, ok := remaining(ctx)
+	defer func() {
+		record := fmt.Sprintf("productinfo%s %s budget=%s used=%v code=%s", port, method, formatBudget(d, ok), time.Since(start).Round(time.Microsecond), status.Code(err))
+		if err := grpc.SetTrailer(ctx, metadata.Pairs(budgetTrailerKey, record)); err != nil {
+			log.Printf("failed to set trailer: %v", err)
+		}
+	}()
+	if ok && d < *minBudget {
+		return nil, status.Errorf(codes.DeadlineExceeded, "%s: remaining budget %v below %v", method, d.Round(time.Millisecond), *minBudget)
+	}
+	return handler(ctx, req)
+}
@@ -28,6 +28,7 @@ type Product struct {
 	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
 	Description          string   `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	Price                float32  `protobuf:"fixed32,4,opt,name=price,proto3" json:"price,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -79,6 +80,13 @@ func (m *Product) GetDescription() string {
 	return ""
 }
 
+func (m *Product) GetPrice() float32 {
+	if m != nil {
+		return m.Price
+	}
+	return 0
+}
+
 type ProductID struct {
 	Value                string   `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -123,31 +131,34 @@ func init() {
 	proto.RegisterType((*ProductID)(nil), "ecommerce.ProductID")
 }
 
-func init() { proto.RegisterFile("product_info.proto", fileDescriptor_9a4d768ec9cb4951) }
+func init() {
+	proto.RegisterFile("product_info.proto", fileDescriptor_9a4d768ec9cb4951)
+}
 
 var fileDescriptor_9a4d768ec9cb4951 = []byte{
-	// 183 bytes of a gzipped FileDescriptorProto
+	// 196 bytes of a gzipped FileDescriptorProto
 	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0x2a, 0x28, 0xca, 0x4f,
 	0x29, 0x4d, 0x2e, 0x89, 0xcf, 0xcc, 0x4b, 0xcb, 0xd7, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2,
-	0x4c, 0x4d, 0xce, 0xcf, 0xcd, 0x4d, 0x2d, 0x4a, 0x4e, 0x55, 0xf2, 0xe7, 0x62, 0x0f, 0x80, 0x28,
+	0x4c, 0x4d, 0xce, 0xcf, 0xcd, 0x4d, 0x2d, 0x4a, 0x4e, 0x55, 0x4a, 0xe5, 0x62, 0x0f, 0x80, 0x28,
 	0x10, 0xe2, 0xe3, 0x62, 0xca, 0x4c, 0x91, 0x60, 0x54, 0x60, 0xd4, 0xe0, 0x0c, 0x62, 0xca, 0x4c,
 	0x11, 0x12, 0xe2, 0x62, 0xc9, 0x4b, 0xcc, 0x4d, 0x95, 0x60, 0x02, 0x8b, 0x80, 0xd9, 0x42, 0x0a,
 	0x5c, 0xdc, 0x29, 0xa9, 0xc5, 0xc9, 0x45, 0x99, 0x05, 0x25, 0x99, 0xf9, 0x79, 0x12, 0xcc, 0x60,
-	0x29, 0x64, 0x21, 0x25, 0x45, 0x2e, 0x4e, 0xa8, 0x81, 0x9e, 0x2e, 0x42, 0x22, 0x5c, 0xac, 0x65,
-	0x89, 0x39, 0xa5, 0xa9, 0x50, 0x53, 0x21, 0x1c, 0xa3, 0x5a, 0x2e, 0x6e, 0x98, 0x92, 0xbc, 0xb4,
-	0x7c, 0x21, 0x33, 0x2e, 0xae, 0xc4, 0x94, 0x14, 0x98, 0x2b, 0x84, 0xf4, 0xe0, 0x8e, 0xd3, 0x83,
-	0x8a, 0x49, 0x89, 0x60, 0x8a, 0x79, 0xba, 0x80, 0xf4, 0xa5, 0xa7, 0x96, 0xc0, 0xf4, 0x61, 0x55,
-	0x23, 0x85, 0xc5, 0xb4, 0x24, 0x36, 0x70, 0x20, 0x18, 0x03, 0x02, 0x00, 0x00, 0xff, 0xff, 0x3b,
-	0xb6, 0x06, 0x95, 0x1a, 0x01, 0x00, 0x00,
+	0x29, 0x64, 0x21, 0x21, 0x11, 0x2e, 0xd6, 0x82, 0xa2, 0xcc, 0xe4, 0x54, 0x09, 0x16, 0x05, 0x46,
+	0x0d, 0xa6, 0x20, 0x08, 0x47, 0x49, 0x91, 0x8b, 0x13, 0x6a, 0x8d, 0xa7, 0x0b, 0x48, 0x49, 0x59,
+	0x62, 0x4e, 0x69, 0x2a, 0xd4, 0x2e, 0x08, 0xc7, 0xa8, 0x96, 0x8b, 0x1b, 0xa6, 0x24, 0x2f, 0x2d,
+	0x5f, 0xc8, 0x8c, 0x8b, 0x2b, 0x31, 0x25, 0x05, 0xe6, 0x36, 0x21, 0x3d, 0xb8, 0x93, 0xf5, 0xa0,
+	0x62, 0x52, 0x22, 0x98, 0x62, 0x9e, 0x2e, 0x20, 0x7d, 0xe9, 0xa9, 0x25, 0x30, 0x7d, 0x58, 0xd5,
+	0x48, 0x61, 0x31, 0x2d, 0x89, 0x0d, 0x1c, 0x34, 0xc6, 0x80, 0x01, 0x00, 0x9b, 0x1f, 0xc5, 0x58,
+	0x30, 0x01, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ context.Context
-var _ grpc.ClientConn
+var _ grpc.ClientConnInterface
 
 // This is a compile-time assertion to ensure that this generated file
 // is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+const _ = grpc.SupportPackageIsVersion6
 
 // ProductInfoClient is the client API for ProductInfo service.
 //
@@ -158,10 +169,10 @@ type ProductInfoClient interface {
 }
 
 type productInfoClient struct {
-	cc *grpc.ClientConn
+	cc grpc.ClientConnInterface
 }
 
-func NewProductInfoClient(cc *grpc.ClientConn) ProductInfoClient {
+func NewProductInfoClient(cc grpc.ClientConnInterface) ProductInfoClient {
 	return &productInfoClient{cc}
 }
 
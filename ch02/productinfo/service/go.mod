@@ -5,13 +5,16 @@ go 1.17
 require (
 	github.com/gofrs/uuid v4.0.0+incompatible
 	github.com/golang/protobuf v1.5.2
+	google.golang.org/genproto v0.0.0-20211011165927-a5fb3255271e
 	google.golang.org/grpc v1.41.0
+	graceful v0.0.0
 )
 
 require (
 	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4 // indirect
 	golang.org/x/sys v0.0.0-20210510120138-977fb7262007 // indirect
 	golang.org/x/text v0.3.6 // indirect
-	google.golang.org/genproto v0.0.0-20211011165927-a5fb3255271e // indirect
 	google.golang.org/protobuf v1.27.1 // indirect
 )
+
+replace graceful => ../../../ch05/graceful
@@ -1,26 +1,41 @@
 package main
 
 import (
+	"flag"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"graceful"
 	"log"
 	"net"
+	"os"
 	pb "productinfo/service/ecommerce"
+	"time"
 )
 
 const (
 	port = ":50051"
 )
 
+var drainTimeout = flag.Duration("drain-timeout", 10*time.Second, "how long to wait for in-flight RPCs on SIGINT/SIGTERM before forcing the server to stop")
+
 func main() {
+	flag.Parse()
 	lis, err := net.Listen("tcp", port)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	s := grpc.NewServer() // 调用 gRPC API 创建新的 gRPC 服务器实例
+	s := grpc.NewServer( // 调用 gRPC API 创建新的 gRPC 服务器实例
+		grpc.ChainUnaryInterceptor(
+			requestIdUnaryServerInterceptor,  // 记录上游传入的请求 ID
+			budgetUnaryServerInterceptor,     // 截止时间预算，本跳耗时写入 trailer
+			validationUnaryServerInterceptor, // 校验请求消息
+		),
+	)
 	pb.RegisterProductInfoServer(s, &server{})
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(s, healthServer)
 
 	log.Printf("Starting gRPC listener on port " + port)
-	if err := s.Serve(lis); err != nil { // 在指定端口上监听传入的消息
-		log.Fatalf("failed to serve: %v", err)
-	}
+	os.Exit(graceful.Serve(s, lis, healthServer, *drainTimeout)) // 在指定端口上监听传入的消息，收到退出信号后优雅退出
 }
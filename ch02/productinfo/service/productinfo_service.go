@@ -16,7 +16,7 @@ type server struct {
 func (s *server) AddProduct(ctx context.Context, in *pb.Product) (*pb.ProductID, error) {
 	out, err := uuid.NewV4()
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Error while generating Product ID", err)
+		return nil, status.Errorf(codes.Internal, "Error while generating Product ID: %v", err)
 	}
 	in.Id = out.String()
 	if s.productMap == nil {
@@ -31,5 +31,5 @@ func (s *server) GetProduct(ctx context.Context, in *pb.ProductID) (*pb.Product,
 	if exists {
 		return value, status.New(codes.OK, "").Err()
 	}
-	return nil, status.Errorf(codes.NotFound, "Product does not exist", in.Value)
+	return nil, status.Errorf(codes.NotFound, "Product does not exist: %s", in.Value)
 }
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log"
+	"strconv"
+	"time"
+)
+
+// requestIdKey 是上游服务（例如 ch05 的订单服务）传入的请求 ID 元数据 key
+const requestIdKey = "x-request-id"
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// 一元拦截器：确定请求 ID（客户端没有传入时生成新的 ID）并通过响应头返回给客户端，
+// 按请求 ID 记录每个请求的方法和结果，便于与上游服务的日志对应
+func requestIdUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	requestId := newRequestId()
+	if ids := md.Get(requestIdKey); len(ids) > 0 && ids[0] != "" {
+		requestId = ids[0]
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs(requestIdKey, requestId)); err != nil {
+		log.Printf("[%s] failed to set header: %v", requestId, err)
+	}
+	resp, err := handler(ctx, req)
+	log.Printf("[%s] %s : %s", requestId, info.FullMethod, status.Code(err))
+	return resp, err
+}
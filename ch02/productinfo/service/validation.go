@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"math"
+	pb "productinfo/service/ecommerce"
+	"strings"
+)
+
+// validateProduct 校验 Product，一次性返回所有违规项。id 由服务端生成，因此不做要求
+func validateProduct(p *pb.Product) []*epb.BadRequest_FieldViolation {
+	var violations []*epb.BadRequest_FieldViolation
+	if strings.TrimSpace(p.Name) == "" {
+		violations = append(violations, &epb.BadRequest_FieldViolation{Field: "name", Description: "must not be empty"})
+	}
+	if f := float64(p.Price); math.IsNaN(f) || math.IsInf(f, 0) {
+		violations = append(violations, &epb.BadRequest_FieldViolation{Field: "price", Description: fmt.Sprintf("must be a finite number, got %v", f)})
+	} else if f < 0 {
+		violations = append(violations, &epb.BadRequest_FieldViolation{Field: "price", Description: fmt.Sprintf("must not be negative, got %v", f)})
+	}
+	return violations
+}
+
+// 校验 AddProduct 的请求消息，不合法时以 BadRequest 详情返回全部违规项
+func validationUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	product, ok := req.(*pb.Product)
+	if !ok {
+		return handler(ctx, req)
+	}
+	violations := validateProduct(product)
+	if len(violations) == 0 {
+		return handler(ctx, req)
+	}
+	log.Printf("%s : invalid request, %d violation(s)", info.FullMethod, len(violations))
+	errorStatus := status.New(codes.InvalidArgument, "Invalid information received")
+	ds, err := errorStatus.WithDetails(&epb.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return nil, errorStatus.Err()
+	}
+	return nil, ds.Err()
+}
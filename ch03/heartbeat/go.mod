@@ -0,0 +1,11 @@
+module heartbeat
+
+go 1.17
+
+require google.golang.org/grpc v1.41.0
+
+require (
+	github.com/golang/protobuf v1.4.3 // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
+	google.golang.org/protobuf v1.25.0 // indirect
+)
@@ -0,0 +1,75 @@
+// Package heartbeat 实现长期存在的流的应用层心跳，由 ProcessOrders 的客户端和服务端共用。
+//
+// 流空闲超过 interval 时通过 send 发送一条心跳消息；超过 timeout 没有收到对端的任何消息时判定对端失联，
+// 关闭 Dead 返回的信道，之后 Err 返回 Unavailable。收发消息时调用方需要调用 Sent 和 Received。
+package heartbeat
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Heartbeat 在流空闲时定期发送心跳，并在超过 timeout 没有收到对端的任何消息时判定对端失联
+type Heartbeat struct {
+	interval time.Duration // 发送心跳的间隔，0 表示不发送
+	timeout  time.Duration // 0 表示不检测对端
+	send     func() error
+
+	lastSend int64 // UnixNano，原子访问
+	lastRecv int64
+	dead     chan struct{} // 对端失联时关闭
+	err      error
+	quit     chan struct{}
+	stopOnce sync.Once
+}
+
+// New 创建心跳，interval 为 0 时不发送心跳，timeout 为 0 时不检测对端
+func New(interval, timeout time.Duration, send func() error) *Heartbeat {
+	now := time.Now().UnixNano()
+	return &Heartbeat{interval: interval, timeout: timeout, send: send, lastSend: now, lastRecv: now,
+		dead: make(chan struct{}), quit: make(chan struct{})}
+}
+
+// Sent 和 Received 记录发送、收到消息的时间
+func (h *Heartbeat) Sent()     { atomic.StoreInt64(&h.lastSend, time.Now().UnixNano()) }
+func (h *Heartbeat) Received() { atomic.StoreInt64(&h.lastRecv, time.Now().UnixNano()) }
+
+// Dead 在对端失联时关闭，之后 Err 返回流应当结束的状态
+func (h *Heartbeat) Dead() <-chan struct{} { return h.dead }
+func (h *Heartbeat) Err() error            { return h.err }
+
+// Start 启动心跳协程，返回的函数停止协程
+func (h *Heartbeat) Start() func() {
+	period := h.interval
+	if h.timeout > 0 && (period == 0 || h.timeout/3 < period) {
+		period = h.timeout / 3
+	}
+	if period <= 0 {
+		return func() {}
+	}
+	go func() {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.quit:
+				return
+			case <-ticker.C:
+			}
+			if silent := time.Since(time.Unix(0, atomic.LoadInt64(&h.lastRecv))); h.timeout > 0 && silent > h.timeout {
+				h.err = status.Errorf(codes.Unavailable, "peer missed heartbeats: no message for %v", silent.Round(time.Millisecond))
+				close(h.dead)
+				return
+			}
+			if h.interval > 0 && time.Since(time.Unix(0, atomic.LoadInt64(&h.lastSend))) >= h.interval {
+				if err := h.send(); err != nil {
+					return // 流已经出错，由 Recv 报告
+				}
+			}
+		}
+	}()
+	return func() { h.stopOnce.Do(func() { close(h.quit) }) }
+}
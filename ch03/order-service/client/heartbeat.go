@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"heartbeat"
+	"log"
+	pb "ordermgt/client/ecommerce"
+	"sync"
+	"time"
+)
+
+// ProcessOrders 的应用层心跳，见服务端 heartbeat.go：客户端在请求元数据 x-heartbeat-interval 中声明自己的心跳间隔，
+// 服务端在响应头中声明它的间隔；连续 heartbeat-misses 个服务端间隔没有收到任何消息时取消流并返回 Unavailable，
+// orderProcessor 随后会续传
+const heartbeatIntervalKey = "x-heartbeat-interval"
+
+var (
+	heartbeatInterval = flag.Duration("heartbeat-interval", 0, "interval of client heartbeats on idle ProcessOrders streams, 0 disables")
+	heartbeatMisses   = flag.Int("heartbeat-misses", 3, "cancel a ProcessOrders stream after this many server heartbeat intervals without any message")
+
+	// 传输层 keepalive。keepalive-time 不能小于服务端的 keepalive-min-time，否则服务端会以 too_many_pings 关闭连接
+	keepaliveTime                = flag.Duration("keepalive-time", 0, "ping the server after the connection has been idle for this long, 0 disables")
+	keepaliveTimeout             = flag.Duration("keepalive-timeout", 20*time.Second, "close the connection if a keepalive ping is not acknowledged within this time")
+	keepalivePermitWithoutStream = flag.Bool("keepalive-permit-without-stream", false, "send keepalive pings even when there are no active RPCs")
+)
+
+// keepaliveDialOptions 返回传输层 keepalive 参数，未设置 keepalive-time 时不发送 ping
+func keepaliveDialOptions() []grpc.DialOption {
+	if *keepaliveTime <= 0 {
+		return nil
+	}
+	return []grpc.DialOption{grpc.WithKeepaliveParams(keepalive.ClientParameters{
+		Time:                *keepaliveTime,
+		Timeout:             *keepaliveTimeout,
+		PermitWithoutStream: *keepalivePermitWithoutStream,
+	})}
+}
+
+// heartbeatClientStream 包装 ProcessOrders 流：Send 和 CloseSend 互斥（心跳协程和发送协程都会发送），
+// CloseSend 之后不再发送心跳；Recv 记录收到消息的时间并丢弃服务端的心跳
+type heartbeatClientStream struct {
+	pb.OrderManagement_ProcessOrdersClient
+	*heartbeat.Heartbeat
+	mu     sync.Mutex
+	closed bool
+}
+
+// newHeartbeatClientStream 根据服务端在响应头中声明的心跳间隔检测服务端是否失联，心跳携带 ack 返回的确认序号
+func newHeartbeatClientStream(stream pb.OrderManagement_ProcessOrdersClient, header metadata.MD, ack func() uint64) (*heartbeatClientStream, error) {
+	var timeout time.Duration
+	if v := header.Get(heartbeatIntervalKey); len(v) > 0 {
+		interval, err := time.ParseDuration(v[0])
+		if err != nil || interval <= 0 {
+			return nil, status.Errorf(codes.Internal, "invalid %s: %q", heartbeatIntervalKey, v[0])
+		}
+		timeout = interval * time.Duration(*heartbeatMisses)
+	}
+	hs := &heartbeatClientStream{OrderManagement_ProcessOrdersClient: stream}
+	hs.Heartbeat = heartbeat.New(*heartbeatInterval, timeout, func() error {
+		hs.mu.Lock()
+		defer hs.mu.Unlock()
+		if hs.closed {
+			return nil
+		}
+		hs.Sent()
+		return hs.OrderManagement_ProcessOrdersClient.Send(&pb.ProcessOrderRequest{Heartbeat: true, Ack: ack()})
+	})
+	return hs, nil
+}
+
+func (hs *heartbeatClientStream) Send(m *pb.ProcessOrderRequest) error {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.Sent()
+	return hs.OrderManagement_ProcessOrdersClient.Send(m)
+}
+
+func (hs *heartbeatClientStream) CloseSend() error {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.closed = true
+	return hs.OrderManagement_ProcessOrdersClient.CloseSend()
+}
+
+func (hs *heartbeatClientStream) Recv() (*pb.CombinedShipment, error) {
+	for {
+		shipment, err := hs.OrderManagement_ProcessOrdersClient.Recv()
+		if err != nil {
+			return nil, err
+		}
+		hs.Received()
+		if !shipment.GetHeartbeat() {
+			return shipment, nil
+		}
+	}
+}
+
+// peerLost 在服务端失联时记录日志
+func (hs *heartbeatClientStream) peerLost() error {
+	log.Printf("ProcessOrders: %v", hs.Err())
+	return hs.Err()
+}
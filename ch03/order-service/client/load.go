@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	pb "ordermgt/client/ecommerce"
+	"os"
+	"time"
+)
+
+var processLoad = flag.Int("load", 0, "send this many order ids through one ProcessOrders session, check that each appears in exactly one shipment and exit")
+
+// runProcessLoad 通过 ProcessOrders 发送 n 个订单 ID，检查每个序号恰好出现在一个批次中，
+// 失败时以退出码 1 结束。配合服务端的 -process-queue、-process-workers、-process-delay 观察背压
+func runProcessLoad(client pb.OrderManagementClient, n int) {
+	sampleIds := []string{"102", "103", "104", "105", "106"}
+	orderIds := make([]string, n)
+	for i := range orderIds {
+		orderIds[i] = sampleIds[i%len(sampleIds)]
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	counts := make(map[uint64]int, n)
+	shipments := 0
+	start := time.Now()
+	err := newOrderProcessor(client).Process(ctx, orderIds, func(shipment *pb.CombinedShipment) {
+		shipments++
+		for _, seq := range shipment.GetSeqs() {
+			counts[seq]++
+		}
+	})
+	if err != nil {
+		log.Printf("FAIL: ProcessOrders: %v", err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for seq := uint64(1); seq <= uint64(n); seq++ {
+		if counts[seq] != 1 {
+			log.Printf("FAIL: seq %d appeared in %d shipment(s)", seq, counts[seq])
+			failed = true
+		}
+	}
+	if len(counts) != n {
+		log.Printf("FAIL: got %d distinct seqs, want %d", len(counts), n)
+		failed = true
+	}
+	if failed {
+		os.Exit(1)
+	}
+	elapsed := time.Since(start)
+	log.Printf("PASS: %d orders in %d shipments, %v (%.0f orders/s)", n, shipments, elapsed.Round(time.Millisecond), float64(n)/elapsed.Seconds())
+}
@@ -2,9 +2,9 @@ package main
 
 import (
 	"context"
+	"flag"
 	"github.com/golang/protobuf/ptypes/wrappers"
 	"google.golang.org/grpc"
-	"io"
 	"log"
 	pb "ordermgt/client/ecommerce"
 	"time"
@@ -14,27 +14,45 @@ const (
 	address = "localhost:50051"
 )
 
+var idle = flag.Duration("idle", 0, "keep the ProcessOrders stream open and idle for this long after sending the orders")
+
 func main() {
-	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	flag.Parse()
+	conn, err := grpc.Dial(address, append(keepaliveDialOptions(), grpc.WithInsecure())...)
 	if err != nil {
 		log.Fatalf("did not connect: %v", err)
 	}
 	defer conn.Close()
 	orderMgtClient := pb.NewOrderManagementClient(conn)
+	if *processLoad > 0 {
+		runProcessLoad(orderMgtClient, *processLoad)
+		return
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 30* time.Second)
 	defer cancel()
 
+	// 订阅订单变更，从第一个事件开始
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	watchDone := watchOrders(watchCtx, orderMgtClient, 1)
+
+	// 添加订单
+	addRes, err := orderMgtClient.AddOrder(ctx, &pb.Order{Id: "107", Items: []string{"Google Pixel 6"}, Destination: "San Jose, CA", Price: 600.00})
+	if err != nil {
+		log.Fatalf("AddOrder failed: %v", err)
+	}
+	log.Print("AddOrder Response -> ", addRes.GetValue())
+
 	// 获取订单
 	retrievedOrder, err := orderMgtClient.GetOrder(ctx, &wrappers.StringValue{Value: "106"})
 	log.Print("GetOrder Response -> : ", retrievedOrder)
 
-	searchStream, _ := orderMgtClient.SearchOrders(ctx, &wrappers.StringValue{Value: "Google"})
-	for {
-		searchOrder, err := searchStream.Recv()
-		if err == io.EOF {
-			break
-		}
-		log.Print("Search Result: ", searchOrder)
+	// 搜索订单，流断开时自动重连并从最后收到的订单之后继续
+	search := newOrderSearch(ctx, orderMgtClient, "Google")
+	for search.Next() {
+		log.Print("Search Result: ", search.Order())
+	}
+	if err := search.Err(); err != nil {
+		log.Printf("SearchOrders failed: %v", err)
 	}
 
 	// updateOrders
@@ -68,44 +86,24 @@ func main() {
 	}
 	log.Printf("Update Orders Res : %s", updateRes)
 
-	// 处理订单
-	streamProcOrder, err := orderMgtClient.ProcessOrders(ctx)
+	// 删除订单
+	deleteRes, err := orderMgtClient.DeleteOrder(ctx, &wrappers.StringValue{Value: "105"})
 	if err != nil {
-		log.Fatalf("%v.ProcessOrders(_) = _, %v", orderMgtClient, err)
-	}
-	if err := streamProcOrder.Send(&wrappers.StringValue{Value: "102"}); err != nil {
-		log.Fatalf("%v.Send(%v) = %v", orderMgtClient, "102", err)
-	}
-
-	if err := streamProcOrder.Send(&wrappers.StringValue{Value: "103"}); err != nil {
-		log.Fatalf("%v.Send(%v) = %v", orderMgtClient, "103", err)
+		log.Fatalf("DeleteOrder failed: %v", err)
 	}
-
-	if err := streamProcOrder.Send(&wrappers.StringValue{Value: "104"}); err != nil {
-		log.Fatalf("%v.Send(%v) = %v", orderMgtClient, "104", err)
-	}
-
-	channel := make(chan struct{})
-	go asyncClientBidirectionalRPC(streamProcOrder, channel)
-	time.Sleep(time.Millisecond * 1000)
-
-	if err := streamProcOrder.Send(&wrappers.StringValue{Value: "101"}); err != nil {
-		log.Fatalf("%v.Send(%v) = %v", orderMgtClient, "101", err)
-	}
-
-	if err := streamProcOrder.CloseSend(); err != nil {
-		log.Fatal(err)
+	log.Print("DeleteOrder Response -> ", deleteRes.GetValue())
+
+	// 处理订单，流断开时自动续传
+	processor := newOrderProcessor(orderMgtClient)
+	processor.idle = *idle
+	err = processor.Process(ctx, []string{"102", "103", "104", "101"}, func(combinedShipment *pb.CombinedShipment) {
+		log.Printf("Combined shipment : %v %v", combinedShipment.GetSeqs(), combinedShipment.OrdersList)
+	})
+	if err != nil {
+		log.Fatalf("ProcessOrders failed: %v", err)
 	}
-	channel <- struct{}{}
-}
 
-func asyncClientBidirectionalRPC(streamProcOrder pb.OrderManagement_ProcessOrdersClient, c chan struct{}) {
-	for {
-		combinedShipment, errProcOrder := streamProcOrder.Recv()
-		if errProcOrder == io.EOF {
-			break
-		}
-		log.Print("Combined shipment : ", combinedShipment.OrdersList)
-	}
-	<-c
+	time.Sleep(500 * time.Millisecond) // 等待最后的事件
+	stopWatch()
+	<-watchDone
 }
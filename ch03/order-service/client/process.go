@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"log"
+	pb "ordermgt/client/ecommerce"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProcessOrders 断点续传使用的元数据，见服务端 session.go
+const (
+	sessionIdKey   = "x-session-id"
+	resumeAfterKey = "x-resume-after"
+	nextSeqKey     = "x-next-seq"
+)
+
+// orderProcessor 通过可续传的 ProcessOrders 流处理一组订单：每个订单带有序号，
+// 流断开后使用同一个会话重连，从服务端告知的序号继续发送，并丢弃重发的、已经收到过的批次
+type orderProcessor struct {
+	client      pb.OrderManagementClient
+	sessionId   string
+	maxAttempts int           // 连续没有进展的重连次数上限
+	backoff     time.Duration // 第一次重连前的等待时间，之后每次加倍
+	idle        time.Duration // 发送完订单后保持流打开的时间
+
+	orderIds []string        // 序号 i+1 对应 orderIds[i]
+	seen     map[uint64]bool // 已出现在批次中的序号
+	acked    uint64          // 连续已出现在批次中的最大序号，发送时作为确认
+}
+
+func newOrderProcessor(client pb.OrderManagementClient) *orderProcessor {
+	return &orderProcessor{
+		client:      client,
+		sessionId:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		maxAttempts: 5,
+		backoff:     100 * time.Millisecond,
+		seen:        make(map[uint64]bool),
+	}
+}
+
+// Process 发送订单并对每个新的批次调用 onShipment，所有订单都出现在批次中后返回 nil
+func (p *orderProcessor) Process(ctx context.Context, orderIds []string, onShipment func(*pb.CombinedShipment)) error {
+	p.orderIds = append(p.orderIds, orderIds...)
+	backoff := p.backoff
+	for attempt := 1; ; attempt++ {
+		acked := atomic.LoadUint64(&p.acked)
+		err := p.processOnce(ctx, onShipment)
+		if err == nil {
+			return nil
+		}
+		if code := status.Code(err); code != codes.Unavailable && code != codes.Aborted {
+			return err
+		}
+		if atomic.LoadUint64(&p.acked) > acked {
+			attempt, backoff = 1, p.backoff // 有进展时重新计数
+		}
+		if attempt >= p.maxAttempts {
+			return err
+		}
+		log.Printf("ProcessOrders stream broken after seq %d: %v, resuming in %v", atomic.LoadUint64(&p.acked), err, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// processOnce 建立一个流：从服务端的 x-next-seq 开始发送，接收批次直到流结束
+func (p *orderProcessor) processOnce(ctx context.Context, onShipment func(*pb.CombinedShipment)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	md := metadata.Pairs(sessionIdKey, p.sessionId, resumeAfterKey, strconv.FormatUint(atomic.LoadUint64(&p.acked), 10))
+	if *heartbeatInterval > 0 {
+		md.Set(heartbeatIntervalKey, heartbeatInterval.String())
+	}
+	rawStream, err := p.client.ProcessOrders(metadata.NewOutgoingContext(ctx, md))
+	if err != nil {
+		return err
+	}
+	header, err := rawStream.Header()
+	if err != nil {
+		return err
+	}
+	stream, err := newHeartbeatClientStream(rawStream, header, func() uint64 { return atomic.LoadUint64(&p.acked) })
+	if err != nil {
+		return err
+	}
+	defer stream.Start()()
+	go func() {
+		select {
+		case <-stream.Dead():
+			cancel() // 服务端失联，取消流使 Recv 返回
+		case <-ctx.Done():
+		}
+	}()
+	next := uint64(1)
+	if v := header.Get(nextSeqKey); len(v) > 0 {
+		if next, err = strconv.ParseUint(v[0], 10, 64); err != nil {
+			return status.Errorf(codes.Internal, "invalid %s: %v", nextSeqKey, err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	defer func() {
+		cancel() // 流出错时结束发送协程的等待
+		wg.Wait()
+	}()
+	go func() {
+		defer wg.Done()
+		for seq := next; seq <= uint64(len(p.orderIds)); seq++ {
+			req := &pb.ProcessOrderRequest{Seq: seq, OrderId: p.orderIds[seq-1], Ack: atomic.LoadUint64(&p.acked)}
+			if err := stream.Send(req); err != nil {
+				return // 错误由 Recv 返回
+			}
+		}
+		if p.idle > 0 {
+			select { // 保持流空闲一段时间，用于观察心跳
+			case <-time.After(p.idle):
+			case <-ctx.Done():
+				return
+			}
+		}
+		stream.CloseSend()
+	}()
+
+	for {
+		shipment, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			select {
+			case <-stream.Dead():
+				return stream.peerLost()
+			default:
+				return err
+			}
+		}
+		if p.record(shipment) {
+			onShipment(shipment)
+		} else {
+			log.Printf("Skipping replayed shipment %v %v", shipment.GetId(), shipment.GetSeqs())
+		}
+	}
+}
+
+// record 记录批次中的序号，返回 false 表示批次中的订单都已经收到过
+func (p *orderProcessor) record(shipment *pb.CombinedShipment) bool {
+	fresh := false
+	for _, seq := range shipment.GetSeqs() {
+		if !p.seen[seq] {
+			p.seen[seq] = true
+			fresh = true
+		}
+	}
+	acked := atomic.LoadUint64(&p.acked)
+	for p.seen[acked+1] {
+		acked++
+	}
+	atomic.StoreUint64(&p.acked, acked)
+	return fresh
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"log"
+	pb "ordermgt/client/ecommerce"
+	"time"
+)
+
+// SearchOrders 的续传令牌，见服务端 search.go
+const resumeTokenKey = "x-resume-token"
+
+// orderSearch 遍历 SearchOrders 的结果，用法与 bufio.Scanner 相同：
+//
+//	search := newOrderSearch(ctx, client, "Google")
+//	for search.Next() {
+//		order := search.Order()
+//	}
+//	if err := search.Err(); err != nil { ... }
+//
+// 流因暂时性错误断开时按退避时间重连，并通过续传令牌从最后收到的订单之后继续，不会重复或遗漏订单
+type orderSearch struct {
+	ctx         context.Context
+	client      pb.OrderManagementClient
+	query       string
+	maxAttempts int           // 连续失败的重连次数上限
+	backoff     time.Duration // 第一次重连前的等待时间，之后每次加倍，最多 maxBackoff
+	maxBackoff  time.Duration
+
+	stream   pb.OrderManagement_SearchOrdersClient
+	cancel   context.CancelFunc
+	token    string // 最后收到的订单 ID
+	order    *pb.Order
+	attempts int // 连续失败的次数
+	done     bool
+	err      error
+}
+
+func newOrderSearch(ctx context.Context, client pb.OrderManagementClient, query string) *orderSearch {
+	return &orderSearch{
+		ctx:         ctx,
+		client:      client,
+		query:       query,
+		maxAttempts: 5,
+		backoff:     100 * time.Millisecond,
+		maxBackoff:  2 * time.Second,
+	}
+}
+
+// Next 取得下一个订单，没有更多订单或出错时返回 false
+func (s *orderSearch) Next() bool {
+	for !s.done {
+		if s.stream == nil {
+			if err := s.open(); err != nil {
+				s.retry(err)
+				continue
+			}
+		}
+		order, err := s.stream.Recv()
+		if err == io.EOF {
+			s.finish(nil)
+			return false
+		}
+		if err != nil {
+			s.closeStream()
+			s.retry(err)
+			continue
+		}
+		s.order, s.token, s.attempts = order, order.GetId(), 0
+		return true
+	}
+	return false
+}
+
+// Order 返回 Next 取得的订单
+func (s *orderSearch) Order() *pb.Order { return s.order }
+
+// Err 返回使遍历提前结束的错误，正常结束时为 nil
+func (s *orderSearch) Err() error { return s.err }
+
+func (s *orderSearch) open() error {
+	ctx, cancel := context.WithCancel(s.ctx)
+	if s.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, resumeTokenKey, s.token)
+	}
+	stream, err := s.client.SearchOrders(ctx, &wrappers.StringValue{Value: s.query})
+	if err != nil {
+		cancel()
+		return err
+	}
+	s.stream, s.cancel = stream, cancel
+	return nil
+}
+
+func (s *orderSearch) closeStream() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.stream, s.cancel = nil, nil
+}
+
+// retry 在暂时性错误时等待退避时间后返回，由 Next 重新建立流；其他错误或超过重试次数时结束遍历
+func (s *orderSearch) retry(err error) {
+	s.attempts++
+	if !isTransient(err) || s.attempts >= s.maxAttempts {
+		s.finish(err)
+		return
+	}
+	backoff := s.backoff << uint(s.attempts-1)
+	if backoff > s.maxBackoff {
+		backoff = s.maxBackoff
+	}
+	log.Printf("SearchOrders stream broken after %q: %v, retrying in %v", s.token, err, backoff)
+	select {
+	case <-s.ctx.Done():
+		s.finish(status.FromContextError(s.ctx.Err()).Err())
+	case <-time.After(backoff):
+	}
+}
+
+func (s *orderSearch) finish(err error) {
+	s.closeStream()
+	s.done, s.order, s.err = true, nil, err
+}
+
+// isTransient 判断错误是否可以通过重连恢复。ResourceExhausted（如超过配额）重连后通常仍然失败，不重试
+func isTransient(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	pb "ordermgt/client/ecommerce"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// searchServer 的 SearchOrders 与服务端 search.go 相同：按 ID 顺序发送匹配的订单，从 x-resume-token 之后继续，
+// breakEvery 相当于服务端的 -break-search-every，每个流发送这么多订单后以 Unavailable 断开
+type searchServer struct {
+	pb.OrderManagementServer
+	orders     map[string]pb.Order
+	breakEvery int
+	breakCode  codes.Code
+	streams    int32
+}
+
+func (s *searchServer) SearchOrders(query *wrappers.StringValue, stream pb.OrderManagement_SearchOrdersServer) error {
+	atomic.AddInt32(&s.streams, 1)
+	var after string
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	if v := md.Get(resumeTokenKey); len(v) > 0 {
+		after = v[0]
+	}
+	keys := make([]string, 0, len(s.orders))
+	for key := range s.orders {
+		if key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	sent := 0
+	for _, key := range keys {
+		order := s.orders[key]
+		for _, item := range order.Items {
+			if strings.Contains(item, query.Value) {
+				if err := stream.Send(&order); err != nil {
+					return err
+				}
+				sent++
+				if s.breakEvery > 0 && sent%s.breakEvery == 0 {
+					return status.Errorf(s.breakCode, "injected stream break after %d orders", sent)
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func startSearchServer(t *testing.T, srv *searchServer) pb.OrderManagementClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	pb.RegisterOrderManagementServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.Dial() }))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewOrderManagementClient(conn)
+}
+
+// sampleOrders 返回 n 个订单，ID 为偶数的订单包含 Google 的商品
+func sampleOrders(n int) (map[string]pb.Order, []string) {
+	orders := make(map[string]pb.Order)
+	var matching []string
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("%d", 100+i)
+		item := "Amazon Echo"
+		if i%2 == 0 {
+			item = "Google Home Mini"
+			matching = append(matching, id)
+		}
+		orders[id] = pb.Order{Id: id, Items: []string{"Apple Watch S4", item}}
+	}
+	return orders, matching
+}
+
+// 服务端每个流只发送几个订单就断开时，迭代器重连并续传，结果与不断开时相同：没有重复，也没有遗漏
+func TestOrderSearchResumesAfterBreaks(t *testing.T) {
+	orders, want := sampleOrders(20)
+	for _, breakEvery := range []int{0, 1, 3, len(want)} {
+		t.Run(fmt.Sprintf("break-search-every=%d", breakEvery), func(t *testing.T) {
+			srv := &searchServer{orders: orders, breakEvery: breakEvery, breakCode: codes.Unavailable}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			search := newOrderSearch(ctx, startSearchServer(t, srv), "Google")
+			search.backoff = time.Millisecond
+
+			var got []string
+			seen := make(map[string]bool)
+			for search.Next() {
+				id := search.Order().GetId()
+				if seen[id] {
+					t.Errorf("order %s returned twice", id)
+				}
+				seen[id] = true
+				got = append(got, id)
+			}
+			if err := search.Err(); err != nil {
+				t.Fatalf("Err() = %v, want nil", err)
+			}
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("got orders %v, want %v", got, want)
+			}
+			wantStreams := 1
+			if breakEvery > 0 {
+				wantStreams = len(want)/breakEvery + 1 // 最后一个流在令牌之后没有订单，直接结束
+			}
+			if n := atomic.LoadInt32(&srv.streams); int(n) != wantStreams {
+				t.Errorf("opened %d streams, want %d", n, wantStreams)
+			}
+		})
+	}
+}
+
+// ResourceExhausted 不是暂时性错误，迭代器不重连，Err 返回该错误
+func TestOrderSearchStopsOnResourceExhausted(t *testing.T) {
+	orders, _ := sampleOrders(20)
+	srv := &searchServer{orders: orders, breakEvery: 2, breakCode: codes.ResourceExhausted}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	search := newOrderSearch(ctx, startSearchServer(t, srv), "Google")
+	search.backoff = time.Millisecond
+
+	n := 0
+	for search.Next() {
+		n++
+	}
+	if status.Code(search.Err()) != codes.ResourceExhausted {
+		t.Fatalf("Err() = %v, want ResourceExhausted", search.Err())
+	}
+	if n != 2 || atomic.LoadInt32(&srv.streams) != 1 {
+		t.Errorf("got %d orders from %d streams, want 2 orders from 1 stream", n, srv.streams)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	pb "ordermgt/client/ecommerce"
+	"time"
+)
+
+var (
+	watchDestination = flag.String("watch-destination", "", "only watch orders shipped to this destination")
+	watchSlow        = flag.Duration("watch-slow", 0, "simulated processing time per WatchOrders event, makes the server drop the subscription when its buffer fills up")
+)
+
+// watchOrders 订阅订单变更并打印事件，从 startRevision 开始补发。返回的信道在订阅结束后关闭
+func watchOrders(ctx context.Context, client pb.OrderManagementClient, startRevision uint64) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stream, err := client.WatchOrders(ctx, &pb.WatchOrdersRequest{StartRevision: startRevision, Destination: *watchDestination})
+		if err != nil {
+			log.Printf("WatchOrders failed: %v", err)
+			return
+		}
+		for {
+			event, err := stream.Recv()
+			if err != nil {
+				switch status.Code(err) {
+				case codes.Canceled:
+				case codes.ResourceExhausted:
+					// 处理得太慢被服务端断开，可以从最后收到的版本号之后重新订阅
+					log.Printf("WatchOrders dropped by server: %v", err)
+				default:
+					log.Printf("WatchOrders failed: %v", err)
+				}
+				return
+			}
+			log.Printf("Order event #%d %v : %s %s %s", event.GetRevision(), event.GetType(), event.GetOrder().GetId(), event.GetStatus(), event.GetShipmentId())
+			time.Sleep(*watchSlow)
+		}
+	}()
+	return done
+}
@@ -9,8 +9,6 @@ import (
 	proto "github.com/golang/protobuf/proto"
 	wrappers "github.com/golang/protobuf/ptypes/wrappers"
 	grpc "google.golang.org/grpc"
-	codes "google.golang.org/grpc/codes"
-	status "google.golang.org/grpc/status"
 	math "math"
 )
 
@@ -25,6 +23,40 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
+type OrderEvent_Type int32
+
+const (
+	OrderEvent_UNKNOWN        OrderEvent_Type = 0
+	OrderEvent_CREATED        OrderEvent_Type = 1
+	OrderEvent_UPDATED        OrderEvent_Type = 2
+	OrderEvent_DELETED        OrderEvent_Type = 3
+	OrderEvent_STATUS_CHANGED OrderEvent_Type = 4
+)
+
+var OrderEvent_Type_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "CREATED",
+	2: "UPDATED",
+	3: "DELETED",
+	4: "STATUS_CHANGED",
+}
+
+var OrderEvent_Type_value = map[string]int32{
+	"UNKNOWN":        0,
+	"CREATED":        1,
+	"UPDATED":        2,
+	"DELETED":        3,
+	"STATUS_CHANGED": 4,
+}
+
+func (x OrderEvent_Type) String() string {
+	return proto.EnumName(OrderEvent_Type_name, int32(x))
+}
+
+func (OrderEvent_Type) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_9a4d768ec9cb4951, []int{4, 0}
+}
+
 type Order struct {
 	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	Items                []string `protobuf:"bytes,2,rep,name=items,proto3" json:"items,omitempty"`
@@ -96,10 +128,76 @@ func (m *Order) GetDestination() string {
 	return ""
 }
 
+// processOrders 的输入
+type ProcessOrderRequest struct {
+	Seq                  uint64   `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	OrderId              string   `protobuf:"bytes,2,opt,name=orderId,proto3" json:"orderId,omitempty"`
+	Ack                  uint64   `protobuf:"varint,3,opt,name=ack,proto3" json:"ack,omitempty"`
+	Heartbeat            bool     `protobuf:"varint,4,opt,name=heartbeat,proto3" json:"heartbeat,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ProcessOrderRequest) Reset()         { *m = ProcessOrderRequest{} }
+func (m *ProcessOrderRequest) String() string { return proto.CompactTextString(m) }
+func (*ProcessOrderRequest) ProtoMessage()    {}
+func (*ProcessOrderRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a4d768ec9cb4951, []int{1}
+}
+
+func (m *ProcessOrderRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ProcessOrderRequest.Unmarshal(m, b)
+}
+func (m *ProcessOrderRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ProcessOrderRequest.Marshal(b, m, deterministic)
+}
+func (m *ProcessOrderRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProcessOrderRequest.Merge(m, src)
+}
+func (m *ProcessOrderRequest) XXX_Size() int {
+	return xxx_messageInfo_ProcessOrderRequest.Size(m)
+}
+func (m *ProcessOrderRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProcessOrderRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProcessOrderRequest proto.InternalMessageInfo
+
+func (m *ProcessOrderRequest) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+func (m *ProcessOrderRequest) GetOrderId() string {
+	if m != nil {
+		return m.OrderId
+	}
+	return ""
+}
+
+func (m *ProcessOrderRequest) GetAck() uint64 {
+	if m != nil {
+		return m.Ack
+	}
+	return 0
+}
+
+func (m *ProcessOrderRequest) GetHeartbeat() bool {
+	if m != nil {
+		return m.Heartbeat
+	}
+	return false
+}
+
 type CombinedShipment struct {
 	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	Status               string   `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
 	OrdersList           []*Order `protobuf:"bytes,3,rep,name=ordersList,proto3" json:"ordersList,omitempty"`
+	Seqs                 []uint64 `protobuf:"varint,4,rep,packed,name=seqs,proto3" json:"seqs,omitempty"`
+	Heartbeat            bool     `protobuf:"varint,5,opt,name=heartbeat,proto3" json:"heartbeat,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -109,7 +207,7 @@ func (m *CombinedShipment) Reset()         { *m = CombinedShipment{} }
 func (m *CombinedShipment) String() string { return proto.CompactTextString(m) }
 func (*CombinedShipment) ProtoMessage()    {}
 func (*CombinedShipment) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9a4d768ec9cb4951, []int{1}
+	return fileDescriptor_9a4d768ec9cb4951, []int{2}
 }
 
 func (m *CombinedShipment) XXX_Unmarshal(b []byte) error {
@@ -151,36 +249,209 @@ func (m *CombinedShipment) GetOrdersList() []*Order {
 	return nil
 }
 
+func (m *CombinedShipment) GetSeqs() []uint64 {
+	if m != nil {
+		return m.Seqs
+	}
+	return nil
+}
+
+func (m *CombinedShipment) GetHeartbeat() bool {
+	if m != nil {
+		return m.Heartbeat
+	}
+	return false
+}
+
+// watchOrders 的订阅条件，各条件同时满足的事件才会发送
+type WatchOrdersRequest struct {
+	StartRevision        uint64            `protobuf:"varint,1,opt,name=startRevision,proto3" json:"startRevision,omitempty"`
+	OrderIds             []string          `protobuf:"bytes,2,rep,name=orderIds,proto3" json:"orderIds,omitempty"`
+	Types                []OrderEvent_Type `protobuf:"varint,3,rep,packed,name=types,proto3,enum=ecommerce.OrderEvent_Type" json:"types,omitempty"`
+	Destination          string            `protobuf:"bytes,4,opt,name=destination,proto3" json:"destination,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *WatchOrdersRequest) Reset()         { *m = WatchOrdersRequest{} }
+func (m *WatchOrdersRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchOrdersRequest) ProtoMessage()    {}
+func (*WatchOrdersRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a4d768ec9cb4951, []int{3}
+}
+
+func (m *WatchOrdersRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchOrdersRequest.Unmarshal(m, b)
+}
+func (m *WatchOrdersRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchOrdersRequest.Marshal(b, m, deterministic)
+}
+func (m *WatchOrdersRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchOrdersRequest.Merge(m, src)
+}
+func (m *WatchOrdersRequest) XXX_Size() int {
+	return xxx_messageInfo_WatchOrdersRequest.Size(m)
+}
+func (m *WatchOrdersRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchOrdersRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchOrdersRequest proto.InternalMessageInfo
+
+func (m *WatchOrdersRequest) GetStartRevision() uint64 {
+	if m != nil {
+		return m.StartRevision
+	}
+	return 0
+}
+
+func (m *WatchOrdersRequest) GetOrderIds() []string {
+	if m != nil {
+		return m.OrderIds
+	}
+	return nil
+}
+
+func (m *WatchOrdersRequest) GetTypes() []OrderEvent_Type {
+	if m != nil {
+		return m.Types
+	}
+	return nil
+}
+
+func (m *WatchOrdersRequest) GetDestination() string {
+	if m != nil {
+		return m.Destination
+	}
+	return ""
+}
+
+// 订单变更事件
+type OrderEvent struct {
+	Revision             uint64          `protobuf:"varint,1,opt,name=revision,proto3" json:"revision,omitempty"`
+	Type                 OrderEvent_Type `protobuf:"varint,2,opt,name=type,proto3,enum=ecommerce.OrderEvent_Type" json:"type,omitempty"`
+	Order                *Order          `protobuf:"bytes,3,opt,name=order,proto3" json:"order,omitempty"`
+	Status               string          `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	ShipmentId           string          `protobuf:"bytes,5,opt,name=shipmentId,proto3" json:"shipmentId,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
+	XXX_unrecognized     []byte          `json:"-"`
+	XXX_sizecache        int32           `json:"-"`
+}
+
+func (m *OrderEvent) Reset()         { *m = OrderEvent{} }
+func (m *OrderEvent) String() string { return proto.CompactTextString(m) }
+func (*OrderEvent) ProtoMessage()    {}
+func (*OrderEvent) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9a4d768ec9cb4951, []int{4}
+}
+
+func (m *OrderEvent) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_OrderEvent.Unmarshal(m, b)
+}
+func (m *OrderEvent) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_OrderEvent.Marshal(b, m, deterministic)
+}
+func (m *OrderEvent) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OrderEvent.Merge(m, src)
+}
+func (m *OrderEvent) XXX_Size() int {
+	return xxx_messageInfo_OrderEvent.Size(m)
+}
+func (m *OrderEvent) XXX_DiscardUnknown() {
+	xxx_messageInfo_OrderEvent.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_OrderEvent proto.InternalMessageInfo
+
+func (m *OrderEvent) GetRevision() uint64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+func (m *OrderEvent) GetType() OrderEvent_Type {
+	if m != nil {
+		return m.Type
+	}
+	return OrderEvent_UNKNOWN
+}
+
+func (m *OrderEvent) GetOrder() *Order {
+	if m != nil {
+		return m.Order
+	}
+	return nil
+}
+
+func (m *OrderEvent) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *OrderEvent) GetShipmentId() string {
+	if m != nil {
+		return m.ShipmentId
+	}
+	return ""
+}
+
 func init() {
+	proto.RegisterEnum("ecommerce.OrderEvent_Type", OrderEvent_Type_name, OrderEvent_Type_value)
 	proto.RegisterType((*Order)(nil), "ecommerce.Order")
+	proto.RegisterType((*ProcessOrderRequest)(nil), "ecommerce.ProcessOrderRequest")
 	proto.RegisterType((*CombinedShipment)(nil), "ecommerce.CombinedShipment")
+	proto.RegisterType((*WatchOrdersRequest)(nil), "ecommerce.WatchOrdersRequest")
+	proto.RegisterType((*OrderEvent)(nil), "ecommerce.OrderEvent")
 }
 
 func init() { proto.RegisterFile("product_info.proto", fileDescriptor_9a4d768ec9cb4951) }
 
 var fileDescriptor_9a4d768ec9cb4951 = []byte{
-	// 323 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x50, 0x4d, 0x4b, 0x33, 0x31,
-	0x18, 0x24, 0xbb, 0x6f, 0xcb, 0xdb, 0xa7, 0x7e, 0x94, 0x20, 0xb2, 0x54, 0x91, 0xa5, 0xa7, 0x3d,
-	0xa5, 0xa5, 0xde, 0x3c, 0x09, 0x5e, 0xfd, 0x80, 0x2d, 0x78, 0x95, 0x34, 0x79, 0xba, 0x0d, 0x74,
-	0x93, 0x90, 0x64, 0xf1, 0x27, 0xf8, 0x3b, 0xfc, 0xa7, 0xb2, 0xd9, 0xb6, 0x14, 0x0b, 0xa2, 0xc7,
-	0x99, 0xcc, 0x4c, 0xe6, 0x19, 0xa0, 0xd6, 0x19, 0xd9, 0x88, 0xf0, 0xa6, 0xf4, 0xca, 0x30, 0xeb,
-	0x4c, 0x30, 0x74, 0x80, 0xc2, 0xd4, 0x35, 0x3a, 0x81, 0xe3, 0x9b, 0xca, 0x98, 0x6a, 0x83, 0xd3,
-	0xf8, 0xb0, 0x6c, 0x56, 0xd3, 0x77, 0xc7, 0xad, 0x45, 0xe7, 0x3b, 0xe9, 0xe4, 0x83, 0x40, 0xef,
-	0xc5, 0x49, 0x74, 0xf4, 0x0c, 0x12, 0x25, 0x33, 0x92, 0x93, 0x62, 0x50, 0x26, 0x4a, 0xd2, 0x0b,
-	0xe8, 0xa9, 0x80, 0xb5, 0xcf, 0x92, 0x3c, 0x2d, 0x06, 0x65, 0x07, 0x68, 0x0e, 0x43, 0x89, 0x5e,
-	0x38, 0x65, 0x83, 0x32, 0x3a, 0x4b, 0xa3, 0xfc, 0x90, 0x6a, 0x7d, 0xd6, 0x29, 0x81, 0xd9, 0xbf,
-	0x9c, 0x14, 0x49, 0xd9, 0x81, 0xad, 0x2f, 0x28, 0xcd, 0xa3, 0xaf, 0xb7, 0xf7, 0xed, 0xa8, 0xc9,
-	0x06, 0x46, 0x0f, 0xa6, 0x5e, 0x2a, 0x8d, 0x72, 0xb1, 0x56, 0xb6, 0x46, 0x1d, 0x8e, 0x3a, 0x5d,
-	0x42, 0xdf, 0x07, 0x1e, 0x9a, 0xb6, 0x54, 0xcb, 0x6d, 0x11, 0x9d, 0x01, 0x98, 0xf6, 0x08, 0xff,
-	0xa8, 0x7c, 0xc8, 0xd2, 0x3c, 0x2d, 0x86, 0xf3, 0x11, 0xdb, 0xaf, 0xc0, 0xe2, 0x85, 0xe5, 0x81,
-	0x66, 0xfe, 0x99, 0xc0, 0x79, 0x64, 0x9f, 0xb8, 0xe6, 0x15, 0xc6, 0xdf, 0xee, 0xe0, 0x7f, 0x85,
-	0xa1, 0x5b, 0xe3, 0x9a, 0x75, 0xc3, 0xb1, 0xdd, 0x70, 0x6c, 0x11, 0x9c, 0xd2, 0xd5, 0x2b, 0xdf,
-	0x34, 0x38, 0x3e, 0xca, 0xa6, 0xf7, 0x70, 0xe2, 0x91, 0x3b, 0xb1, 0x8e, 0xd0, 0xff, 0xd5, 0x3f,
-	0x23, 0x6d, 0x42, 0x63, 0x25, 0x0f, 0xb8, 0x4d, 0x38, 0xd2, 0x8c, 0x7f, 0xcc, 0x2c, 0x08, 0x7d,
-	0x86, 0x53, 0xeb, 0x8c, 0x40, 0xef, 0x7f, 0x55, 0xe2, 0xea, 0xe0, 0x83, 0xef, 0xcb, 0x17, 0x64,
-	0x46, 0x96, 0xfd, 0x68, 0xbb, 0xfd, 0x0a, 0x00, 0x00, 0xff, 0xff, 0x22, 0xf9, 0x23, 0x1b, 0x63,
-	0x02, 0x00, 0x00,
+	// 628 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0xdd, 0x4e, 0xdb, 0x4c,
+	0x10, 0xfd, 0xfc, 0x13, 0x48, 0x26, 0x1f, 0x34, 0x9a, 0xfe, 0xc8, 0x4a, 0x29, 0x8a, 0xa2, 0xaa,
+	0xf2, 0x95, 0x89, 0xe8, 0x1d, 0x57, 0x45, 0xc4, 0x6a, 0x51, 0x69, 0x40, 0x4e, 0x28, 0x97, 0x68,
+	0x63, 0x0f, 0xc1, 0x2a, 0xb1, 0x9d, 0xdd, 0x0d, 0x88, 0x27, 0x68, 0x9f, 0xa2, 0x0f, 0xd0, 0xb7,
+	0xeb, 0x1b, 0x54, 0xbb, 0x76, 0x82, 0xf3, 0x23, 0x5a, 0xee, 0x7c, 0x66, 0xcf, 0xcc, 0x9c, 0x3d,
+	0xb3, 0x63, 0xc0, 0x8c, 0xa7, 0xd1, 0x34, 0x94, 0x97, 0x71, 0x72, 0x95, 0x7a, 0x19, 0x4f, 0x65,
+	0x8a, 0x35, 0x0a, 0xd3, 0xf1, 0x98, 0x78, 0x48, 0xcd, 0xdd, 0x51, 0x9a, 0x8e, 0x6e, 0x68, 0x4f,
+	0x1f, 0x0c, 0xa7, 0x57, 0x7b, 0x77, 0x9c, 0x65, 0x19, 0x71, 0x91, 0x53, 0xdb, 0xdf, 0x0d, 0xa8,
+	0x9c, 0xf2, 0x88, 0x38, 0x6e, 0x83, 0x19, 0x47, 0x8e, 0xd1, 0x32, 0xdc, 0x5a, 0x60, 0xc6, 0x11,
+	0xbe, 0x80, 0x4a, 0x2c, 0x69, 0x2c, 0x1c, 0xb3, 0x65, 0xb9, 0xb5, 0x20, 0x07, 0xd8, 0x82, 0x7a,
+	0x44, 0x22, 0xe4, 0x71, 0x26, 0xe3, 0x34, 0x71, 0x2c, 0x4d, 0x2f, 0x87, 0x54, 0x5e, 0xc6, 0xe3,
+	0x90, 0x1c, 0xbb, 0x65, 0xb8, 0x66, 0x90, 0x83, 0x22, 0x4f, 0xc6, 0x09, 0xd3, 0x79, 0x95, 0x79,
+	0xde, 0x2c, 0xd4, 0x9e, 0xc0, 0xf3, 0x33, 0x9e, 0x86, 0x24, 0x84, 0xd6, 0x13, 0xd0, 0x64, 0x4a,
+	0x42, 0x62, 0x03, 0x2c, 0x41, 0x13, 0xad, 0xcb, 0x0e, 0xd4, 0x27, 0x3a, 0xb0, 0x99, 0x2a, 0xc6,
+	0x71, 0xe4, 0x98, 0xba, 0xcc, 0x0c, 0x2a, 0x2e, 0x0b, 0xbf, 0x69, 0x51, 0x76, 0xa0, 0x3e, 0x71,
+	0x07, 0x6a, 0xd7, 0xc4, 0xb8, 0x1c, 0x12, 0x93, 0x5a, 0x50, 0x35, 0x78, 0x08, 0xb4, 0x7f, 0x1a,
+	0xd0, 0x38, 0x4a, 0xc7, 0xc3, 0x38, 0xa1, 0xa8, 0x7f, 0x1d, 0x67, 0x63, 0x4a, 0xe4, 0x8a, 0x0f,
+	0xaf, 0x60, 0x43, 0x48, 0x26, 0xa7, 0xa2, 0xe8, 0x56, 0x20, 0xec, 0x00, 0xe8, 0xbe, 0xe2, 0x24,
+	0x16, 0xd2, 0xb1, 0x5a, 0x96, 0x5b, 0xdf, 0x6f, 0x78, 0x73, 0xe7, 0xbd, 0xfc, 0x16, 0x25, 0x0e,
+	0x22, 0xd8, 0x82, 0x26, 0xc2, 0xb1, 0x5b, 0x96, 0x6b, 0x07, 0xfa, 0x7b, 0x51, 0x60, 0x65, 0x59,
+	0xe0, 0x2f, 0x03, 0xf0, 0x82, 0xc9, 0xf0, 0x5a, 0x17, 0x13, 0x33, 0x4f, 0xde, 0xc2, 0x96, 0x90,
+	0x8c, 0xcb, 0x80, 0x6e, 0x63, 0xa1, 0xec, 0xcc, 0xdd, 0x59, 0x0c, 0x62, 0x13, 0xaa, 0x85, 0x31,
+	0xb3, 0x19, 0xce, 0x31, 0x76, 0xa0, 0x22, 0xef, 0x33, 0x12, 0x5a, 0xf7, 0xf6, 0x7e, 0x73, 0x59,
+	0xb7, 0x7f, 0x4b, 0x89, 0xf4, 0x06, 0xf7, 0x19, 0x05, 0x39, 0x71, 0x79, 0x80, 0xf6, 0xea, 0x00,
+	0x7f, 0x98, 0x00, 0x0f, 0xc9, 0xaa, 0x3d, 0x5f, 0xd4, 0x37, 0xc7, 0xe8, 0x81, 0xad, 0xaa, 0x6a,
+	0x47, 0x1f, 0xef, 0xae, 0x79, 0xf8, 0x0e, 0x2a, 0x5a, 0xba, 0x1e, 0xed, 0x3a, 0x9b, 0xf3, 0xe3,
+	0xd2, 0xac, 0xec, 0x85, 0x59, 0xed, 0x02, 0x88, 0x62, 0xbe, 0xc7, 0x51, 0xf1, 0xf8, 0x4a, 0x91,
+	0x76, 0x0f, 0x6c, 0xd5, 0x0d, 0xeb, 0xb0, 0x79, 0xde, 0xfb, 0xdc, 0x3b, 0xbd, 0xe8, 0x35, 0xfe,
+	0x53, 0xe0, 0x28, 0xf0, 0x0f, 0x07, 0x7e, 0xb7, 0x61, 0xe8, 0x93, 0xb3, 0xae, 0x06, 0xa6, 0x02,
+	0x5d, 0xff, 0xc4, 0x57, 0xc0, 0x42, 0x84, 0xed, 0xfe, 0xe0, 0x70, 0x70, 0xde, 0xbf, 0x3c, 0xfa,
+	0x74, 0xd8, 0xfb, 0xe8, 0x77, 0x1b, 0xf6, 0xfe, 0x6f, 0x0b, 0x9e, 0x69, 0x61, 0x5f, 0x58, 0xc2,
+	0x46, 0xa4, 0xdf, 0xd5, 0x01, 0x54, 0x59, 0x14, 0xe9, 0x28, 0xae, 0x5c, 0xa0, 0xb9, 0xe3, 0xe5,
+	0x8b, 0xea, 0xcd, 0x16, 0xd5, 0xeb, 0x4b, 0x1e, 0x27, 0xa3, 0xaf, 0xec, 0x66, 0x4a, 0x78, 0xac,
+	0xcc, 0xbf, 0x21, 0x49, 0x79, 0xfa, 0xa3, 0xe4, 0xbf, 0x94, 0x3a, 0x80, 0xea, 0x88, 0xe4, 0xbf,
+	0xd4, 0x59, 0x11, 0x89, 0x1f, 0xe0, 0x7f, 0x41, 0x8c, 0xcf, 0x9e, 0xe3, 0x53, 0xf3, 0x3b, 0x86,
+	0xaa, 0x30, 0xcd, 0x22, 0x56, 0x5c, 0x44, 0x3c, 0xd5, 0x08, 0xd7, 0xc0, 0x33, 0xd8, 0xca, 0x4a,
+	0xbf, 0x09, 0x81, 0xbb, 0xa5, 0x12, 0x6b, 0x7e, 0x20, 0xcd, 0xd7, 0xa5, 0xf3, 0xe5, 0x65, 0x77,
+	0x8d, 0x8e, 0x81, 0x3e, 0xd4, 0xef, 0x1e, 0x76, 0x0c, 0xdf, 0x94, 0xf8, 0xab, 0xbb, 0xd7, 0x7c,
+	0xb9, 0xf6, 0xb1, 0x76, 0x8c, 0xe1, 0x86, 0x16, 0xfc, 0xfe, 0xcf, 0x00, 0x9b, 0x6d, 0xe7, 0x7b,
+	0x91, 0x05, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -195,10 +466,13 @@ const _ = grpc.SupportPackageIsVersion4
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type OrderManagementClient interface {
+	AddOrder(ctx context.Context, in *Order, opts ...grpc.CallOption) (*wrappers.StringValue, error)
+	DeleteOrder(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*wrappers.StringValue, error)
 	GetOrder(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*Order, error)
 	SearchOrders(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (OrderManagement_SearchOrdersClient, error)
 	UpdateOrders(ctx context.Context, opts ...grpc.CallOption) (OrderManagement_UpdateOrdersClient, error)
 	ProcessOrders(ctx context.Context, opts ...grpc.CallOption) (OrderManagement_ProcessOrdersClient, error)
+	WatchOrders(ctx context.Context, in *WatchOrdersRequest, opts ...grpc.CallOption) (OrderManagement_WatchOrdersClient, error)
 }
 
 type orderManagementClient struct {
@@ -209,6 +483,24 @@ func NewOrderManagementClient(cc *grpc.ClientConn) OrderManagementClient {
 	return &orderManagementClient{cc}
 }
 
+func (c *orderManagementClient) AddOrder(ctx context.Context, in *Order, opts ...grpc.CallOption) (*wrappers.StringValue, error) {
+	out := new(wrappers.StringValue)
+	err := c.cc.Invoke(ctx, "/ecommerce.OrderManagement/addOrder", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderManagementClient) DeleteOrder(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*wrappers.StringValue, error) {
+	out := new(wrappers.StringValue)
+	err := c.cc.Invoke(ctx, "/ecommerce.OrderManagement/deleteOrder", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *orderManagementClient) GetOrder(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*Order, error) {
 	out := new(Order)
 	err := c.cc.Invoke(ctx, "/ecommerce.OrderManagement/getOrder", in, out, opts...)
@@ -294,7 +586,7 @@ func (c *orderManagementClient) ProcessOrders(ctx context.Context, opts ...grpc.
 }
 
 type OrderManagement_ProcessOrdersClient interface {
-	Send(*wrappers.StringValue) error
+	Send(*ProcessOrderRequest) error
 	Recv() (*CombinedShipment, error)
 	grpc.ClientStream
 }
@@ -303,7 +595,7 @@ type orderManagementProcessOrdersClient struct {
 	grpc.ClientStream
 }
 
-func (x *orderManagementProcessOrdersClient) Send(m *wrappers.StringValue) error {
+func (x *orderManagementProcessOrdersClient) Send(m *ProcessOrderRequest) error {
 	return x.ClientStream.SendMsg(m)
 }
 
@@ -315,33 +607,87 @@ func (x *orderManagementProcessOrdersClient) Recv() (*CombinedShipment, error) {
 	return m, nil
 }
 
+func (c *orderManagementClient) WatchOrders(ctx context.Context, in *WatchOrdersRequest, opts ...grpc.CallOption) (OrderManagement_WatchOrdersClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_OrderManagement_serviceDesc.Streams[3], "/ecommerce.OrderManagement/watchOrders", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &orderManagementWatchOrdersClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type OrderManagement_WatchOrdersClient interface {
+	Recv() (*OrderEvent, error)
+	grpc.ClientStream
+}
+
+type orderManagementWatchOrdersClient struct {
+	grpc.ClientStream
+}
+
+func (x *orderManagementWatchOrdersClient) Recv() (*OrderEvent, error) {
+	m := new(OrderEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // OrderManagementServer is the server API for OrderManagement service.
 type OrderManagementServer interface {
+	AddOrder(context.Context, *Order) (*wrappers.StringValue, error)
+	DeleteOrder(context.Context, *wrappers.StringValue) (*wrappers.StringValue, error)
 	GetOrder(context.Context, *wrappers.StringValue) (*Order, error)
 	SearchOrders(*wrappers.StringValue, OrderManagement_SearchOrdersServer) error
 	UpdateOrders(OrderManagement_UpdateOrdersServer) error
 	ProcessOrders(OrderManagement_ProcessOrdersServer) error
+	WatchOrders(*WatchOrdersRequest, OrderManagement_WatchOrdersServer) error
 }
 
-// UnimplementedOrderManagementServer can be embedded to have forward compatible implementations.
-type UnimplementedOrderManagementServer struct {
+func RegisterOrderManagementServer(s *grpc.Server, srv OrderManagementServer) {
+	s.RegisterService(&_OrderManagement_serviceDesc, srv)
 }
 
-func (*UnimplementedOrderManagementServer) GetOrder(ctx context.Context, req *wrappers.StringValue) (*Order, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetOrder not implemented")
-}
-func (*UnimplementedOrderManagementServer) SearchOrders(req *wrappers.StringValue, srv OrderManagement_SearchOrdersServer) error {
-	return status.Errorf(codes.Unimplemented, "method SearchOrders not implemented")
-}
-func (*UnimplementedOrderManagementServer) UpdateOrders(srv OrderManagement_UpdateOrdersServer) error {
-	return status.Errorf(codes.Unimplemented, "method UpdateOrders not implemented")
-}
-func (*UnimplementedOrderManagementServer) ProcessOrders(srv OrderManagement_ProcessOrdersServer) error {
-	return status.Errorf(codes.Unimplemented, "method ProcessOrders not implemented")
+func _OrderManagement_AddOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Order)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderManagementServer).AddOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ecommerce.OrderManagement/AddOrder",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderManagementServer).AddOrder(ctx, req.(*Order))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func RegisterOrderManagementServer(s *grpc.Server, srv OrderManagementServer) {
-	s.RegisterService(&_OrderManagement_serviceDesc, srv)
+func _OrderManagement_DeleteOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(wrappers.StringValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderManagementServer).DeleteOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ecommerce.OrderManagement/DeleteOrder",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderManagementServer).DeleteOrder(ctx, req.(*wrappers.StringValue))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
 func _OrderManagement_GetOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
@@ -415,7 +761,7 @@ func _OrderManagement_ProcessOrders_Handler(srv interface{}, stream grpc.ServerS
 
 type OrderManagement_ProcessOrdersServer interface {
 	Send(*CombinedShipment) error
-	Recv() (*wrappers.StringValue, error)
+	Recv() (*ProcessOrderRequest, error)
 	grpc.ServerStream
 }
 
@@ -427,18 +773,47 @@ func (x *orderManagementProcessOrdersServer) Send(m *CombinedShipment) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func (x *orderManagementProcessOrdersServer) Recv() (*wrappers.StringValue, error) {
-	m := new(wrappers.StringValue)
+func (x *orderManagementProcessOrdersServer) Recv() (*ProcessOrderRequest, error) {
+	m := new(ProcessOrderRequest)
 	if err := x.ServerStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
 	return m, nil
 }
 
+func _OrderManagement_WatchOrders_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchOrdersRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OrderManagementServer).WatchOrders(m, &orderManagementWatchOrdersServer{stream})
+}
+
+type OrderManagement_WatchOrdersServer interface {
+	Send(*OrderEvent) error
+	grpc.ServerStream
+}
+
+type orderManagementWatchOrdersServer struct {
+	grpc.ServerStream
+}
+
+func (x *orderManagementWatchOrdersServer) Send(m *OrderEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _OrderManagement_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "ecommerce.OrderManagement",
 	HandlerType: (*OrderManagementServer)(nil),
 	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "addOrder",
+			Handler:    _OrderManagement_AddOrder_Handler,
+		},
+		{
+			MethodName: "deleteOrder",
+			Handler:    _OrderManagement_DeleteOrder_Handler,
+		},
 		{
 			MethodName: "getOrder",
 			Handler:    _OrderManagement_GetOrder_Handler,
@@ -461,6 +836,11 @@ var _OrderManagement_serviceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "watchOrders",
+			Handler:       _OrderManagement_WatchOrders_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "product_info.proto",
 }
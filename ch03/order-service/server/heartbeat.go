@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"heartbeat"
+	"log"
+	pb "ordermgt/server/ecommerce"
+	"sync"
+	"time"
+)
+
+// ProcessOrders 的应用层心跳：双方在元数据 x-heartbeat-interval 中声明自己的心跳间隔（客户端在请求元数据中，
+// 服务端在响应头中），流空闲时按该间隔发送心跳消息。对端声明了间隔时，连续 heartbeat-misses 个间隔
+// 没有收到对端的任何消息就判定对端失联，以 Unavailable 结束流。
+// HTTP/2 keepalive 只能发现连接断开，无法发现代理后面连接仍在但对端已经不再处理的流
+const heartbeatIntervalKey = "x-heartbeat-interval"
+
+var (
+	heartbeatInterval = flag.Duration("heartbeat-interval", 0, "interval of server heartbeats on idle ProcessOrders streams, 0 disables")
+	heartbeatMisses   = flag.Int("heartbeat-misses", 3, "cancel a ProcessOrders stream after this many client heartbeat intervals without any message")
+
+	// 传输层 keepalive，默认值与 grpc 相同
+	keepaliveTime                = flag.Duration("keepalive-time", 2*time.Hour, "ping the client after the connection has been idle for this long")
+	keepaliveTimeout             = flag.Duration("keepalive-timeout", 20*time.Second, "close the connection if a keepalive ping is not acknowledged within this time")
+	maxConnectionIdle            = flag.Duration("max-connection-idle", 0, "close connections without active RPCs after this long, 0 means infinity")
+	keepaliveMinTime             = flag.Duration("keepalive-min-time", 5*time.Minute, "minimum interval between client keepalive pings, clients pinging more often get GOAWAY too_many_pings")
+	keepalivePermitWithoutStream = flag.Bool("keepalive-permit-without-stream", false, "allow client keepalive pings when there are no active RPCs")
+)
+
+// keepaliveServerOptions 返回传输层 keepalive 参数和对客户端 ping 的限制策略
+func keepaliveServerOptions() []grpc.ServerOption {
+	params := keepalive.ServerParameters{Time: *keepaliveTime, Timeout: *keepaliveTimeout}
+	if *maxConnectionIdle > 0 {
+		params.MaxConnectionIdle = *maxConnectionIdle
+	}
+	return []grpc.ServerOption{
+		grpc.KeepaliveParams(params),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             *keepaliveMinTime,
+			PermitWithoutStream: *keepalivePermitWithoutStream,
+		}),
+	}
+}
+
+// heartbeatStream 包装 ProcessOrders 流：Send 互斥（心跳协程和处理函数都会发送），
+// Recv 记录收到消息的时间并丢弃不携带确认的客户端心跳
+type heartbeatStream struct {
+	pb.OrderManagement_ProcessOrdersServer
+	*heartbeat.Heartbeat
+	mu sync.Mutex
+}
+
+// newHeartbeatStream 读取客户端声明的心跳间隔，并在响应头中声明服务端的心跳间隔，需要在发送响应头之前调用
+func newHeartbeatStream(stream pb.OrderManagement_ProcessOrdersServer) (*heartbeatStream, error) {
+	var timeout time.Duration
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	if v := md.Get(heartbeatIntervalKey); len(v) > 0 {
+		interval, err := time.ParseDuration(v[0])
+		if err != nil || interval <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid %s: %q", heartbeatIntervalKey, v[0])
+		}
+		timeout = interval * time.Duration(*heartbeatMisses)
+	}
+	if *heartbeatInterval > 0 {
+		if err := stream.SetHeader(metadata.Pairs(heartbeatIntervalKey, heartbeatInterval.String())); err != nil {
+			return nil, err
+		}
+	}
+	hs := &heartbeatStream{OrderManagement_ProcessOrdersServer: stream}
+	hs.Heartbeat = heartbeat.New(*heartbeatInterval, timeout, func() error {
+		return hs.Send(&pb.CombinedShipment{Heartbeat: true})
+	})
+	return hs, nil
+}
+
+func (hs *heartbeatStream) Send(m *pb.CombinedShipment) error {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.Sent()
+	return hs.OrderManagement_ProcessOrdersServer.Send(m)
+}
+
+func (hs *heartbeatStream) Recv() (*pb.ProcessOrderRequest, error) {
+	for {
+		req, err := hs.OrderManagement_ProcessOrdersServer.Recv()
+		if err != nil {
+			return nil, err
+		}
+		hs.Received()
+		if !req.GetHeartbeat() || req.GetAck() > 0 {
+			return req, nil
+		}
+	}
+}
+
+// peerLost 在对端失联时记录日志
+func (hs *heartbeatStream) peerLost() error {
+	log.Printf("ProcessOrders: %v", hs.Err())
+	return hs.Err()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	pb "ordermgt/server/ecommerce"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startHeartbeatServer 启动服务端，ProcessOrders 结束时向返回的信道发送处理函数的错误。
+// 客户端使用固定的 64KB 流量控制窗口，不读取批次时服务端的发送很快就会阻塞
+func startHeartbeatServer(t *testing.T) (pb.OrderManagementClient, <-chan error) {
+	t.Helper()
+	exits := make(chan error, 1)
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := handler(srv, ss)
+		exits <- err
+		return err
+	}))
+	feed := newOrderFeed(*watchBuffer, *watchHistory)
+	pb.RegisterOrderManagementServer(s, &server{sessions: newSessionStore(feed), feed: feed})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(),
+		grpc.WithInitialWindowSize(1<<16), grpc.WithInitialConnWindowSize(1<<16),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.Dial() }))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewOrderManagementClient(conn), exits
+}
+
+// 客户端声明了心跳间隔却不再发送任何消息时，服务端在大约 heartbeat-misses 个间隔后以 Unavailable 结束流
+func TestSilentPeer(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	timeout := interval * time.Duration(*heartbeatMisses)
+
+	t.Run("Idle", func(t *testing.T) {
+		client, exits := startHeartbeatServer(t)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		ctx = metadata.AppendToOutgoingContext(ctx, heartbeatIntervalKey, interval.String())
+		start := time.Now()
+		stream, err := client.ProcessOrders(ctx)
+		if err != nil {
+			t.Fatalf("ProcessOrders: %v", err)
+		}
+		_, err = stream.Recv()
+		took := time.Since(start)
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("Recv = %v, want Unavailable", err)
+		}
+		// 每 timeout/3 检查一次，最多晚一个检查周期
+		if took < timeout || took > timeout+interval+200*time.Millisecond {
+			t.Errorf("stream ended after %v, want about %v", took, timeout)
+		}
+		if err := <-exits; status.Code(err) != codes.Unavailable {
+			t.Errorf("handler returned %v, want Unavailable", err)
+		}
+	})
+
+	// 工作协程阻塞在发送批次中、处理函数阻塞在 submit 中时同样能发现客户端失联
+	t.Run("BlockedInSubmit", func(t *testing.T) {
+		defer func(workers, queue int) { *processWorkers, *processQueueSize = workers, queue }(*processWorkers, *processQueueSize)
+		*processWorkers, *processQueueSize = 1, 1
+
+		client, exits := startHeartbeatServer(t)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		ctx = metadata.AppendToOutgoingContext(ctx, heartbeatIntervalKey, interval.String())
+		stream, err := client.ProcessOrders(ctx)
+		if err != nil {
+			t.Fatalf("ProcessOrders: %v", err)
+		}
+		var sent int64
+		go func() {
+			for seq := uint64(1); seq <= 100000; seq++ {
+				if stream.Send(&pb.ProcessOrderRequest{Seq: seq, OrderId: "102"}) != nil {
+					return
+				}
+				atomic.StoreInt64(&sent, int64(seq))
+			}
+		}()
+		// 不读取批次，等待双方的流量控制窗口用尽，客户端的发送停止
+		for last := int64(-1); ; {
+			time.Sleep(100 * time.Millisecond)
+			n := atomic.LoadInt64(&sent)
+			if n == last {
+				break
+			}
+			last = n
+		}
+		if n := atomic.LoadInt64(&sent); n == 100000 {
+			t.Fatal("client never blocked, the server kept reading")
+		}
+		select {
+		case err := <-exits:
+			if status.Code(err) != codes.Unavailable {
+				t.Errorf("handler returned %v, want Unavailable", err)
+			}
+		case <-time.After(timeout + time.Second):
+			t.Fatal("handler blocked in submit did not notice the silent client")
+		}
+	})
+}
@@ -2,40 +2,135 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"github.com/golang/protobuf/ptypes/wrappers"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"io"
 	"log"
 	"net"
 	pb "ordermgt/server/ecommerce"
+	"os"
 	"strings"
+	"sync"
 )
 
 const (
-	port = ":50051"
+	port           = ":50051"
 	orderBatchSize = 3
 )
 
-var orderMap = make(map[string]pb.Order)
+var (
+	orderMap = make(map[string]pb.Order)
+	orderMu  sync.RWMutex // 保护 orderMap，ProcessOrders 的工作协程与 AddOrder、UpdateOrders 并发访问
+)
+
+// getOrder 返回订单的副本
+func getOrder(id string) (pb.Order, bool) {
+	orderMu.RLock()
+	defer orderMu.RUnlock()
+	ord, found := orderMap[id]
+	return ord, found
+}
 
 type server struct {
 	orderMap map[string]*pb.Order
+	sessions *sessionStore // ProcessOrders 的会话检查点
+	feed     *orderFeed    // 订单变更事件，由 WatchOrders 订阅
 }
 
-func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) error {
-	batchMarker := 1
-	var combinedShipmentMap = make(map[string]pb.CombinedShipment)
-	for {
-		orderId, err := stream.Recv()
-		log.Printf("Reading Proc order ; %s", orderId)
+func (s *server) AddOrder(ctx context.Context, orderReq *pb.Order) (*wrappers.StringValue, error) {
+	s.putOrder(*orderReq)
+	log.Println("Order : ", orderReq.Id, " -> Added")
+	return &wrappers.StringValue{Value: "Order Added: " + orderReq.Id}, nil
+}
+
+func (s *server) DeleteOrder(ctx context.Context, orderId *wrappers.StringValue) (*wrappers.StringValue, error) {
+	orderMu.Lock()
+	defer orderMu.Unlock()
+	old, found := orderMap[orderId.Value]
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "order %s not found", orderId.Value)
+	}
+	delete(orderMap, orderId.Value)
+	s.feed.publish(pb.OrderEvent_DELETED, old, "", "")
+	log.Println("Order : ", orderId.Value, " -> Deleted")
+	return &wrappers.StringValue{Value: "Order Deleted: " + orderId.Value}, nil
+}
+
+// putOrder 保存订单并发布 CREATED 或 UPDATED 事件，事件的版本号与写入顺序一致
+func (s *server) putOrder(order pb.Order) {
+	orderMu.Lock()
+	defer orderMu.Unlock()
+	eventType := pb.OrderEvent_CREATED
+	if _, found := orderMap[order.Id]; found {
+		eventType = pb.OrderEvent_UPDATED
+	}
+	orderMap[order.Id] = order
+	s.feed.publish(eventType, order, "", "")
+}
+
+func (s *server) ProcessOrders(rawStream pb.OrderManagement_ProcessOrdersServer) error {
+	stream, err := newHeartbeatStream(rawStream)
+	if err != nil {
+		return err
+	}
+	sess, err := s.sessions.attach(stream.Context())
+	if err != nil {
+		return err
+	}
+	finished := false
+	defer func() { s.sessions.detach(sess, finished) }()
+	if err := sess.resume(stream); err != nil {
+		return err
+	}
+	defer stream.Start()()
+
+	orderIds := recvOrderIds(stream, *processQueueSize)
+	defer drainOrderIds(orderIds)
+	workers := startShipmentWorkers(*processWorkers, stream, sess)
+	peerLost := false
+	defer func() {
+		if peerLost {
+			workers.stop() // 阻塞在发送中的工作协程在处理函数返回、流被取消后结束
+			return
+		}
+		workers.wait() // 已接受的订单合并到批次之后会话才能被续传
+	}()
+	for received := 0; ; {
+		var r recvResult
+		var ok bool
+		select {
+		case r, ok = <-orderIds:
+			if !ok { // 流的上下文已取消
+				return status.FromContextError(stream.Context().Err()).Err()
+			}
+			processStats.queued.Add(-1)
+		case <-shuttingDown:
+			return flushShipments(stream, sess, workers) // 服务器退出前发送已缓存的批次
+		case <-stream.Dead():
+			peerLost = true
+			return stream.peerLost() // 客户端失联，会话保留等待续传
+		}
+		req, err := r.req, r.err
+		log.Printf("Reading Proc order ; %s", req)
 		if err == io.EOF {
-			log.Printf("EOF : %s", orderId)
-			for _, shipment := range combinedShipmentMap {
-				if err := stream.Send(&shipment); err != nil {
-					return err
-				}
+			log.Printf("EOF : %s", req)
+			if err := workers.wait(); err != nil {
+				return err
 			}
+			if err := sess.flush(stream); err != nil {
+				return err
+			}
+			// 最后的批次被确认之前保留会话，客户端没有收到它们时可以续传
+			sess.mu.Lock()
+			finished = len(sess.unacked) == 0
+			sess.mu.Unlock()
 			return nil
 		}
 		if err != nil {
@@ -43,43 +138,58 @@ func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) er
 			return err
 		}
 
-		destination := orderMap[orderId.GetValue()].Destination
-		shipment, found := combinedShipmentMap[destination]
-
-		if found {
-			ord := orderMap[orderId.GetValue()]
-			shipment.OrdersList = append(shipment.OrdersList, &ord)
-			combinedShipmentMap[destination] = shipment
-		} else {
-			comShip := pb.CombinedShipment{Id: "cmb - " + (orderMap[orderId.GetValue()].Description), Status: "Processed!"}
-			ord := orderMap[orderId.GetValue()]
-			comShip.OrdersList = append(shipment.OrdersList, &ord)
-			combinedShipmentMap[destination] = comShip
-			log.Print(len(comShip.OrdersList), comShip.GetId())
+		if req.GetHeartbeat() { // 携带确认的心跳，客户端发送完订单后据此确认之后收到的批次
+			sess.mu.Lock()
+			sess.confirm(req.GetAck())
+			sess.mu.Unlock()
+			continue
 		}
+		received++
 
-		if batchMarker == orderBatchSize {
-			for _, comb := range combinedShipmentMap {
-				log.Printf("Shipping : %v -> %v", comb.Id, len(comb.OrdersList))
-				if err := stream.Send(&comb); err != nil {
-					return err
-				}
+		sess.mu.Lock()
+		seq, duplicate, err := sess.accept(req)
+		sess.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		if duplicate {
+			log.Printf("Skipping duplicate seq %d", seq)
+			continue
+		}
+		if !workers.submit(shipmentJob{seq: seq, orderId: req.GetOrderId()}, stream.Dead()) {
+			select {
+			case <-stream.Dead(): // 工作协程阻塞在发送中时客户端失联
+				peerLost = true
+				return stream.peerLost()
+			default:
 			}
-			batchMarker = 0
-			combinedShipmentMap = make(map[string]pb.CombinedShipment)
-		} else {
-			batchMarker++
+			return workers.wait() // 发送批次失败，该订单没有被接受，续传时客户端会重发
+		}
+		sess.mu.Lock()
+		sess.commit(seq)
+		sess.mu.Unlock()
+		if *breakEvery > 0 && received%*breakEvery == 0 {
+			return status.Errorf(codes.Unavailable, "injected stream break after %d orders", received)
 		}
 	}
 }
 
 func (s *server) GetOrder(ctx context.Context, orderId *wrappers.StringValue) (*pb.Order, error) {
-	ord := orderMap[orderId.Value]
+	ord, _ := getOrder(orderId.Value)
 	return &ord, nil
 }
 
 func (s *server) SearchOrders(searchQuery *wrappers.StringValue, strem pb.OrderManagement_SearchOrdersServer) error {
-	for key, order := range orderMap {
+	md, _ := metadata.FromIncomingContext(strem.Context())
+	sent := 0
+	for _, key := range searchKeys(md) {
+		if err := strem.Context().Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		order, found := getOrder(key)
+		if !found {
+			continue // 已被删除
+		}
 		log.Print(key, order)
 		for _, itemStr := range order.Items {
 			log.Print(itemStr)
@@ -89,6 +199,10 @@ func (s *server) SearchOrders(searchQuery *wrappers.StringValue, strem pb.OrderM
 					return fmt.Errorf("error sending message to stream: %v", err)
 				}
 				log.Print("Matching Order Found: ", key)
+				sent++
+				if *breakSearchEvery > 0 && sent%*breakSearchEvery == 0 {
+					return status.Errorf(codes.Unavailable, "injected stream break after %d orders", sent)
+				}
 				break
 			}
 		}
@@ -103,7 +217,10 @@ func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) erro
 		if err == io.EOF {          // 检查流是否已经结束
 			return stream.SendAndClose(&wrappers.StringValue{Value: "Orders processed " + ordersStr}) // 服务端发送响应
 		}
-		orderMap[order.Id] = *order
+		if err != nil {
+			return err
+		}
+		s.putOrder(*order)
 
 		log.Println("Order ID ", order.Id, ": Updated")
 		ordersStr += order.Id + ","
@@ -111,16 +228,25 @@ func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) erro
 }
 
 func main() {
+	flag.Parse()
+	// 缓冲区为 0 时订阅者只要没有正在等待事件就会被断开
+	if *watchBuffer < 1 {
+		log.Fatalf("invalid -watch-buffer %d: must be at least 1", *watchBuffer)
+	}
 	initSampleData()
+	if *metricsAddr != "" {
+		go serveMetrics(*metricsAddr)
+	}
 	lis, err := net.Listen("tcp", port)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	s := grpc.NewServer()
-	pb.RegisterOrderManagementServer(s, &server{})
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
-	}
+	s := grpc.NewServer(keepaliveServerOptions()...)
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(s, healthServer)
+	feed := newOrderFeed(*watchBuffer, *watchHistory)
+	pb.RegisterOrderManagementServer(s, &server{sessions: newSessionStore(feed), feed: feed})
+	os.Exit(serve(s, lis, healthServer))
 }
 
 func initSampleData() {
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"expvar"
+	"flag"
+	"log"
+	"net/http"
+	pb "ordermgt/server/ecommerce"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProcessOrders 的处理流水线：接收协程把收到的订单放入有界队列，处理函数按顺序检查序号后交给工作协程池，
+// 工作协程查找订单、按目的地合并批次并在批次满时发送。队列满时接收协程停止调用 Recv，
+// 由 HTTP/2 流量控制把压力传回客户端，服务端缓存的订单数量因此有上限。
+// 指标通过 expvar 发布在 -metrics-addr 的 /debug/vars
+var (
+	processQueueSize = flag.Int("process-queue", 64, "orders buffered per ProcessOrders stream between Recv and the workers, reading stops when full")
+	processWorkers   = flag.Int("process-workers", 4, "workers per ProcessOrders stream that group orders into shipments")
+	processDelay     = flag.Duration("process-delay", 0, "simulated time to look up each order")
+	metricsAddr      = flag.String("metrics-addr", "", "HTTP address serving ProcessOrders metrics at /debug/vars, disabled when empty")
+)
+
+// pipelineStats 是所有 ProcessOrders 流的汇总指标
+type pipelineStats struct {
+	queued        expvar.Int // 当前所有队列中的订单数
+	maxQueueDepth int64      // 单个队列达到过的最大长度，原子访问
+	stalls        expvar.Int // 队列已满、接收协程停止读取的次数
+	received      expvar.Int
+	grouped       expvar.Int // 已合并到批次的订单
+}
+
+var processStats = newPipelineStats()
+
+func newPipelineStats() *pipelineStats {
+	p := &pipelineStats{}
+	stats := expvar.NewMap("processOrders")
+	stats.Set("queue_depth", &p.queued)
+	stats.Set("max_queue_depth", expvar.Func(func() interface{} { return atomic.LoadInt64(&p.maxQueueDepth) }))
+	stats.Set("backpressure_stalls", &p.stalls)
+	stats.Set("received", &p.received)
+	stats.Set("grouped", &p.grouped)
+	return p
+}
+
+// observeDepth 记录单个队列的长度
+func (p *pipelineStats) observeDepth(depth int) {
+	for {
+		max := atomic.LoadInt64(&p.maxQueueDepth)
+		if int64(depth) <= max || atomic.CompareAndSwapInt64(&p.maxQueueDepth, max, int64(depth)) {
+			return
+		}
+	}
+}
+
+// shipmentJob 是一个已经通过序号检查、等待合并的订单
+type shipmentJob struct {
+	seq     uint64
+	orderId string
+}
+
+// shipmentWorkers 是一个 ProcessOrders 流的工作协程池，会话的批次状态由 sess.mu 保护
+type shipmentWorkers struct {
+	jobs     chan shipmentJob
+	wg       sync.WaitGroup
+	failed   chan struct{} // 发送批次失败时关闭
+	err      error
+	failOnce sync.Once
+	stopOnce sync.Once
+}
+
+func startShipmentWorkers(n int, stream pb.OrderManagement_ProcessOrdersServer, sess *session) *shipmentWorkers {
+	if n < 1 {
+		n = 1
+	}
+	w := &shipmentWorkers{jobs: make(chan shipmentJob), failed: make(chan struct{})}
+	w.wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer w.wg.Done()
+			for job := range w.jobs {
+				order := lookupOrder(job.orderId)
+				sess.mu.Lock()
+				full := sess.add(job.seq, order)
+				sess.mu.Unlock()
+				var err error
+				if full {
+					err = sess.flush(stream)
+				}
+				processStats.grouped.Add(1)
+				if err != nil {
+					w.failOnce.Do(func() {
+						w.err = err
+						close(w.failed)
+					})
+					return
+				}
+			}
+		}()
+	}
+	return w
+}
+
+// submit 把订单交给空闲的工作协程，所有工作协程都忙时阻塞；发送失败或 dead 关闭（对端失联）时返回 false
+func (w *shipmentWorkers) submit(job shipmentJob, dead <-chan struct{}) bool {
+	select {
+	case w.jobs <- job:
+		return true
+	case <-w.failed:
+		return false
+	case <-dead:
+		return false
+	}
+}
+
+// stop 停止接受订单但不等待工作协程。对端失联时工作协程可能阻塞在发送中，处理函数返回后发送才会出错
+func (w *shipmentWorkers) stop() {
+	w.stopOnce.Do(func() { close(w.jobs) })
+}
+
+// wait 停止接受订单，等待已提交的订单全部合并到批次，返回发送批次时的错误
+func (w *shipmentWorkers) wait() error {
+	w.stop()
+	w.wg.Wait()
+	return w.err
+}
+
+// lookupOrder 查找订单，-process-delay 模拟查询耗时
+func lookupOrder(orderId string) pb.Order {
+	if *processDelay > 0 {
+		time.Sleep(*processDelay)
+	}
+	order, _ := getOrder(orderId)
+	return order
+}
+
+// serveMetrics 在 addr 上通过 HTTP 发布 expvar 指标
+func serveMetrics(addr string) {
+	if err := http.ListenAndServe(addr, nil); err != nil {
+		log.Printf("metrics: %v", err)
+	}
+}
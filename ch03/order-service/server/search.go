@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"google.golang.org/grpc/metadata"
+	"sort"
+)
+
+// SearchOrders 的续传：订单按 ID 顺序发送，客户端重连时在元数据 x-resume-token 中给出最后收到的订单 ID，
+// 服务端从它之后继续发送
+const resumeTokenKey = "x-resume-token"
+
+// 故障注入：每个 SearchOrders 流发送指定数量的订单后以 Unavailable 断开，用于验证客户端的重连
+var breakSearchEvery = flag.Int("break-search-every", 0, "abort each SearchOrders stream with Unavailable after sending this many orders, 0 disables")
+
+// searchKeys 返回 resume token 之后的订单 ID，按 ID 排序
+func searchKeys(md metadata.MD) []string {
+	var after string
+	if v := md.Get(resumeTokenKey); len(v) > 0 {
+		after = v[0]
+	}
+	orderMu.RLock()
+	defer orderMu.RUnlock()
+	keys := make([]string, 0, len(orderMap))
+	for key := range orderMap {
+		if key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"net"
+	pb "ordermgt/server/ecommerce"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	initSampleData()
+	os.Exit(m.Run())
+}
+
+// startServer 在内存中的 bufconn 上启动服务端并返回客户端
+func startServer(t *testing.T) pb.OrderManagementClient {
+	_, client := startOrderServer(t)
+	return client
+}
+
+// startOrderServer 同 startServer，同时返回服务实现，用于检查会话等内部状态
+func startOrderServer(t *testing.T) (*server, pb.OrderManagementClient) {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	feed := newOrderFeed(*watchBuffer, *watchHistory)
+	srv := &server{sessions: newSessionStore(feed), feed: feed}
+	pb.RegisterOrderManagementServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.Dial() }))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return srv, pb.NewOrderManagementClient(conn)
+}
+
+// processAll 通过一个可续传的会话处理 orderIds，流断开时从服务端的 x-next-seq 续传，
+// 丢弃所有序号都已收到过的重发批次，返回每个序号出现的次数
+func processAll(t *testing.T, client pb.OrderManagementClient, orderIds []string) map[uint64]int {
+	counts := make(map[uint64]int, len(orderIds))
+	var acked uint64
+	for attempt := 0; attempt < 1000; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		ctx = metadata.AppendToOutgoingContext(ctx, sessionIdKey, t.Name(), resumeAfterKey, strconv.FormatUint(acked, 10))
+		err := func() error {
+			defer cancel()
+			stream, err := client.ProcessOrders(ctx)
+			if err != nil {
+				return err
+			}
+			header, err := stream.Header()
+			if err != nil {
+				return err
+			}
+			next, err := strconv.ParseUint(header.Get(nextSeqKey)[0], 10, 64)
+			if err != nil {
+				t.Fatalf("invalid %s: %v", nextSeqKey, err)
+			}
+			go func() {
+				for seq := next; seq <= uint64(len(orderIds)); seq++ {
+					if stream.Send(&pb.ProcessOrderRequest{Seq: seq, OrderId: orderIds[seq-1], Ack: acked}) != nil {
+						return
+					}
+				}
+				stream.CloseSend()
+			}()
+			for {
+				shipment, err := stream.Recv()
+				if err != nil {
+					return err
+				}
+				replayed := true
+				for _, seq := range shipment.GetSeqs() {
+					if counts[seq] == 0 {
+						replayed = false
+					}
+				}
+				if replayed {
+					continue
+				}
+				for i, seq := range shipment.GetSeqs() {
+					counts[seq]++
+					if got, want := shipment.GetOrdersList()[i].GetId(), orderIds[seq-1]; got != want {
+						t.Errorf("seq %d shipped order %q, want %q", seq, got, want)
+					}
+				}
+				for counts[acked+1] > 0 {
+					acked++
+				}
+			}
+		}()
+		if err == io.EOF {
+			return counts
+		}
+		switch status.Code(err) {
+		case codes.Unavailable:
+		case codes.Aborted: // 服务端还没有结束上一个流
+			time.Sleep(10 * time.Millisecond)
+		default:
+			t.Fatalf("ProcessOrders: %v", err)
+		}
+	}
+	t.Fatal("too many reconnects")
+	return nil
+}
+
+func TestProcessOrdersExactlyOnce(t *testing.T) {
+	const n = 2000
+	sampleIds := []string{"102", "103", "104", "105", "106"}
+	orderIds := make([]string, n)
+	for i := range orderIds {
+		orderIds[i] = sampleIds[i%len(sampleIds)]
+	}
+
+	for _, tc := range []struct {
+		name       string
+		breakEvery int
+	}{
+		{"NoBreaks", 0},
+		{"InjectedBreaks", 97},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func(old int) { *breakEvery = old }(*breakEvery)
+			*breakEvery = tc.breakEvery
+
+			counts := processAll(t, startServer(t), orderIds)
+			for seq := uint64(1); seq <= n; seq++ {
+				if counts[seq] != 1 {
+					t.Errorf("seq %d appeared in %d shipment(s), want 1", seq, counts[seq])
+				}
+			}
+			if len(counts) != n {
+				t.Errorf("got %d distinct seqs, want %d", len(counts), n)
+			}
+		})
+	}
+
+	// 所有流结束后队列中不应残留订单
+	deadline := time.Now().Add(time.Second)
+	for processStats.queued.Value() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if depth := processStats.queued.Value(); depth != 0 {
+		t.Errorf("queue_depth = %d after all streams ended, want 0", depth)
+	}
+}
+
+// unackedShipments 返回会话中尚未确认的批次数，会话不存在时 found 为 false
+func unackedShipments(srv *server, id string) (n int, found bool) {
+	srv.sessions.mu.Lock()
+	sess, found := srv.sessions.sessions[id]
+	srv.sessions.mu.Unlock()
+	if !found {
+		return 0, false
+	}
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return len(sess.unacked), true
+}
+
+// waitForSessionGone 等待服务端删除会话，流结束后服务端才在延迟调用中处理会话
+func waitForSessionGone(t *testing.T, srv *server, id string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if _, found := unackedShipments(srv, id); !found {
+			return
+		}
+	}
+	t.Error("session still kept after the stream ended with everything acked")
+}
+
+// openSession 打开会话 id 的流，返回流和服务端告知的下一个序号
+func openSession(t *testing.T, client pb.OrderManagementClient, id string, resumeAfter uint64) (pb.OrderManagement_ProcessOrdersClient, uint64) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	ctx = metadata.AppendToOutgoingContext(ctx, sessionIdKey, id, resumeAfterKey, strconv.FormatUint(resumeAfter, 10))
+	stream, err := client.ProcessOrders(ctx)
+	if err != nil {
+		t.Fatalf("ProcessOrders: %v", err)
+	}
+	header, err := stream.Header()
+	if err != nil {
+		t.Fatalf("Header: %v", err)
+	}
+	next, err := strconv.ParseUint(header.Get(nextSeqKey)[0], 10, 64)
+	if err != nil {
+		t.Fatalf("invalid %s: %v", nextSeqKey, err)
+	}
+	return stream, next
+}
+
+// recvSeqs 接收批次直到收到 n 个序号
+func recvSeqs(t *testing.T, stream pb.OrderManagement_ProcessOrdersClient, n int) {
+	t.Helper()
+	for got := 0; got < n; {
+		shipment, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv after %d seqs: %v", got, err)
+		}
+		got += len(shipment.GetSeqs())
+	}
+}
+
+// 客户端半关闭后服务端发送的最后批次还没有被确认，会话保留到客户端续传并确认为止
+func TestSessionKeptUntilFinalShipmentsAcked(t *testing.T) {
+	srv, client := startOrderServer(t)
+	orderIds := []string{"102", "103"} // 不足一个批次，只在 EOF 时发送
+	stream, _ := openSession(t, client, t.Name(), 0)
+	for i, id := range orderIds {
+		if err := stream.Send(&pb.ProcessOrderRequest{Seq: uint64(i + 1), OrderId: id}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	stream.CloseSend()
+	recvSeqs(t, stream, len(orderIds))
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("Recv = %v, want EOF", err)
+	}
+	if n, found := unackedShipments(srv, t.Name()); !found || n == 0 {
+		t.Fatalf("session after EOF: found %v with %d unacked shipment(s), want it kept with the final shipments", found, n)
+	}
+
+	// 假设客户端没有收到最后的批次：不确认地续传，服务端重发它们，且不要求重发订单
+	stream, next := openSession(t, client, t.Name(), 0)
+	if next != uint64(len(orderIds))+1 {
+		t.Errorf("resumed at seq %d, want %d", next, len(orderIds)+1)
+	}
+	recvSeqs(t, stream, len(orderIds))
+	stream.CloseSend()
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("Recv = %v, want EOF", err)
+	}
+
+	// 确认所有订单后会话结束
+	stream, _ = openSession(t, client, t.Name(), uint64(len(orderIds)))
+	stream.CloseSend()
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("Recv = %v, want EOF", err)
+	}
+	waitForSessionGone(t, srv, t.Name())
+}
+
+// 客户端发送完订单后通过心跳确认之后收到的批次，服务端不再保留它们
+func TestHeartbeatAck(t *testing.T) {
+	srv, client := startOrderServer(t)
+	stream, _ := openSession(t, client, t.Name(), 0)
+	for seq := uint64(1); seq <= orderBatchSize; seq++ {
+		if err := stream.Send(&pb.ProcessOrderRequest{Seq: seq, OrderId: "102"}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	recvSeqs(t, stream, orderBatchSize)
+	if n, _ := unackedShipments(srv, t.Name()); n == 0 {
+		t.Fatal("no unacked shipment before the heartbeat ack")
+	}
+	if err := stream.Send(&pb.ProcessOrderRequest{Heartbeat: true, Ack: orderBatchSize}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for n, _ := unackedShipments(srv, t.Name()); n > 0; n, _ = unackedShipments(srv, t.Name()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d shipment(s) still unacked after the heartbeat ack", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	stream.CloseSend()
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("Recv = %v, want EOF", err)
+	}
+	waitForSessionGone(t, srv, t.Name())
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log"
+	pb "ordermgt/server/ecommerce"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ProcessOrders 的断点续传：客户端在元数据 x-session-id 中指定会话 ID，重连时在 x-resume-after 中给出
+// 已确认的序号（所有不大于它的订单都已出现在收到的批次中）。服务端为每个会话保存检查点：
+// 已收到的最大序号、尚未发送的批次和已发送但客户端尚未确认的批次。
+// 流建立后服务端先在响应头 x-next-seq 中告知客户端从哪个序号继续发送，再重发客户端可能没有收到的批次；
+// 重复的序号被忽略，因此重连后订单不会重复出现在批次中。客户端发送完订单后通过心跳中的 ack 确认之后收到的批次；
+// 流正常结束时如果还有未确认的批次，会话同样保留 sessionTTL。没有会话 ID 的流不能续传
+const (
+	sessionIdKey   = "x-session-id"
+	resumeAfterKey = "x-resume-after"
+	nextSeqKey     = "x-next-seq"
+	sessionTTL     = time.Minute // 没有活动流的会话保留的时间
+)
+
+// 故障注入：每个流收到指定数量的订单后以 Unavailable 断开，用于验证客户端的续传
+var breakEvery = flag.Int("break-every", 0, "abort each ProcessOrders stream with Unavailable after this many orders, 0 disables")
+
+// session 是一个 ProcessOrders 会话的检查点，同一时间只有一个流使用
+type session struct {
+	id          string     // 为空表示不能续传
+	mu          sync.Mutex // 流的处理函数和工作协程同时访问以下状态
+	received    uint64     // 已收到的最大序号
+	batchMarker int
+	pending     map[string]*pb.CombinedShipment // 目的地 -> 尚未发送的批次
+	unacked     []*pb.CombinedShipment          // 已发送但客户端尚未确认的批次
+	feed        *orderFeed                      // 批次发出时发布订单的状态变更
+
+	active bool
+	expiry *time.Timer
+}
+
+func newSession(id string, feed *orderFeed) *session {
+	return &session{id: id, batchMarker: 1, pending: make(map[string]*pb.CombinedShipment), feed: feed}
+}
+
+// accept 检查请求的序号并返回订单的序号，duplicate 为 true 表示该订单已经收到过。
+// 订单交给工作协程之后才调用 commit 推进已收到的序号，否则续传时客户端不会重发它
+func (s *session) accept(req *pb.ProcessOrderRequest) (seq uint64, duplicate bool, err error) {
+	s.confirm(req.GetAck())
+	seq = req.GetSeq()
+	if seq == 0 {
+		seq = s.received + 1
+	}
+	switch {
+	case seq <= s.received:
+		return seq, true, nil
+	case seq > s.received+1:
+		return 0, false, status.Errorf(codes.InvalidArgument, "expected seq %d, got %d", s.received+1, seq)
+	}
+	return seq, false, nil
+}
+
+// commit 记录序号为 seq 的订单已被接受
+func (s *session) commit(seq uint64) {
+	s.received = seq
+}
+
+// add 将订单加入目的地对应的批次，返回 true 表示达到批次大小，需要发送
+func (s *session) add(seq uint64, ord pb.Order) bool {
+	shipment, found := s.pending[ord.Destination]
+	if !found {
+		shipment = &pb.CombinedShipment{Id: "cmb - " + ord.Description, Status: "Processed!"}
+		s.pending[ord.Destination] = shipment
+	}
+	shipment.OrdersList = append(shipment.OrdersList, &ord)
+	shipment.Seqs = append(shipment.Seqs, seq)
+	log.Print(len(shipment.OrdersList), shipment.GetId())
+
+	if s.batchMarker == orderBatchSize {
+		s.batchMarker = 1
+		return true
+	}
+	s.batchMarker++
+	return false
+}
+
+// flush 发送所有尚未发送的批次，调用方不能持有 s.mu。批次在持锁时记入 unacked，发送失败时由续传的流重发；
+// 发送时不持锁，客户端不再读取、发送阻塞时处理函数仍然可以检查序号并发现客户端失联
+func (s *session) flush(stream pb.OrderManagement_ProcessOrdersServer) error {
+	s.mu.Lock()
+	shipments := make([]*pb.CombinedShipment, 0, len(s.pending))
+	for destination, comb := range s.pending {
+		delete(s.pending, destination)
+		if s.id != "" {
+			s.unacked = append(s.unacked, comb)
+		}
+		shipments = append(shipments, comb)
+	}
+	s.mu.Unlock()
+	for _, comb := range shipments {
+		log.Printf("Shipping : %v -> %v", comb.Id, len(comb.OrdersList))
+		if err := stream.Send(comb); err != nil {
+			return err
+		}
+		for _, ord := range comb.OrdersList {
+			s.feed.publish(pb.OrderEvent_STATUS_CHANGED, *ord, comb.Status, comb.Id)
+		}
+	}
+	return nil
+}
+
+// confirm 删除客户端已经确认的批次，即所有订单的序号都不大于 ack 的批次
+func (s *session) confirm(ack uint64) {
+	kept := s.unacked[:0]
+	for _, comb := range s.unacked {
+		for _, seq := range comb.Seqs {
+			if seq > ack {
+				kept = append(kept, comb)
+				break
+			}
+		}
+	}
+	for i := len(kept); i < len(s.unacked); i++ {
+		s.unacked[i] = nil
+	}
+	s.unacked = kept
+}
+
+// resume 告知客户端下一个序号，并重发尚未确认的批次
+func (s *session) resume(stream pb.OrderManagement_ProcessOrdersServer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := stream.SendHeader(metadata.Pairs(nextSeqKey, strconv.FormatUint(s.received+1, 10))); err != nil {
+		return err
+	}
+	for _, comb := range s.unacked {
+		log.Printf("Replaying : %v -> %v %v", comb.Id, len(comb.OrdersList), comb.Seqs)
+		if err := stream.Send(comb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionStore 保存可续传的会话
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+	feed     *orderFeed
+}
+
+func newSessionStore(feed *orderFeed) *sessionStore {
+	return &sessionStore{sessions: make(map[string]*session), feed: feed}
+}
+
+// attach 取得流元数据中指定的会话，不存在时创建；会话已有活动的流时返回 Aborted
+func (st *sessionStore) attach(ctx context.Context) (*session, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ids := md.Get(sessionIdKey)
+	if len(ids) == 0 || ids[0] == "" {
+		return newSession("", st.feed), nil
+	}
+	var resumeAfter uint64
+	if v := md.Get(resumeAfterKey); len(v) > 0 {
+		n, err := strconv.ParseUint(v[0], 10, 64)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid %s: %v", resumeAfterKey, err)
+		}
+		resumeAfter = n
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	s, found := st.sessions[ids[0]]
+	if !found {
+		s = newSession(ids[0], st.feed)
+		st.sessions[s.id] = s
+	} else if s.active {
+		// 服务端可能还没有发现上一个流已经断开，客户端稍后重试即可
+		return nil, status.Errorf(codes.Aborted, "session %s already has an active stream", s.id)
+	}
+	if s.expiry != nil {
+		s.expiry.Stop()
+	}
+	s.active = true
+	// 上一个流的工作协程可能仍在发送批次
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.confirm(resumeAfter)
+	if found {
+		log.Printf("Resuming session %s after seq %d, next seq %d, %d shipment(s) to replay", s.id, resumeAfter, s.received+1, len(s.unacked))
+	}
+	return s, nil
+}
+
+// detach 在流结束时调用，finished 为 true 表示会话已正常结束且所有批次都已确认，否则保留 sessionTTL 等待客户端续传
+func (st *sessionStore) detach(s *session, finished bool) {
+	if s.id == "" {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	s.active = false
+	if finished {
+		delete(st.sessions, s.id)
+		return
+	}
+	s.expiry = time.AfterFunc(sessionTTL, func() {
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		if !s.active && st.sessions[s.id] == s {
+			delete(st.sessions, s.id)
+			log.Printf("Session %s expired", s.id)
+		}
+	})
+}
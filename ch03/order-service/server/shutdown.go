@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/status"
+	"log"
+	"net"
+	pb "ordermgt/server/ecommerce"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// 进程退出码
+const (
+	exitOK           = 0 // 所有 RPC 在排空期限内结束
+	exitServeFailed  = 1 // Serve 返回错误
+	exitDrainTimeout = 2 // 排空超时（或再次收到信号），强制关闭了剩余的连接
+)
+
+var drainTimeout = flag.Duration("drain-timeout", 10*time.Second, "how long to wait for in-flight RPCs on SIGINT/SIGTERM before forcing the server to stop")
+
+// shuttingDown 在收到退出信号后关闭，ProcessOrders 据此把缓存的订单批次发给客户端并结束流
+var shuttingDown = make(chan struct{})
+
+// serve 运行 s 直到收到 SIGINT/SIGTERM，然后优雅退出：
+// 健康状态设为 NOT_SERVING，执行 onShutdown，通知 ProcessOrders 发送缓存的批次，
+// 调用 GracefulStop 等待正在处理的 RPC 结束，超过 drainTimeout 或再次收到信号时调用 Stop。返回进程退出码
+func serve(s *grpc.Server, lis net.Listener, healthServer *health.Server, onShutdown ...func()) int {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- s.Serve(lis) }()
+	select {
+	case err := <-serveErr:
+		log.Printf("failed to serve: %v", err)
+		return exitServeFailed
+	case sig := <-sigs:
+		log.Printf("received %v, draining for up to %v", sig, *drainTimeout)
+	}
+
+	healthServer.Shutdown() // 所有服务报告 NOT_SERVING，之后的状态更新被忽略
+	for _, f := range onShutdown {
+		f()
+	}
+	close(shuttingDown)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		log.Println("server stopped gracefully")
+		return exitOK
+	case <-time.After(*drainTimeout):
+		log.Printf("drain timed out after %v, forcing stop", *drainTimeout)
+	case sig := <-sigs:
+		log.Printf("received %v again, forcing stop", sig)
+	}
+	s.Stop()
+	return exitDrainTimeout
+}
+
+// recvResult 是 ProcessOrders 流中收到的一条消息
+type recvResult struct {
+	req *pb.ProcessOrderRequest
+	err error
+}
+
+// recvOrderIds 在单独的协程中接收订单并放入容量为 size 的队列，使 ProcessOrders 在等待客户端时也能响应退出信号。
+// 队列满时协程停止调用 Recv，直到处理函数取走订单。
+// 收到错误（包括 io.EOF）后协程结束；处理函数返回后流的上下文被取消，协程随之退出
+func recvOrderIds(stream pb.OrderManagement_ProcessOrdersServer, size int) <-chan recvResult {
+	results := make(chan recvResult, size)
+	go func() {
+		defer close(results)
+		for {
+			req, err := stream.Recv()
+			if err == nil && !req.GetHeartbeat() {
+				processStats.received.Add(1)
+			}
+			if len(results) == cap(results) {
+				processStats.stalls.Add(1)
+			}
+			processStats.queued.Add(1)
+			select {
+			case results <- recvResult{req: req, err: err}:
+				processStats.observeDepth(len(results))
+			case <-stream.Context().Done():
+				processStats.queued.Add(-1)
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return results
+}
+
+// drainOrderIds 在处理函数返回后丢弃队列中剩余的消息，使队列长度指标归零。
+// 处理函数返回后流的上下文被取消，接收协程随之结束并关闭队列
+func drainOrderIds(results <-chan recvResult) {
+	go func() {
+		for range results {
+			processStats.queued.Add(-1)
+		}
+	}()
+}
+
+// flushShipments 在服务器退出前发送缓存的批次，并以 Unavailable 结束流，
+// 客户端需要在其他实例上重新提交尚未出现在任何批次中的订单
+func flushShipments(stream pb.OrderManagement_ProcessOrdersServer, sess *session, workers *shipmentWorkers) error {
+	if err := workers.wait(); err != nil {
+		return err
+	}
+	sess.mu.Lock()
+	log.Printf("Flushing %d pending shipment(s)", len(sess.pending))
+	sess.mu.Unlock()
+	if err := sess.flush(stream); err != nil {
+		return err
+	}
+	return status.Error(codes.Unavailable, "server is shutting down")
+}
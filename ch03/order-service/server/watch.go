@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	pb "ordermgt/server/ecommerce"
+	"sync"
+)
+
+var (
+	watchBuffer  = flag.Int("watch-buffer", 64, "events buffered per WatchOrders subscriber, slower subscribers are disconnected with ResourceExhausted")
+	watchHistory = flag.Int("watch-history", 1000, "number of past events kept for WatchOrders catch-up")
+)
+
+// orderFeed 为订单的每次变更分配全局递增的版本号，保存最近的事件用于补发，并分发给所有订阅者
+type orderFeed struct {
+	mu          sync.Mutex
+	revision    uint64
+	history     []*pb.OrderEvent // 最近的事件，按版本号递增
+	maxHistory  int
+	subscribers map[*subscriber]bool
+	bufferSize  int
+}
+
+// subscriber 是一个 WatchOrders 流
+type subscriber struct {
+	req     *pb.WatchOrdersRequest
+	events  chan *pb.OrderEvent
+	dropped chan struct{} // 缓冲区满时关闭，流以 ResourceExhausted 结束
+}
+
+func newOrderFeed(bufferSize, maxHistory int) *orderFeed {
+	return &orderFeed{subscribers: make(map[*subscriber]bool), bufferSize: bufferSize, maxHistory: maxHistory}
+}
+
+// publish 记录一次变更并发送给订阅了该事件的订阅者，不会因为订阅者处理得慢而阻塞
+func (f *orderFeed) publish(typ pb.OrderEvent_Type, order pb.Order, orderStatus, shipmentId string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.revision++
+	event := &pb.OrderEvent{Revision: f.revision, Type: typ, Order: &order, Status: orderStatus, ShipmentId: shipmentId}
+	f.history = append(f.history, event)
+	if len(f.history) > f.maxHistory {
+		f.history[0] = nil
+		f.history = f.history[1:]
+	}
+	for sub := range f.subscribers {
+		if !matches(sub.req, event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			// 订阅者跟不上变更的速度，断开它而不是阻塞变更或无限缓存
+			delete(f.subscribers, sub)
+			close(sub.dropped)
+		}
+	}
+}
+
+// subscribe 注册订阅者并返回 startRevision 之后需要补发的历史事件；
+// 补发和注册在同一把锁内完成，因此补发的事件与之后收到的事件之间既不重复也不遗漏
+func (f *orderFeed) subscribe(req *pb.WatchOrdersRequest) (*subscriber, []*pb.OrderEvent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var backlog []*pb.OrderEvent
+	if start := req.GetStartRevision(); start > 0 && start <= f.revision {
+		if len(f.history) == 0 || start < f.history[0].GetRevision() {
+			return nil, nil, status.Errorf(codes.OutOfRange, "revision %d has been compacted, oldest available revision is %d", start, f.revision-uint64(len(f.history))+1)
+		}
+		for _, event := range f.history[start-f.history[0].GetRevision():] {
+			if matches(req, event) {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+	sub := &subscriber{req: req, events: make(chan *pb.OrderEvent, f.bufferSize), dropped: make(chan struct{})}
+	f.subscribers[sub] = true
+	return sub, backlog, nil
+}
+
+func (f *orderFeed) unsubscribe(sub *subscriber) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.subscribers, sub)
+}
+
+// matches 判断事件是否满足订阅条件
+func matches(req *pb.WatchOrdersRequest, event *pb.OrderEvent) bool {
+	if event.GetRevision() < req.GetStartRevision() {
+		return false
+	}
+	if ids := req.GetOrderIds(); len(ids) > 0 && !containsString(ids, event.GetOrder().GetId()) {
+		return false
+	}
+	if dest := req.GetDestination(); dest != "" && dest != event.GetOrder().GetDestination() {
+		return false
+	}
+	if types := req.GetTypes(); len(types) > 0 {
+		for _, typ := range types {
+			if typ == event.GetType() {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *server) WatchOrders(req *pb.WatchOrdersRequest, stream pb.OrderManagement_WatchOrdersServer) error {
+	sub, backlog, err := s.feed.subscribe(req)
+	if err != nil {
+		return err
+	}
+	defer s.feed.unsubscribe(sub)
+	log.Printf("WatchOrders: %v, %d event(s) to catch up", req, len(backlog))
+
+	for _, event := range backlog {
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case event := <-sub.events:
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		case <-sub.dropped:
+			log.Printf("WatchOrders: dropping slow subscriber %v", req)
+			return status.Errorf(codes.ResourceExhausted, "subscriber too slow: more than %d events pending", cap(sub.events))
+		case <-shuttingDown:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+	}
+}
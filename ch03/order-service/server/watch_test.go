@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "ordermgt/server/ecommerce"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startWatchServer 同 startOrderServer，使用指定的订阅者缓冲区大小和历史事件数量。
+// 测试结束时删除 ID 以 w 开头的订单，重复运行时事件类型不变
+func startWatchServer(t *testing.T, buffer, history int) (*server, pb.OrderManagementClient) {
+	t.Cleanup(func() {
+		orderMu.Lock()
+		defer orderMu.Unlock()
+		for id := range orderMap {
+			if strings.HasPrefix(id, "w") {
+				delete(orderMap, id)
+			}
+		}
+	})
+	defer func(b, h int) { *watchBuffer, *watchHistory = b, h }(*watchBuffer, *watchHistory)
+	*watchBuffer, *watchHistory = buffer, history
+	return startOrderServer(t)
+}
+
+func watch(t *testing.T, client pb.OrderManagementClient, req *pb.WatchOrdersRequest) pb.OrderManagement_WatchOrdersClient {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	stream, err := client.WatchOrders(ctx, req)
+	if err != nil {
+		t.Fatalf("WatchOrders: %v", err)
+	}
+	return stream
+}
+
+// recvRevisions 接收 n 个事件并返回它们的版本号
+func recvRevisions(t *testing.T, stream pb.OrderManagement_WatchOrdersClient, n int) []uint64 {
+	t.Helper()
+	revisions := make([]uint64, 0, n)
+	for len(revisions) < n {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv after %v: %v", revisions, err)
+		}
+		revisions = append(revisions, event.GetRevision())
+	}
+	return revisions
+}
+
+func equalRevisions(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// 从 startRevision 开始订阅时先补发历史事件，之后的变更接着发送，既不重复也不遗漏
+func TestWatchCatchUp(t *testing.T) {
+	srv, client := startWatchServer(t, 64, 1000)
+	for _, id := range []string{"w101", "w102", "w103"} {
+		srv.putOrder(pb.Order{Id: id, Destination: "San Jose, CA"})
+	}
+	stream := watch(t, client, &pb.WatchOrdersRequest{StartRevision: 2})
+	if got := recvRevisions(t, stream, 2); !equalRevisions(got, []uint64{2, 3}) {
+		t.Fatalf("catch-up revisions = %v, want [2 3]", got)
+	}
+	srv.putOrder(pb.Order{Id: "w101", Destination: "Mountain View, CA"})
+	srv.putOrder(pb.Order{Id: "w104", Destination: "San Jose, CA"})
+	if got := recvRevisions(t, stream, 2); !equalRevisions(got, []uint64{4, 5}) {
+		t.Fatalf("live revisions = %v, want [4 5]", got)
+	}
+}
+
+// startRevision 早于保存的历史时以 OutOfRange 结束，仍在历史中的版本号可以正常补发
+func TestWatchCompacted(t *testing.T) {
+	srv, client := startWatchServer(t, 64, 2)
+	for _, id := range []string{"w201", "w202", "w203", "w204", "w205"} {
+		srv.putOrder(pb.Order{Id: id})
+	}
+	_, err := watch(t, client, &pb.WatchOrdersRequest{StartRevision: 3}).Recv()
+	if status.Code(err) != codes.OutOfRange {
+		t.Fatalf("Recv from compacted revision 3 = %v, want OutOfRange", err)
+	}
+	stream := watch(t, client, &pb.WatchOrdersRequest{StartRevision: 4})
+	if got := recvRevisions(t, stream, 2); !equalRevisions(got, []uint64{4, 5}) {
+		t.Fatalf("revisions = %v, want [4 5]", got)
+	}
+}
+
+// 按订单 ID、目的地和事件类型过滤，补发的历史事件和之后的变更使用相同的条件
+func TestWatchFilters(t *testing.T) {
+	srv, client := startWatchServer(t, 64, 1000)
+	srv.putOrder(pb.Order{Id: "w301", Destination: "San Jose, CA"})      // 1 CREATED
+	srv.putOrder(pb.Order{Id: "w302", Destination: "Mountain View, CA"}) // 2 CREATED
+	srv.putOrder(pb.Order{Id: "w301", Destination: "San Jose, CA"})      // 3 UPDATED
+	srv.putOrder(pb.Order{Id: "w303", Destination: "San Jose, CA"})      // 4 CREATED
+
+	tests := []struct {
+		name string
+		req  *pb.WatchOrdersRequest
+		want []uint64
+	}{
+		{"OrderIds", &pb.WatchOrdersRequest{OrderIds: []string{"w301", "w302"}}, []uint64{1, 2, 3, 5, 6}},
+		{"Destination", &pb.WatchOrdersRequest{Destination: "San Jose, CA"}, []uint64{1, 3, 4, 6, 7, 8}},
+		{"Types", &pb.WatchOrdersRequest{Types: []pb.OrderEvent_Type{pb.OrderEvent_UPDATED}}, []uint64{3, 5, 6, 7}},
+		{"Combined", &pb.WatchOrdersRequest{OrderIds: []string{"w301", "w303"}, Destination: "San Jose, CA", Types: []pb.OrderEvent_Type{pb.OrderEvent_UPDATED}}, []uint64{3, 6, 7}},
+	}
+	streams := make([]pb.OrderManagement_WatchOrdersClient, len(tests))
+	for i, tt := range tests {
+		tt.req.StartRevision = 1
+		streams[i] = watch(t, client, tt.req)
+		// 补发的事件都在第 4 个版本之前，收到它们说明订阅已经注册
+		var backlog int
+		for _, rev := range tt.want {
+			if rev <= 4 {
+				backlog++
+			}
+		}
+		if got := recvRevisions(t, streams[i], backlog); !equalRevisions(got, tt.want[:backlog]) {
+			t.Fatalf("%s: catch-up revisions = %v, want %v", tt.name, got, tt.want[:backlog])
+		}
+	}
+	srv.putOrder(pb.Order{Id: "w302", Destination: "Mountain View, CA"}) // 5 UPDATED
+	srv.putOrder(pb.Order{Id: "w301", Destination: "San Jose, CA"})      // 6 UPDATED
+	srv.putOrder(pb.Order{Id: "w303", Destination: "San Jose, CA"})      // 7 UPDATED
+	srv.putOrder(pb.Order{Id: "w304", Destination: "San Jose, CA"})      // 8 CREATED
+
+	for i, tt := range tests {
+		var live []uint64
+		for _, rev := range tt.want {
+			if rev > 4 {
+				live = append(live, rev)
+			}
+		}
+		if got := recvRevisions(t, streams[i], len(live)); !equalRevisions(got, live) {
+			t.Errorf("%s: live revisions = %v, want %v", tt.name, got, live)
+		}
+	}
+}
+
+// 订阅者处理得比变更慢、缓冲区满时，流以 ResourceExhausted 结束；之前收到的事件仍然连续
+func TestWatchSlowSubscriber(t *testing.T) {
+	srv, client := startWatchServer(t, 4, 1000)
+	order := pb.Order{Id: "w401", Items: []string{"Google Home Mini"}, Destination: "San Jose, CA"}
+	srv.feed.publish(pb.OrderEvent_CREATED, order, "", "")
+	// 补发的第一个事件证明订阅已经注册
+	stream := watch(t, client, &pb.WatchOrdersRequest{StartRevision: 1})
+	if got := recvRevisions(t, stream, 1); got[0] != 1 {
+		t.Fatalf("first revision = %d, want 1", got[0])
+	}
+	// 客户端暂不读取，流量控制窗口用尽后服务端的发送阻塞，订阅者的缓冲区随之填满
+	for i := 0; i < 10000; i++ {
+		srv.feed.publish(pb.OrderEvent_UPDATED, order, "", "")
+	}
+	for want := uint64(2); ; want++ {
+		event, err := stream.Recv()
+		if err != nil {
+			if status.Code(err) != codes.ResourceExhausted {
+				t.Fatalf("Recv after revision %d = %v, want ResourceExhausted", want-1, err)
+			}
+			if want > 10001 {
+				t.Fatal("received every event, the subscriber was never dropped")
+			}
+			return
+		}
+		if event.GetRevision() != want {
+			t.Fatalf("revision = %d, want %d", event.GetRevision(), want)
+		}
+	}
+}
@@ -67,6 +67,10 @@ func asyncClientBidirectionalRPC(streamProcOrder pb.OrderManagement_ProcessOrder
 		if errProcOrder == io.EOF {
 			break
 		}
+		if errProcOrder != nil {
+			log.Printf("ProcessOrders stopped: %v", errProcOrder) // 取消后服务端返回 Canceled
+			break
+		}
 		log.Print("Combined shipment : ", combinedShipment.OrdersList)
 	}
 	<-c
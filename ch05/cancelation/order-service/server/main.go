@@ -2,13 +2,19 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"flag"
 	"github.com/golang/protobuf/ptypes/wrappers"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"graceful"
 	"io"
 	"log"
 	"net"
 	pb "ordermgt/server/ecommerce"
+	"os"
 	"strings"
 	"time"
 )
@@ -18,32 +24,60 @@ const (
 	orderBatchSize = 3
 )
 
-var orderMap = make(map[string]pb.Order)
+var (
+	addOrderDelay = flag.Duration("add-delay", 5*time.Second, "simulated processing time of AddOrder")
+	storeDelay    = flag.Duration("store-delay", 0, "simulated latency of every order store operation")
+)
 
+// 所有处理函数都使用 RPC 的上下文：客户端取消或超过截止时间后立即停止，
+// 并返回 Canceled/DeadlineExceeded 状态
 type server struct {
-	orderMap map[string]*pb.Order
+	store    orderStore
+	addDelay time.Duration
 }
 
-func (s *server) AddOrder(ctx context.Context, orderReq *pb.Order) (*wrappers.StringValue, error) {
-	orderMap[orderReq.Id] = *orderReq
-
-	sleepDuration := 5
-	log.Println("Sleeping for :", sleepDuration, "s")
-	time.Sleep(time.Duration(sleepDuration) * time.Second)
+// rpcError 把上下文的错误转换为对应的 gRPC 状态，其他错误原样返回
+func rpcError(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	return err
+}
 
-	if ctx.Err() == context.DeadlineExceeded {
-		log.Printf("RPC has reached deadline exceeded state : %s ", ctx.Err())
-		return nil, ctx.Err()
+func (s *server) AddOrder(ctx context.Context, orderReq *pb.Order) (*wrappers.StringValue, error) {
+	log.Println("Processing for :", s.addDelay)
+	if err := sleep(ctx, s.addDelay); err != nil {
+		log.Printf("RPC has reached %s state before order %s is added", err, orderReq.Id)
+		return nil, rpcError(err)
+	}
+	if err := s.store.Put(ctx, *orderReq); err != nil {
+		return nil, rpcError(err)
 	}
 	log.Println("Order : ", orderReq.Id, " -> Added")
 	return &wrappers.StringValue{Value: "Order Added: " + orderReq.Id}, nil
 }
 
 func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) error {
+	ctx := stream.Context()
 	batchMarker := 1
 	var combinedShipmentMap = make(map[string]pb.CombinedShipment)
+	orderIds := recvOrderIds(stream)
 	for {
-		orderId, err := stream.Recv()
+		var r graceful.Received
+		var ok bool
+		select {
+		case r, ok = <-orderIds:
+			if !ok { // 流的上下文已取消
+				return rpcError(ctx.Err())
+			}
+		case <-shuttingDown:
+			return flushShipments(stream, combinedShipmentMap, orderIds, func(id string) (pb.Order, error) { // 服务器退出前发送已缓存的批次
+				ord, _, err := s.store.Get(ctx, id)
+				return ord, rpcError(err)
+			})
+		}
+		orderId, _ := r.Msg.(*wrappers.StringValue)
+		err := r.Err // 客户端取消后 Recv 会立即返回错误
 		log.Printf("Reading Proc order ; %s", orderId)
 		if err == io.EOF {
 			log.Printf("EOF : %s", orderId)
@@ -56,19 +90,25 @@ func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) er
 		}
 		if err != nil {
 			log.Println(err)
+			if ctx.Err() != nil {
+				return rpcError(ctx.Err())
+			}
 			return err
 		}
 
-		destination := orderMap[orderId.GetValue()].Destination
+		ord, _, err := s.store.Get(ctx, orderId.GetValue())
+		if err != nil {
+			log.Printf("Processing order %s stopped: %v", orderId.GetValue(), err)
+			return rpcError(err)
+		}
+		destination := ord.Destination
 		shipment, found := combinedShipmentMap[destination]
 
 		if found {
-			ord := orderMap[orderId.GetValue()]
 			shipment.OrdersList = append(shipment.OrdersList, &ord)
 			combinedShipmentMap[destination] = shipment
 		} else {
-			comShip := pb.CombinedShipment{Id: "cmb - " + (orderMap[orderId.GetValue()].Description), Status: "Processed!"}
-			ord := orderMap[orderId.GetValue()]
+			comShip := pb.CombinedShipment{Id: "cmb - " + (ord.Description), Status: "Processed!"}
 			comShip.OrdersList = append(shipment.OrdersList, &ord)
 			combinedShipmentMap[destination] = comShip
 			log.Print(len(comShip.OrdersList), comShip.GetId())
@@ -76,6 +116,9 @@ func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) er
 
 		if batchMarker == orderBatchSize {
 			for _, comb := range combinedShipmentMap {
+				if err := ctx.Err(); err != nil {
+					return rpcError(err)
+				}
 				log.Printf("Shipping : %v -> %v", comb.Id, len(comb.OrdersList))
 				if err := stream.Send(&comb); err != nil {
 					return err
@@ -90,36 +133,55 @@ func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) er
 }
 
 func (s *server) GetOrder(ctx context.Context, orderId *wrappers.StringValue) (*pb.Order, error) {
-	ord := orderMap[orderId.Value]
+	ord, _, err := s.store.Get(ctx, orderId.Value)
+	if err != nil {
+		return nil, rpcError(err)
+	}
 	return &ord, nil
 }
 
 func (s *server) SearchOrders(searchQuery *wrappers.StringValue, strem pb.OrderManagement_SearchOrdersServer) error {
-	for key, order := range orderMap {
-		log.Print(key, order)
+	ctx := strem.Context()
+	err := s.store.Range(ctx, func(order pb.Order) error {
+		log.Print(order.Id, order)
 		for _, itemStr := range order.Items {
 			log.Print(itemStr)
 			if strings.Contains(itemStr, searchQuery.Value) {
-				err := strem.Send(&order) // 在流中发送匹配的订单
-				if err != nil {
-					return fmt.Errorf("error sending message to stream: %v", err)
+				if err := strem.Send(&order); err != nil { // 在流中发送匹配的订单
+					return err
 				}
-				log.Print("Matching Order Found: ", key)
+				log.Print("Matching Order Found: ", order.Id)
 				break
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Search stopped: %v", err)
+		return rpcError(err)
 	}
 	return nil
 }
 
 func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) error {
+	ctx := stream.Context()
 	ordersStr := "Update Order IDs : "
 	for {
 		order, err := stream.Recv() // 从客户端流中读取消息
 		if err == io.EOF {          // 检查流是否已经结束
 			return stream.SendAndClose(&wrappers.StringValue{Value: "Orders processed " + ordersStr}) // 服务端发送响应
 		}
-		orderMap[order.Id] = *order
+		if err != nil {
+			log.Println(err)
+			if ctx.Err() != nil {
+				return rpcError(ctx.Err())
+			}
+			return err
+		}
+		if err := s.store.Put(ctx, *order); err != nil {
+			log.Printf("Updating order %s stopped: %v", order.Id, err)
+			return rpcError(err)
+		}
 
 		log.Println("Order ID ", order.Id, ": Updated")
 		ordersStr += order.Id + ","
@@ -127,22 +189,33 @@ func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) erro
 }
 
 func main() {
-	initSampleData()
+	flag.Parse()
 	lis, err := net.Listen("tcp", port)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 	s := grpc.NewServer()
-	pb.RegisterOrderManagementServer(s, &server{})
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(s, healthServer)
+	pb.RegisterOrderManagementServer(s, &server{store: newOrderStore(*storeDelay), addDelay: *addOrderDelay})
+	os.Exit(serve(s, lis, healthServer))
+}
+
+// newOrderStore 返回包含示例数据的订单存储，delay 大于 0 时每次操作都会变慢
+func newOrderStore(delay time.Duration) orderStore {
+	var store orderStore = newMemoryStore()
+	initSampleData(store)
+	if delay > 0 {
+		store = &slowStore{orderStore: store, delay: delay}
 	}
+	return store
 }
 
-func initSampleData() {
-	orderMap["102"] = pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}, Destination: "Mountain View, CA", Price: 1800.00}
-	orderMap["103"] = pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA", Price: 400.00}
-	orderMap["104"] = pb.Order{Id: "104", Items: []string{"Google Home Mini", "Google Nest Hub"}, Destination: "Mountain View, CA", Price: 400.00}
-	orderMap["105"] = pb.Order{Id: "105", Items: []string{"Amazon Echo"}, Destination: "San Jose, CA", Price: 30.00}
-	orderMap["106"] = pb.Order{Id: "106", Items: []string{"Amazon Echo", "Apple iPhone XS"}, Destination: "Mountain View, CA", Price: 300.00}
+func initSampleData(store orderStore) {
+	ctx := context.Background()
+	store.Put(ctx, pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}, Destination: "Mountain View, CA", Price: 1800.00})
+	store.Put(ctx, pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA", Price: 400.00})
+	store.Put(ctx, pb.Order{Id: "104", Items: []string{"Google Home Mini", "Google Nest Hub"}, Destination: "Mountain View, CA", Price: 400.00})
+	store.Put(ctx, pb.Order{Id: "105", Items: []string{"Amazon Echo"}, Destination: "San Jose, CA", Price: 30.00})
+	store.Put(ctx, pb.Order{Id: "106", Items: []string{"Amazon Echo", "Apple iPhone XS"}, Destination: "Mountain View, CA", Price: 300.00})
 }
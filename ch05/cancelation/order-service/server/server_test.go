@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net"
+	pb "ordermgt/server/ecommerce"
+	"testing"
+	"time"
+)
+
+// 在测试进程中启动使用慢速存储的服务端，每个用例在存储操作进行中取消 RPC，
+// 检查处理函数是否在 cancelTolerance 内返回 Canceled/DeadlineExceeded
+const (
+	testStoreDelay  = time.Second
+	cancelAfter     = 300 * time.Millisecond
+	cancelTolerance = 200 * time.Millisecond
+)
+
+// handlerExit 记录处理函数返回的时间和状态
+type handlerExit struct {
+	method string
+	code   codes.Code
+	at     time.Time
+}
+
+// startServer 启动服务端，处理函数每次返回时向 exits 发送记录
+func startServer(t *testing.T) (pb.OrderManagementClient, <-chan handlerExit) {
+	t.Helper()
+	exits := make(chan handlerExit, 16)
+	record := func(method string, err error) {
+		exits <- handlerExit{method: method, code: status.Code(err), at: time.Now()}
+	}
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			resp, err := handler(ctx, req)
+			record(info.FullMethod, err)
+			return resp, err
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			err := handler(srv, ss)
+			record(info.FullMethod, err)
+			return err
+		}),
+	)
+	pb.RegisterOrderManagementServer(s, &server{store: newOrderStore(testStoreDelay), addDelay: 5 * time.Second})
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewOrderManagementClient(conn), exits
+}
+
+// cancelLater 等待 cancelAfter 后取消 RPC，此时服务端正在执行存储操作或等待消息
+func cancelLater(cancel context.CancelFunc) time.Time {
+	time.Sleep(cancelAfter)
+	cancel()
+	return time.Now()
+}
+
+func hasCode(want []codes.Code, code codes.Code) bool {
+	for _, c := range want {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHandlersStopOnCancel(t *testing.T) {
+	cases := []struct {
+		name string
+		want []codes.Code
+		// run 发起 RPC 并在处理函数执行中取消，返回取消（或截止）的时间
+		run func(t *testing.T, client pb.OrderManagementClient) time.Time
+	}{
+		// 客户端在截止时间也会发送 RST_STREAM，服务端可能先看到取消
+		{"AddOrder deadline", []codes.Code{codes.DeadlineExceeded, codes.Canceled}, func(t *testing.T, client pb.OrderManagementClient) time.Time {
+			ctx, cancel := context.WithTimeout(context.Background(), cancelAfter)
+			defer cancel()
+			deadline, _ := ctx.Deadline()
+			client.AddOrder(ctx, &pb.Order{Id: "201"})
+			return deadline
+		}},
+		{"GetOrder on slow store", []codes.Code{codes.Canceled}, func(t *testing.T, client pb.OrderManagementClient) time.Time {
+			ctx, cancel := context.WithCancel(context.Background())
+			go client.GetOrder(ctx, &wrappers.StringValue{Value: "102"})
+			return cancelLater(cancel)
+		}},
+		{"SearchOrders on slow store", []codes.Code{codes.Canceled}, func(t *testing.T, client pb.OrderManagementClient) time.Time {
+			ctx, cancel := context.WithCancel(context.Background())
+			if _, err := client.SearchOrders(ctx, &wrappers.StringValue{Value: "Google"}); err != nil {
+				t.Fatalf("SearchOrders: %v", err)
+			}
+			return cancelLater(cancel)
+		}},
+		{"UpdateOrders on slow store", []codes.Code{codes.Canceled}, func(t *testing.T, client pb.OrderManagementClient) time.Time {
+			ctx, cancel := context.WithCancel(context.Background())
+			stream, err := client.UpdateOrders(ctx)
+			if err == nil {
+				err = stream.Send(&pb.Order{Id: "102", Items: []string{"Google Pixel 3A"}})
+			}
+			if err != nil {
+				t.Fatalf("UpdateOrders: %v", err)
+			}
+			return cancelLater(cancel)
+		}},
+		{"ProcessOrders on slow store", []codes.Code{codes.Canceled}, func(t *testing.T, client pb.OrderManagementClient) time.Time {
+			ctx, cancel := context.WithCancel(context.Background())
+			stream, err := client.ProcessOrders(ctx)
+			if err == nil {
+				err = stream.Send(&wrappers.StringValue{Value: "102"})
+			}
+			if err != nil {
+				t.Fatalf("ProcessOrders: %v", err)
+			}
+			return cancelLater(cancel)
+		}},
+		{"ProcessOrders waiting for orders", []codes.Code{codes.Canceled}, func(t *testing.T, client pb.OrderManagementClient) time.Time {
+			ctx, cancel := context.WithCancel(context.Background())
+			if _, err := client.ProcessOrders(ctx); err != nil {
+				t.Fatalf("ProcessOrders: %v", err)
+			}
+			return cancelLater(cancel)
+		}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client, exits := startServer(t)
+			cancelledAt := c.run(t, client)
+			select {
+			case exit := <-exits:
+				took := exit.at.Sub(cancelledAt)
+				if !hasCode(c.want, exit.code) || took > cancelTolerance {
+					t.Errorf("%s returned %s %v after cancellation, want %v within %v", exit.method, exit.code, took, c.want, cancelTolerance)
+				}
+			case <-time.After(testStoreDelay * 3):
+				t.Errorf("handler still running %v after cancellation", testStoreDelay*3)
+			}
+		})
+	}
+}
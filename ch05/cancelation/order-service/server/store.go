@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	pb "ordermgt/server/ecommerce"
+	"sort"
+	"sync"
+	"time"
+)
+
+// orderStore 是订单存储，所有方法在 ctx 取消或超时时立即返回 ctx.Err()
+type orderStore interface {
+	Get(ctx context.Context, id string) (pb.Order, bool, error)
+	Put(ctx context.Context, order pb.Order) error
+	// Range 按订单 ID 顺序对每个订单调用 f，f 返回错误时停止并返回该错误
+	Range(ctx context.Context, f func(order pb.Order) error) error
+}
+
+// memoryStore 是内存中的订单存储
+type memoryStore struct {
+	mu     sync.RWMutex
+	orders map[string]pb.Order
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{orders: make(map[string]pb.Order)}
+}
+
+func (s *memoryStore) Get(ctx context.Context, id string) (pb.Order, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return pb.Order{}, false, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	order, found := s.orders[id]
+	return order, found, nil
+}
+
+func (s *memoryStore) Put(ctx context.Context, order pb.Order) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orders[order.Id] = order
+	return nil
+}
+
+func (s *memoryStore) Range(ctx context.Context, f func(order pb.Order) error) error {
+	// 先复制一份，调用 f（可能向流中发送消息）时不持有锁
+	s.mu.RLock()
+	orders := make([]pb.Order, 0, len(s.orders))
+	for _, order := range s.orders {
+		orders = append(orders, order)
+	}
+	s.mu.RUnlock()
+	sort.Slice(orders, func(i, j int) bool { return orders[i].Id < orders[j].Id })
+
+	for _, order := range orders {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := f(order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// slowStore 在每次操作（Range 中每个订单）前等待 delay，用于模拟慢速的存储，
+// 等待期间 ctx 被取消时立即返回
+type slowStore struct {
+	orderStore
+	delay time.Duration
+}
+
+func (s *slowStore) Get(ctx context.Context, id string) (pb.Order, bool, error) {
+	if err := sleep(ctx, s.delay); err != nil {
+		return pb.Order{}, false, err
+	}
+	return s.orderStore.Get(ctx, id)
+}
+
+func (s *slowStore) Put(ctx context.Context, order pb.Order) error {
+	if err := sleep(ctx, s.delay); err != nil {
+		return err
+	}
+	return s.orderStore.Put(ctx, order)
+}
+
+func (s *slowStore) Range(ctx context.Context, f func(order pb.Order) error) error {
+	return s.orderStore.Range(ctx, func(order pb.Order) error {
+		if err := sleep(ctx, s.delay); err != nil {
+			return err
+		}
+		return f(order)
+	})
+}
+
+// sleep 等待 d，ctx 先结束时返回 ctx.Err()
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
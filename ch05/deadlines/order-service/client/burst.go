@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"google.golang.org/grpc/status"
+	"log"
+	"math/rand"
+	pb "ordermgt/client/ecommerce"
+	"sync"
+	"time"
+)
+
+// 突发负载：同时发送 -burst 个 AddOrder，截止时间从 burstBase 开始依次增加 burstStep，发送顺序随机。
+// 服务端启用准入队列（-max-concurrent）时，请求按截止时间先后被处理，来不及完成的请求会被提前丢弃
+var burst = flag.Int("burst", 0, "number of concurrent AddOrder calls with staggered deadlines to send, 0 disables the burst")
+
+const (
+	burstBase = 6 * time.Second
+	burstStep = time.Second // 服务端默认将 AddOrder 的截止时间限制在 10 秒以内
+)
+
+func runBurst(client pb.OrderManagementClient, n int) {
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, i := range rand.Perm(n) {
+		timeout := burstBase + time.Duration(i)*burstStep
+		wg.Add(1)
+		go func(i int, timeout time.Duration) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			_, err := client.AddOrder(ctx, &pb.Order{Id: "burst-" + string(rune('a'+i))})
+			log.Printf("burst: order with %v deadline finished after %v: %v",
+				timeout, time.Since(start).Round(100*time.Millisecond), status.Code(err))
+		}(i, timeout)
+		time.Sleep(10 * time.Millisecond) // 保证请求依次到达
+	}
+	wg.Wait()
+}
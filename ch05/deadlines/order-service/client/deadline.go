@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"google.golang.org/grpc"
+	"time"
+)
+
+// 客户端默认超时：调用方没有设置截止时间时由拦截器补充，避免请求在服务端无限期地占用资源
+var defaultTimeout = flag.Duration("default-timeout", 5*time.Second, "timeout injected into calls made without a deadline, 0 disables the injection")
+
+// methodTimeouts 是各方法的默认超时，没有列出的方法使用 -default-timeout
+var methodTimeouts = map[string]time.Duration{
+	"/ecommerce.OrderManagement/processOrders": time.Minute,
+}
+
+func timeoutFor(method string) time.Duration {
+	if *defaultTimeout <= 0 {
+		return 0
+	}
+	if d, ok := methodTimeouts[method]; ok {
+		return d
+	}
+	return *defaultTimeout
+}
+
+// defaultTimeoutUnaryClientInterceptor 为没有截止时间的一元 RPC 设置默认超时
+func defaultTimeoutUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if _, ok := ctx.Deadline(); !ok && timeoutFor(method) > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeoutFor(method))
+		defer cancel()
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// defaultTimeoutStreamClientInterceptor 为没有截止时间的流 RPC 设置默认超时，流结束时释放定时器
+func defaultTimeoutStreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+	method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if _, ok := ctx.Deadline(); ok || timeoutFor(method) <= 0 {
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeoutFor(method))
+	s, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &timeoutClientStream{ClientStream: s, cancel: cancel, serverStreams: desc.ServerStreams}, nil
+}
+
+// timeoutClientStream 在流结束时调用 cancel：RecvMsg 返回错误（包括 io.EOF），
+// 或者客户端流 RPC 收到了唯一的响应
+type timeoutClientStream struct {
+	grpc.ClientStream
+	cancel        context.CancelFunc
+	serverStreams bool
+}
+
+func (s *timeoutClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || !s.serverStreams {
+		s.cancel()
+	}
+	return err
+}
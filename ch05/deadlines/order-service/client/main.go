@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"github.com/golang/protobuf/ptypes/wrappers"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/status"
@@ -15,18 +16,32 @@ const (
 	address = "localhost:50051"
 )
 
+var timeout = flag.Duration("timeout", 2*time.Second, "deadline of the calls, 0 leaves the deadline to the default timeout interceptors")
+
 func main() {
-	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	flag.Parse()
+	conn, err := grpc.Dial(address, grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(defaultTimeoutUnaryClientInterceptor),   // 没有截止时间时使用默认超时
+		grpc.WithStreamInterceptor(defaultTimeoutStreamClientInterceptor), // 流 RPC 同样适用
+	)
 	if err != nil {
 		log.Fatalf("did not connect: %v", err)
 	}
 	defer conn.Close()
 	orderMgtClient := pb.NewOrderManagementClient(conn)
+	if *burst > 0 {
+		runBurst(orderMgtClient, *burst)
+		return
+	}
 
 	// add deadline
-	clientDeadline := time.Now().Add(time.Duration(2 * time.Second)) // 2秒截止时间
-	ctx, cancel := context.WithDeadline(context.Background(),clientDeadline)
-	defer cancel()
+	ctx := context.Background()
+	if *timeout > 0 {
+		clientDeadline := time.Now().Add(*timeout) // 默认 2 秒截止时间
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, clientDeadline)
+		defer cancel()
+	}
 
 	// 添加订单
 	order1 := pb.Order{Id: "101", Items: []string{"iPhone XS", "Mac Book Pro"},Destination: "San Jose, CA", Price: 2300.00}
@@ -42,12 +57,19 @@ func main() {
 	retrievedOrder, err := orderMgtClient.GetOrder(ctx, &wrappers.StringValue{Value: "106"})
 	log.Print("GetOrder Response -> : ", retrievedOrder)
 
-	searchStream, _ := orderMgtClient.SearchOrders(ctx, &wrappers.StringValue{Value: "Google"})
-	for {
+	searchStream, err := orderMgtClient.SearchOrders(ctx, &wrappers.StringValue{Value: "Google"})
+	if err != nil {
+		log.Printf("Error Occured -> searchOrders : %v", status.Code(err))
+	}
+	for err == nil {
 		searchOrder, err := searchStream.Recv()
 		if err == io.EOF {
 			break
 		}
+		if err != nil {
+			log.Printf("Error Occured -> searchOrders : %v", status.Code(err))
+			break
+		}
 		log.Print("Search Result: ", searchOrder)
 	}
 
@@ -119,6 +141,10 @@ func asyncClientBidirectionalRPC(streamProcOrder pb.OrderManagement_ProcessOrder
 		if errProcOrder == io.EOF {
 			break
 		}
+		if errProcOrder != nil {
+			log.Printf("Error Occured -> processOrders : %v", status.Code(errProcOrder))
+			break
+		}
 		log.Print("Combined shipment : ", combinedShipment.OrdersList)
 	}
 	<-c
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"expvar"
+	"flag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 准入队列：同时处理的一元 RPC 达到 maxConcurrent 后，新请求进入等待队列，按截止时间最早优先（EDF）的顺序处理，
+// 没有截止时间的请求排在最后。根据各方法实际完成的请求观测到的平均耗时，估计已经无法在截止时间前完成的请求直接以
+// DeadlineExceeded 丢弃，不再占用处理能力。因超时或取消而中途结束的请求只反映了等待了多久，不计入耗时。队列长度等指标通过 expvar 发布在 -metrics-addr 的 /debug/vars
+var (
+	maxConcurrent = flag.Int("max-concurrent", 0, "unary RPCs handled at the same time before requests are queued by deadline, 0 disables the admission queue")
+	maxQueue      = flag.Int("max-queue", 100, "waiting requests beyond which new requests fail with ResourceExhausted")
+	metricsAddr   = flag.String("metrics-addr", "", "HTTP address serving queue metrics at /debug/vars, disabled when empty")
+)
+
+// latencyAlpha 是耗时指数移动平均的权重
+const latencyAlpha = 0.2
+
+// waiter 是一个等待处理的请求
+type waiter struct {
+	method   string
+	deadline time.Time  // 零值表示没有截止时间
+	seq      uint64     // 截止时间相同时先到先处理
+	ready    chan error // 被接纳时收到 nil，被丢弃时收到错误
+	index    int        // 在堆中的位置，出队后为 -1
+}
+
+// waitQueue 是按截止时间排序的最小堆
+type waitQueue []*waiter
+
+func (q waitQueue) Len() int { return len(q) }
+
+func (q waitQueue) Less(i, j int) bool {
+	a, b := q[i], q[j]
+	switch {
+	case a.deadline.IsZero() != b.deadline.IsZero():
+		return b.deadline.IsZero()
+	case !a.deadline.Equal(b.deadline):
+		return a.deadline.Before(b.deadline)
+	}
+	return a.seq < b.seq
+}
+
+func (q waitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waitQueue) Push(x interface{}) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waitQueue) Pop() interface{} {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*q = old[:len(old)-1]
+	return w
+}
+
+// admissionQueue 限制并发并按 EDF 顺序接纳请求
+type admissionQueue struct {
+	limit    int
+	maxQueue int
+
+	mu       sync.Mutex
+	running  int
+	waiting  waitQueue
+	seq      uint64
+	latency  map[string]time.Duration // 方法 -> 耗时的指数移动平均
+	maxDepth int
+
+	admitted, rejected, infeasible, abandoned expvar.Int
+}
+
+func newAdmissionQueue(limit, maxQueue int) *admissionQueue {
+	return &admissionQueue{limit: limit, maxQueue: maxQueue, latency: make(map[string]time.Duration)}
+}
+
+// publish 把队列的指标发布为 expvar 变量 admission，每个进程只能调用一次
+func (q *admissionQueue) publish() {
+	stats := expvar.NewMap("admission")
+	stats.Set("admitted", &q.admitted)     // 接纳的请求
+	stats.Set("rejected", &q.rejected)     // 队列已满而拒绝的请求
+	stats.Set("infeasible", &q.infeasible) // 估计无法按时完成而丢弃的请求
+	stats.Set("abandoned", &q.abandoned)   // 等待期间被客户端取消或超时的请求
+	stats.Set("queue_depth", expvar.Func(func() interface{} {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return len(q.waiting)
+	}))
+	stats.Set("max_queue_depth", expvar.Func(func() interface{} {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return q.maxDepth
+	}))
+	stats.Set("running", expvar.Func(func() interface{} {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return q.running
+	}))
+	stats.Set("latency_ms", expvar.Func(func() interface{} {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		ms := make(map[string]float64, len(q.latency))
+		for method, d := range q.latency {
+			ms[method] = float64(d) / float64(time.Millisecond)
+		}
+		return ms
+	}))
+}
+
+// feasibleLocked 检查请求能否在截止时间前完成，估计耗时为该方法的平均耗时
+func (q *admissionQueue) feasibleLocked(method string, deadline time.Time) error {
+	if deadline.IsZero() {
+		return nil
+	}
+	if remaining, estimate := time.Until(deadline), q.latency[method]; remaining < estimate {
+		q.infeasible.Add(1)
+		return status.Errorf(codes.DeadlineExceeded, "%s: remaining %v is less than the expected latency %v",
+			method, remaining.Round(time.Millisecond), estimate.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// acquire 等待处理 method 的请求，返回 nil 时调用方必须在处理结束后调用 release
+func (q *admissionQueue) acquire(ctx context.Context, method string) error {
+	deadline, _ := ctx.Deadline()
+	q.mu.Lock()
+	if err := q.feasibleLocked(method, deadline); err != nil {
+		q.mu.Unlock()
+		return err
+	}
+	if q.running < q.limit && len(q.waiting) == 0 {
+		q.running++
+		q.mu.Unlock()
+		q.admitted.Add(1)
+		return nil
+	}
+	if len(q.waiting) >= q.maxQueue {
+		q.mu.Unlock()
+		q.rejected.Add(1)
+		return status.Errorf(codes.ResourceExhausted, "admission queue is full (%d waiting)", q.maxQueue)
+	}
+	q.seq++
+	w := &waiter{method: method, deadline: deadline, seq: q.seq, ready: make(chan error, 1)}
+	heap.Push(&q.waiting, w)
+	if len(q.waiting) > q.maxDepth {
+		q.maxDepth = len(q.waiting)
+	}
+	q.mu.Unlock()
+
+	select {
+	case err := <-w.ready:
+		return err
+	case <-ctx.Done():
+		q.mu.Lock()
+		queued := w.index >= 0
+		if queued {
+			heap.Remove(&q.waiting, w.index)
+		}
+		q.mu.Unlock()
+		if !queued {
+			if err := <-w.ready; err != nil {
+				return err // 已经被丢弃
+			}
+			q.release("", 0) // 刚被接纳，把名额让给下一个请求
+		}
+		q.abandoned.Add(1)
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// release 记录 method 的耗时（method 为空时不记录），并按 EDF 顺序接纳等待中的请求
+func (q *admissionQueue) release(method string, elapsed time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if method != "" {
+		if avg, ok := q.latency[method]; ok {
+			q.latency[method] = avg + time.Duration(latencyAlpha*float64(elapsed-avg))
+		} else {
+			q.latency[method] = elapsed
+		}
+	}
+	q.running--
+	for q.running < q.limit && len(q.waiting) > 0 {
+		w := heap.Pop(&q.waiting).(*waiter)
+		if err := q.feasibleLocked(w.method, w.deadline); err != nil {
+			w.ready <- err
+			continue
+		}
+		q.running++
+		q.admitted.Add(1)
+		w.ready <- nil
+	}
+}
+
+// unaryServerInterceptor 通过准入队列处理一元 RPC，健康检查不排队
+func (q *admissionQueue) unaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	method, _ := grpc.Method(ctx)
+	if method == "/grpc.health.v1.Health/Check" {
+		return handler(ctx, req)
+	}
+	if err := q.acquire(ctx, method); err != nil {
+		log.Printf("admission: %s dropped: %v", method, err)
+		return nil, err
+	}
+	start := time.Now()
+	completed := false
+	defer func() { // 处理函数 panic 时同样归还名额，只有正常返回的请求计入耗时
+		switch code := status.Code(err); {
+		case !completed, code == codes.DeadlineExceeded, code == codes.Canceled:
+			q.release("", 0)
+		default:
+			q.release(method, time.Since(start))
+		}
+	}()
+	resp, err = handler(ctx, req)
+	completed = true
+	return resp, err
+}
+
+// serveMetrics 在 addr 上通过 HTTP 发布 expvar 指标
+func serveMetrics(addr string) {
+	if err := http.ListenAndServe(addr, nil); err != nil {
+		log.Printf("metrics: %v", err)
+	}
+}
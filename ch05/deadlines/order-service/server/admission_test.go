@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	pb "ordermgt/server/ecommerce"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startQueuedServer 启动经过准入队列处理一元 RPC 的服务端，返回按接纳顺序记录订单 ID 的函数
+func startQueuedServer(t *testing.T, q *admissionQueue) (pb.OrderManagementClient, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var admitted []string
+	record := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		mu.Lock()
+		admitted = append(admitted, req.(*pb.Order).Id)
+		mu.Unlock()
+		return handler(ctx, req)
+	}
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(q.unaryServerInterceptor, record))
+	pb.RegisterOrderManagementServer(s, &server{})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }))
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewOrderManagementClient(conn), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), admitted...)
+	}
+}
+
+// waitForQueue 等待队列中有 n 个请求
+func waitForQueue(t *testing.T, q *admissionQueue, n int) {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(5 * time.Millisecond) {
+		q.mu.Lock()
+		depth := len(q.waiting)
+		q.mu.Unlock()
+		if depth == n {
+			return
+		}
+	}
+	t.Fatalf("queue never reached %d waiting requests", n)
+}
+
+// 并发为 1 时，后到但截止时间更早的请求先被接纳；队列满时立即返回 ResourceExhausted；
+// 学到的耗时超过剩余时间的请求以 DeadlineExceeded 丢弃，都不进入处理函数
+func TestAdmissionQueue(t *testing.T) {
+	const delay = 300 * time.Millisecond
+	defer func(d time.Duration) { *addOrderDelay = d }(*addOrderDelay)
+	*addOrderDelay = delay
+
+	q := newAdmissionQueue(1, 3)
+	client, admitted := startQueuedServer(t, q)
+
+	var wg sync.WaitGroup
+	addOrder := func(id string, timeout time.Duration) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			if _, err := client.AddOrder(ctx, &pb.Order{Id: id}); err != nil {
+				t.Errorf("AddOrder %s: %v", id, err)
+			}
+		}()
+	}
+	addOrder("running", time.Minute)
+	for len(admitted()) == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	// 按截止时间从晚到早依次到达
+	for i, id := range []string{"late", "mid", "early"} {
+		addOrder(id, time.Duration(6-i)*time.Second)
+		waitForQueue(t, q, i+1)
+	}
+
+	_, err := client.AddOrder(context.Background(), &pb.Order{Id: "overflow"})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("AddOrder on a full queue returned %v, want ResourceExhausted", err)
+	}
+	wg.Wait()
+
+	want := []string{"running", "early", "mid", "late"}
+	got := admitted()
+	if len(got) != len(want) {
+		t.Fatalf("admitted %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("admitted %v, want %v", got, want)
+		}
+	}
+
+	// 已经学到 addOrder 的耗时约为 delay，剩余时间更短的请求不会被处理
+	ctx, cancel := context.WithTimeout(context.Background(), delay/3)
+	defer cancel()
+	if _, err := client.AddOrder(ctx, &pb.Order{Id: "infeasible"}); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("infeasible AddOrder returned %v, want DeadlineExceeded", err)
+	}
+
+	if n := q.admitted.Value(); n != 4 {
+		t.Errorf("admitted counter = %d, want 4", n)
+	}
+	if n := q.rejected.Value(); n != 1 {
+		t.Errorf("rejected counter = %d, want 1", n)
+	}
+	if n := q.infeasible.Value(); n != 1 {
+		t.Errorf("infeasible counter = %d, want 1", n)
+	}
+	if got := admitted(); len(got) != len(want) {
+		t.Errorf("dropped requests reached the handler: %v", got)
+	}
+}
+
+// 处理函数 panic 时并发名额同样被归还
+func TestAdmissionReleaseOnPanic(t *testing.T) {
+	q := newAdmissionQueue(1, 1)
+	func() {
+		defer func() { recover() }()
+		q.unaryServerInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{},
+			func(ctx context.Context, req interface{}) (interface{}, error) { panic("handler failed") })
+	}()
+	q.mu.Lock()
+	running := q.running
+	q.mu.Unlock()
+	if running != 0 {
+		t.Errorf("running = %d after a panicking handler, want 0", running)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"time"
+)
+
+// 服务端截止时间策略：按方法配置，可以拒绝没有截止时间的请求、缩短过长的截止时间，或为没有截止时间的请求设置默认值。
+// 配置文件的格式为方法全名（与客户端调用的路径相同，例如 /ecommerce.OrderManagement/addOrder）到策略的映射，
+// "*" 为其他方法的策略：
+// {"/ecommerce.OrderManagement/addOrder": {"require": true, "max": "10s"}, "*": {"default": "30s", "max": "5m"}}
+var deadlinePolicyPath = flag.String("deadline-policy", "", "path of a JSON file with per-method deadline policies, uses the built-in policies when empty")
+
+type deadlinePolicy struct {
+	Require bool   `json:"require,omitempty"` // 没有截止时间时返回 InvalidArgument
+	Max     string `json:"max,omitempty"`     // 剩余时间超过 max 时缩短为 max
+	Default string `json:"default,omitempty"` // 没有截止时间（且 require 为 false）时使用的超时
+
+	max, def time.Duration
+}
+
+func (p *deadlinePolicy) validate() error {
+	var err error
+	if p.Max != "" {
+		if p.max, err = time.ParseDuration(p.Max); err != nil || p.max <= 0 {
+			return fmt.Errorf("max must be a positive duration")
+		}
+	}
+	if p.Default != "" {
+		if p.def, err = time.ParseDuration(p.Default); err != nil || p.def <= 0 {
+			return fmt.Errorf("default must be a positive duration")
+		}
+	}
+	if p.max > 0 && p.def > p.max {
+		return fmt.Errorf("default %v exceeds max %v", p.def, p.max)
+	}
+	return nil
+}
+
+// deadlinePolicies 是方法全名到策略的映射
+type deadlinePolicies map[string]*deadlinePolicy
+
+// defaultDeadlinePolicyJSON 在没有指定 -deadline-policy 时使用：AddOrder 必须设置截止时间，其他方法默认 30 秒，最多 5 分钟
+const defaultDeadlinePolicyJSON = `{
+  "/ecommerce.OrderManagement/addOrder": {"require": true, "max": "10s"},
+  "*": {"default": "30s", "max": "5m"}
+}`
+
+func loadDeadlinePolicies(path string) (deadlinePolicies, error) {
+	data, source := []byte(defaultDeadlinePolicyJSON), "built-in policies"
+	if path != "" {
+		var err error
+		if data, err = ioutil.ReadFile(path); err != nil {
+			return nil, err
+		}
+		source = path
+	}
+	var policies deadlinePolicies
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return nil, fmt.Errorf("%s: %v", source, err)
+	}
+	for method, p := range policies {
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", source, method, err)
+		}
+	}
+	return policies, nil
+}
+
+func (ps deadlinePolicies) lookup(method string) *deadlinePolicy {
+	if p, ok := ps[method]; ok {
+		return p
+	}
+	return ps["*"]
+}
+
+// apply 按方法的策略调整 ctx 的截止时间，返回的 cancel 需要在 RPC 结束时调用
+func (ps deadlinePolicies) apply(ctx context.Context, method string) (context.Context, context.CancelFunc, error) {
+	p := ps.lookup(method)
+	if p == nil {
+		return ctx, func() {}, nil
+	}
+	deadline, ok := ctx.Deadline()
+	switch {
+	case !ok && p.Require:
+		return nil, nil, status.Errorf(codes.InvalidArgument, "%s requires a deadline", method)
+	case !ok && p.def > 0:
+		log.Printf("deadline: %s has no deadline, using default %v", method, p.def)
+		ctx, cancel := context.WithTimeout(ctx, p.def)
+		return ctx, cancel, nil
+	case ok && p.max > 0 && time.Until(deadline) > p.max:
+		log.Printf("deadline: %s deadline %v capped to %v", method, time.Until(deadline).Round(time.Millisecond), p.max)
+		ctx, cancel := context.WithTimeout(ctx, p.max)
+		return ctx, cancel, nil
+	}
+	return ctx, func() {}, nil
+}
+
+// unaryServerInterceptor 按策略调整一元 RPC 的截止时间
+func (ps deadlinePolicies) unaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, cancel, err := ps.apply(ctx, methodName(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	return handler(ctx, req)
+}
+
+// streamServerInterceptor 按策略调整流 RPC 的截止时间，处理函数通过 stream.Context() 获得调整后的上下文
+func (ps deadlinePolicies) streamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, cancel, err := ps.apply(ss.Context(), methodName(ss.Context()))
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	if ctx == ss.Context() {
+		return handler(srv, ss)
+	}
+	return handler(srv, &deadlineServerStream{ServerStream: ss, ctx: ctx})
+}
+
+// methodName 返回客户端调用的方法路径，一元和流 RPC 都从上下文中获取，与策略文件中的方法名一致。
+// 旧版生成代码中 UnaryServerInfo.FullMethod 的大小写与实际调用的路径不同，因此不使用 info.FullMethod
+func methodName(ctx context.Context) string {
+	method, _ := grpc.Method(ctx)
+	return method
+}
+
+// deadlineServerStream 使用服务端设置的截止时间。原始流只受客户端截止时间控制，
+// 因此 RecvMsg 在新的截止时间到达时直接返回 DeadlineExceeded，不再等待客户端的消息。
+// 消息由一个读取 goroutine 依次接收到新的消息实例中，再复制给调用方：截止时间到达后仍在进行的接收
+// 不会写入调用方的消息，之后的 RecvMsg 也不会在原始流上并发接收
+type deadlineServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+
+	msgs chan recvMsg // 读取 goroutine 接收到的消息，第一次调用 RecvMsg 时创建
+	err  error        // 截止时间到达或接收失败后，RecvMsg 始终返回该错误
+}
+
+type recvMsg struct {
+	msg proto.Message
+	err error
+}
+
+func (s *deadlineServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *deadlineServerStream) RecvMsg(m interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "unexpected message type %T", m)
+	}
+	if s.msgs == nil {
+		s.msgs = make(chan recvMsg)
+		go s.recvLoop(reflect.TypeOf(msg).Elem())
+	}
+	if err := s.ctx.Err(); err != nil {
+		s.err = status.FromContextError(err).Err()
+		return s.err
+	}
+	select {
+	case r := <-s.msgs:
+		if r.err != nil {
+			s.err = r.err
+			return r.err
+		}
+		msg.Reset()
+		proto.Merge(msg, r.msg)
+		return nil
+	case <-s.ctx.Done():
+		// 处理函数收到错误后应当返回，流结束后读取 goroutine 随之退出
+		s.err = status.FromContextError(s.ctx.Err()).Err()
+		return s.err
+	}
+}
+
+// recvLoop 持续从原始流接收 typ 类型的消息，直到接收失败或 RPC 结束
+func (s *deadlineServerStream) recvLoop(typ reflect.Type) {
+	for {
+		msg := reflect.New(typ).Interface().(proto.Message)
+		err := s.ServerStream.RecvMsg(msg)
+		select {
+		case s.msgs <- recvMsg{msg: msg, err: err}:
+		case <-s.ServerStream.Context().Done():
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
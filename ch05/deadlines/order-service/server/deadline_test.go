@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	pb "ordermgt/server/ecommerce"
+	"testing"
+	"time"
+)
+
+func startServer(t *testing.T, policies deadlinePolicies) pb.OrderManagementClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(policies.unaryServerInterceptor),
+		grpc.StreamInterceptor(policies.streamServerInterceptor),
+	)
+	pb.RegisterOrderManagementServer(s, &server{})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }))
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewOrderManagementClient(conn)
+}
+
+func TestDefaultDeadlinePolicies(t *testing.T) {
+	policies, err := loadDeadlinePolicies("")
+	if err != nil {
+		t.Fatalf("loadDeadlinePolicies: %v", err)
+	}
+	if p := policies.lookup("/ecommerce.OrderManagement/addOrder"); p == nil || !p.Require || p.max != 10*time.Second {
+		t.Errorf("addOrder policy = %+v, want require with max 10s", p)
+	}
+	if p := policies.lookup("/ecommerce.OrderManagement/getOrder"); p == nil || p.def != 30*time.Second || p.max != 5*time.Minute {
+		t.Errorf("default policy = %+v, want default 30s and max 5m", p)
+	}
+}
+
+// 服务端为 updateOrders 设置的截止时间到达时，等待客户端消息的 Recv 立即返回 DeadlineExceeded，
+// 之前收到的消息完整交给处理函数
+func TestStreamRecvServerDeadline(t *testing.T) {
+	const max = 300 * time.Millisecond
+	policies := deadlinePolicies{"/ecommerce.OrderManagement/updateOrders": {Max: max.String()}}
+	for _, p := range policies {
+		if err := p.validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := startServer(t, policies)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	stream, err := client.UpdateOrders(ctx)
+	if err != nil {
+		t.Fatalf("UpdateOrders: %v", err)
+	}
+	sent := []*pb.Order{
+		{Id: "t-1", Items: []string{"Google Pixel 3A"}, Destination: "Mountain View, CA", Price: 1800},
+		{Id: "t-2", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA", Price: 400},
+	}
+	for _, o := range sent {
+		if err := stream.Send(o); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	// 不再发送，也不关闭发送方向，服务端只能等到自己的截止时间
+	err = stream.RecvMsg(new(wrappers.StringValue))
+	if took := time.Since(start); status.Code(err) != codes.DeadlineExceeded || took > max+500*time.Millisecond {
+		t.Fatalf("UpdateOrders returned %v after %v, want DeadlineExceeded after about %v", err, took, max)
+	}
+	for _, o := range sent {
+		if got := getOrder(o.Id); got.Destination != o.Destination || len(got.Items) != 1 || got.Items[0] != o.Items[0] {
+			t.Errorf("order %s stored as %v, want %v", o.Id, got, o)
+		}
+	}
+}
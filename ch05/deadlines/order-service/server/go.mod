@@ -5,6 +5,7 @@ go 1.17
 require (
 	github.com/golang/protobuf v1.5.2
 	google.golang.org/grpc v1.41.0
+	graceful v0.0.0
 )
 
 require (
@@ -14,3 +15,5 @@ require (
 	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
 	google.golang.org/protobuf v1.26.0 // indirect
 )
+
+replace graceful => ../../../graceful
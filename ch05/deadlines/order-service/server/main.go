@@ -2,14 +2,21 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"github.com/golang/protobuf/ptypes/wrappers"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"graceful"
 	"io"
 	"log"
 	"net"
 	pb "ordermgt/server/ecommerce"
+	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -18,22 +25,37 @@ const (
 	orderBatchSize = 3
 )
 
-var orderMap = make(map[string]pb.Order)
+var addOrderDelay = flag.Duration("add-delay", 5*time.Second, "simulated processing time of AddOrder, also what the admission queue learns as its latency")
+
+var (
+	orderMap = make(map[string]pb.Order)
+	orderMu  sync.RWMutex // 保护 orderMap，-max-concurrent 不为 1 时多个 AddOrder、UpdateOrders 并发写入
+)
+
+// getOrder 返回订单的副本
+func getOrder(id string) pb.Order {
+	orderMu.RLock()
+	defer orderMu.RUnlock()
+	return orderMap[id]
+}
 
 type server struct {
 	orderMap map[string]*pb.Order
 }
 
 func (s *server) AddOrder(ctx context.Context, orderReq *pb.Order) (*wrappers.StringValue, error) {
+	orderMu.Lock()
 	orderMap[orderReq.Id] = *orderReq
-
-	sleepDuration := 5
-	log.Println("Sleeping for :", sleepDuration, "s")
-	time.Sleep(time.Duration(sleepDuration) * time.Second)
-
-	if ctx.Err() == context.DeadlineExceeded {
+	orderMu.Unlock()
+
+	log.Println("Sleeping for :", *addOrderDelay)
+	timer := time.NewTimer(*addOrderDelay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done(): // 超过截止时间后不再继续处理
 		log.Printf("RPC has reached deadline exceeded state : %s ", ctx.Err())
-		return nil, ctx.Err()
+		return nil, status.FromContextError(ctx.Err()).Err()
 	}
 	log.Println("Order : ", orderReq.Id, " -> Added")
 	return &wrappers.StringValue{Value: "Order Added: " + orderReq.Id}, nil
@@ -42,8 +64,20 @@ func (s *server) AddOrder(ctx context.Context, orderReq *pb.Order) (*wrappers.St
 func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) error {
 	batchMarker := 1
 	var combinedShipmentMap = make(map[string]pb.CombinedShipment)
+	orderIds := recvOrderIds(stream)
 	for {
-		orderId, err := stream.Recv()
+		var r graceful.Received
+		var ok bool
+		select {
+		case r, ok = <-orderIds:
+			if !ok { // 流的上下文已取消
+				return status.FromContextError(stream.Context().Err()).Err()
+			}
+		case <-shuttingDown:
+			return flushShipments(stream, combinedShipmentMap, orderIds, lookupOrder) // 服务器退出前发送已缓存的批次
+		}
+		orderId, _ := r.Msg.(*wrappers.StringValue)
+		err := r.Err
 		log.Printf("Reading Proc order ; %s", orderId)
 		if err == io.EOF {
 			log.Printf("EOF : %s", orderId)
@@ -59,16 +93,15 @@ func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) er
 			return err
 		}
 
-		destination := orderMap[orderId.GetValue()].Destination
+		ord := getOrder(orderId.GetValue())
+		destination := ord.Destination
 		shipment, found := combinedShipmentMap[destination]
 
 		if found {
-			ord := orderMap[orderId.GetValue()]
 			shipment.OrdersList = append(shipment.OrdersList, &ord)
 			combinedShipmentMap[destination] = shipment
 		} else {
-			comShip := pb.CombinedShipment{Id: "cmb - " + (orderMap[orderId.GetValue()].Description), Status: "Processed!"}
-			ord := orderMap[orderId.GetValue()]
+			comShip := pb.CombinedShipment{Id: "cmb - " + (ord.Description), Status: "Processed!"}
 			comShip.OrdersList = append(shipment.OrdersList, &ord)
 			combinedShipmentMap[destination] = comShip
 			log.Print(len(comShip.OrdersList), comShip.GetId())
@@ -90,25 +123,31 @@ func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) er
 }
 
 func (s *server) GetOrder(ctx context.Context, orderId *wrappers.StringValue) (*pb.Order, error) {
-	ord := orderMap[orderId.Value]
+	ord := getOrder(orderId.Value)
 	return &ord, nil
 }
 
 func (s *server) SearchOrders(searchQuery *wrappers.StringValue, strem pb.OrderManagement_SearchOrdersServer) error {
+	var matches []pb.Order
+	orderMu.RLock() // 持锁时只收集匹配的订单，发送可能阻塞，不能占用锁
 	for key, order := range orderMap {
 		log.Print(key, order)
 		for _, itemStr := range order.Items {
 			log.Print(itemStr)
 			if strings.Contains(itemStr, searchQuery.Value) {
-				err := strem.Send(&order) // 在流中发送匹配的订单
-				if err != nil {
-					return fmt.Errorf("error sending message to stream: %v", err)
-				}
-				log.Print("Matching Order Found: ", key)
+				matches = append(matches, order)
 				break
 			}
 		}
 	}
+	orderMu.RUnlock()
+	for i := range matches {
+		err := strem.Send(&matches[i]) // 在流中发送匹配的订单
+		if err != nil {
+			return fmt.Errorf("error sending message to stream: %v", err)
+		}
+		log.Print("Matching Order Found: ", matches[i].Id)
+	}
 	return nil
 }
 
@@ -119,7 +158,13 @@ func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) erro
 		if err == io.EOF {          // 检查流是否已经结束
 			return stream.SendAndClose(&wrappers.StringValue{Value: "Orders processed " + ordersStr}) // 服务端发送响应
 		}
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		orderMu.Lock()
 		orderMap[order.Id] = *order
+		orderMu.Unlock()
 
 		log.Println("Order ID ", order.Id, ": Updated")
 		ordersStr += order.Id + ","
@@ -127,16 +172,34 @@ func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) erro
 }
 
 func main() {
+	flag.Parse()
 	initSampleData()
 	lis, err := net.Listen("tcp", port)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	s := grpc.NewServer()
-	pb.RegisterOrderManagementServer(s, &server{})
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+	policies, err := loadDeadlinePolicies(*deadlinePolicyPath)
+	if err != nil {
+		log.Fatalf("failed to load deadline policies: %v", err)
+	}
+	unaryInterceptors := []grpc.UnaryServerInterceptor{policies.unaryServerInterceptor} // 按方法拒绝、缩短或补充截止时间
+	if *maxConcurrent > 0 {
+		// 在截止时间策略之后执行，使用调整后的截止时间排队
+		queue := newAdmissionQueue(*maxConcurrent, *maxQueue)
+		queue.publish()
+		unaryInterceptors = append(unaryInterceptors, queue.unaryServerInterceptor)
 	}
+	if *metricsAddr != "" {
+		go serveMetrics(*metricsAddr)
+	}
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.StreamInterceptor(policies.streamServerInterceptor), // 流 RPC 同样适用截止时间策略
+	)
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(s, healthServer)
+	pb.RegisterOrderManagementServer(s, &server{})
+	os.Exit(serve(s, lis, healthServer))
 }
 
 func initSampleData() {
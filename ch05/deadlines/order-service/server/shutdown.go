@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"graceful"
+	"log"
+	"net"
+	pb "ordermgt/server/ecommerce"
+	"time"
+)
+
+var drainTimeout = flag.Duration("drain-timeout", 10*time.Second, "how long to wait for in-flight RPCs on SIGINT/SIGTERM before forcing the server to stop")
+
+// shuttingDown 在收到退出信号后关闭，ProcessOrders 据此把缓存的订单批次发给客户端并结束流
+var shuttingDown = graceful.ShuttingDown()
+
+// serve 运行 s 直到收到 SIGINT/SIGTERM 后优雅退出，返回进程退出码，见 graceful.Serve
+func serve(s *grpc.Server, lis net.Listener, healthServer *health.Server, onShutdown ...func()) int {
+	return graceful.Serve(s, lis, healthServer, *drainTimeout, onShutdown...)
+}
+
+// recvOrderIds 在单独的协程中接收订单 ID，见 graceful.Recv
+func recvOrderIds(stream pb.OrderManagement_ProcessOrdersServer) <-chan graceful.Received {
+	return graceful.Recv(stream.Context(), func() (interface{}, error) { return stream.Recv() })
+}
+
+// flushShipments 在服务器退出前把已经从流中读出、还没有处理的订单加入批次，发送缓存的批次，并以 Unavailable 结束流，
+// 客户端需要在其他实例上重新提交尚未出现在任何批次中的订单
+func flushShipments(stream pb.OrderManagement_ProcessOrdersServer, combinedShipmentMap map[string]pb.CombinedShipment,
+	orderIds <-chan graceful.Received, lookup func(orderId string) (pb.Order, error)) error {
+	return graceful.Flush(orderIds, func(msg interface{}) error {
+		ord, err := lookup(msg.(*wrappers.StringValue).GetValue())
+		if err != nil {
+			return err
+		}
+		shipment, found := combinedShipmentMap[ord.Destination]
+		if !found {
+			shipment = pb.CombinedShipment{Id: "cmb - " + ord.Description, Status: "Processed!"}
+		}
+		shipment.OrdersList = append(shipment.OrdersList, &ord)
+		combinedShipmentMap[ord.Destination] = shipment
+		return nil
+	}, func() error {
+		for _, comb := range combinedShipmentMap {
+			log.Printf("Flushing : %v -> %v", comb.Id, len(comb.OrdersList))
+			if err := stream.Send(&comb); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// lookupOrder 是 flushShipments 查找订单的方式
+func lookupOrder(orderId string) (pb.Order, error) {
+	return getOrder(orderId), nil
+}
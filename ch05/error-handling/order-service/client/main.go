@@ -6,6 +6,7 @@ import (
 	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"io"
 	"log"
@@ -29,6 +30,8 @@ func main() {
 	clientDeadline := time.Now().Add(time.Duration(2 * time.Second)) // 2秒截止时间
 	ctx, cancel := context.WithDeadline(context.Background(), clientDeadline)
 	defer cancel()
+	// 服务端根据 accept-language 选择错误的本地化消息
+	ctx = metadata.AppendToOutgoingContext(ctx, "accept-language", "zh-CN,zh;q=0.9,en;q=0.8")
 
 	// 添加订单
 	order1 := pb.Order{Id: "101", Items: []string{"iPhone XS", "Mac Book Pro"}, Destination: "San Jose, CA", Price: 2300.00}
@@ -40,14 +43,25 @@ func main() {
 			errorStatus := status.Convert(addErr)
 			for _, d := range errorStatus.Details() {
 				switch info := d.(type) {
+				case *epb.BadRequest:
+					for _, violation := range info.GetFieldViolations() {
+						log.Printf("Request Field Invalid: %s : %s", violation.GetField(), violation.GetDescription())
+					}
 				case *epb.BadRequest_FieldViolation:
 					log.Printf("Request Field Invalid: %s", info)
+				case *epb.LocalizedMessage:
+					log.Printf("Localized Message [%s]: %s", info.GetLocale(), info.GetMessage())
 				default:
 					log.Printf("Unexpected err type: %s", info)
 				}
 			}
 		} else {
 			log.Printf("Unhandled error : %s", errorCode)
+			for _, d := range status.Convert(addErr).Details() {
+				if info, ok := d.(*epb.LocalizedMessage); ok {
+					log.Printf("Localized Message [%s]: %s", info.GetLocale(), info.GetMessage())
+				}
+			}
 		}
 	} else {
 		log.Print("AddOrder Response -> ", res.Value)
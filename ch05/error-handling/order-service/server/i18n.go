@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	acceptLanguageKey = "accept-language" // 客户端通过该元数据指定期望的语言
+	defaultLocale     = "en"
+)
+
+// catalogs 按语言保存消息目录，每个目录以错误码名称（如 InvalidArgument）为 key
+type catalogs map[string]map[string]string
+
+// loadCatalogs 从 dir 目录加载 <locale>.json 形式的消息目录，便于翻译人员直接编辑
+func loadCatalogs(dir string) (catalogs, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	c := make(catalogs)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		messages := make(map[string]string)
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("parse catalog %s: %v", file, err)
+		}
+		locale := strings.ToLower(strings.TrimSuffix(filepath.Base(file), ".json"))
+		c[locale] = messages
+		log.Printf("Loaded %d messages for locale %s", len(messages), locale)
+	}
+	if _, found := c[defaultLocale]; !found {
+		return nil, fmt.Errorf("catalog for default locale %q not found in %s", defaultLocale, dir)
+	}
+	return c, nil
+}
+
+// match 按 accept-language 中的权重顺序选择第一个可用的语言，例如 "zh-CN,zh;q=0.9,en;q=0.8"
+// 找不到时回退到英文
+func (c catalogs) match(acceptLanguage []string) string {
+	type tag struct {
+		name string
+		q    float64
+	}
+	var tags []tag
+	for _, header := range acceptLanguage {
+		for _, part := range strings.Split(header, ",") {
+			fields := strings.Split(strings.TrimSpace(part), ";")
+			t := tag{name: strings.ToLower(strings.TrimSpace(fields[0])), q: 1}
+			for _, param := range fields[1:] {
+				if v := strings.TrimSpace(param); strings.HasPrefix(v, "q=") {
+					if q, err := strconv.ParseFloat(v[2:], 64); err == nil {
+						t.q = q
+					}
+				}
+			}
+			if t.name != "" && t.q > 0 {
+				tags = append(tags, t)
+			}
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	for _, t := range tags {
+		if _, found := c[t.name]; found {
+			return t.name
+		}
+		// zh-CN 没有对应目录时使用 zh
+		if i := strings.Index(t.name, "-"); i > 0 {
+			if _, found := c[t.name[:i]]; found {
+				return t.name[:i]
+			}
+		}
+	}
+	return defaultLocale
+}
+
+// localize 为错误状态附加 LocalizedMessage 详情，已带有本地化消息的错误保持不变
+func (c catalogs) localize(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		st = status.FromContextError(err)
+	}
+	for _, d := range st.Details() {
+		if _, found := d.(*epb.LocalizedMessage); found {
+			return err
+		}
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	locale := c.match(md.Get(acceptLanguageKey))
+	message, found := c[locale][st.Code().String()]
+	if !found {
+		locale = defaultLocale
+		if message, found = c[locale][st.Code().String()]; !found {
+			return st.Err()
+		}
+	}
+	ds, detailErr := st.WithDetails(&epb.LocalizedMessage{Locale: locale, Message: message})
+	if detailErr != nil {
+		return st.Err()
+	}
+	return ds.Err()
+}
+
+// 为一元 RPC 返回的错误附加本地化消息
+func (c catalogs) unaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	m, err := handler(ctx, req)
+	return m, c.localize(ctx, err)
+}
+
+// 为流 RPC 返回的错误附加本地化消息
+func (c catalogs) streamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return c.localize(ss.Context(), handler(srv, ss))
+}
@@ -2,17 +2,23 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"github.com/golang/protobuf/ptypes/wrappers"
 	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
+	"graceful"
 	"io"
 	"log"
 	"net"
 	pb "ordermgt/server/ecommerce"
+	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,25 +27,36 @@ const (
 	orderBatchSize = 3
 )
 
-var orderMap = make(map[string]pb.Order)
+var localesDir = flag.String("locales", "locales", "directory of <locale>.json message catalogs")
+
+var (
+	orderMu  sync.RWMutex // 保护 orderMap，AddOrder、UpdateOrders 写入时其他 RPC 可能正在读取
+	orderMap = make(map[string]pb.Order)
+)
+
+// getOrder 返回订单的副本
+func getOrder(id string) pb.Order {
+	orderMu.RLock()
+	defer orderMu.RUnlock()
+	return orderMap[id]
+}
 
 type server struct {
 	orderMap map[string]*pb.Order
 }
 
 func (s *server) AddOrder(ctx context.Context, orderReq *pb.Order) (*wrappers.StringValue, error) {
-	if orderReq.Id == "-1" {
-		log.Printf("Order ID is invalid! -> Received Order ID %s", orderReq.Id)
-
-		errorStatus := status.New(codes.InvalidArgument, "Invalid information received")
-		ds, err := errorStatus.WithDetails(&epb.BadRequest_FieldViolation{Field: "ID",
-			Description: fmt.Sprintf("Order ID received is not valid %s : %s", orderReq.Id, orderReq.Description)})
-		if err != nil {
-			return nil, errorStatus.Err()
-		}
-		return nil, ds.Err()
+	// 请求在 validationUnaryServerInterceptor 中已完成校验
+	orderMu.Lock()
+	_, exists := orderMap[orderReq.Id]
+	if !exists {
+		orderMap[orderReq.Id] = *orderReq
+	}
+	orderMu.Unlock()
+	if exists {
+		log.Printf("Order : %s already exists", orderReq.Id)
+		return nil, alreadyExistsError(orderReq.Id)
 	}
-	orderMap[orderReq.Id] = *orderReq
 
 	sleepDuration := 5
 	log.Println("Sleeping for :", sleepDuration, "s")
@@ -53,11 +70,37 @@ func (s *server) AddOrder(ctx context.Context, orderReq *pb.Order) (*wrappers.St
 	return &wrappers.StringValue{Value: "Order Added: " + orderReq.Id}, nil
 }
 
+// alreadyExistsError 表示订单 ID 已被使用，以 ResourceInfo 详情指明冲突的订单
+func alreadyExistsError(orderId string) error {
+	errorStatus := status.Newf(codes.AlreadyExists, "Order %s already exists", orderId)
+	ds, err := errorStatus.WithDetails(&epb.ResourceInfo{
+		ResourceType: "ecommerce.Order",
+		ResourceName: orderId,
+		Description:  "order id is already in use",
+	})
+	if err != nil {
+		return errorStatus.Err()
+	}
+	return ds.Err()
+}
+
 func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) error {
 	batchMarker := 1
 	var combinedShipmentMap = make(map[string]pb.CombinedShipment)
+	orderIds := recvOrderIds(stream)
 	for {
-		orderId, err := stream.Recv()
+		var r graceful.Received
+		var ok bool
+		select {
+		case r, ok = <-orderIds:
+			if !ok { // 流的上下文已取消
+				return status.FromContextError(stream.Context().Err()).Err()
+			}
+		case <-shuttingDown:
+			return flushShipments(stream, combinedShipmentMap, orderIds, lookupOrder) // 服务器退出前发送已缓存的批次
+		}
+		orderId, _ := r.Msg.(*wrappers.StringValue)
+		err := r.Err
 		log.Printf("Reading Proc order ; %s", orderId)
 		if err == io.EOF {
 			log.Printf("EOF : %s", orderId)
@@ -73,16 +116,15 @@ func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) er
 			return err
 		}
 
-		destination := orderMap[orderId.GetValue()].Destination
+		ord := getOrder(orderId.GetValue())
+		destination := ord.Destination
 		shipment, found := combinedShipmentMap[destination]
 
 		if found {
-			ord := orderMap[orderId.GetValue()]
 			shipment.OrdersList = append(shipment.OrdersList, &ord)
 			combinedShipmentMap[destination] = shipment
 		} else {
-			comShip := pb.CombinedShipment{Id: "cmb - " + (orderMap[orderId.GetValue()].Description), Status: "Processed!"}
-			ord := orderMap[orderId.GetValue()]
+			comShip := pb.CombinedShipment{Id: "cmb - " + (ord.Description), Status: "Processed!"}
 			comShip.OrdersList = append(shipment.OrdersList, &ord)
 			combinedShipmentMap[destination] = comShip
 			log.Print(len(comShip.OrdersList), comShip.GetId())
@@ -104,25 +146,31 @@ func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) er
 }
 
 func (s *server) GetOrder(ctx context.Context, orderId *wrappers.StringValue) (*pb.Order, error) {
-	ord := orderMap[orderId.Value]
+	ord := getOrder(orderId.Value)
 	return &ord, nil
 }
 
 func (s *server) SearchOrders(searchQuery *wrappers.StringValue, strem pb.OrderManagement_SearchOrdersServer) error {
+	var matches []pb.Order
+	orderMu.RLock() // 持锁时只收集匹配的订单，发送可能阻塞，不能占用锁
 	for key, order := range orderMap {
 		log.Print(key, order)
 		for _, itemStr := range order.Items {
 			log.Print(itemStr)
 			if strings.Contains(itemStr, searchQuery.Value) {
-				err := strem.Send(&order) // 在流中发送匹配的订单
-				if err != nil {
-					return fmt.Errorf("error sending message to stream: %v", err)
-				}
-				log.Print("Matching Order Found: ", key)
+				matches = append(matches, order)
 				break
 			}
 		}
 	}
+	orderMu.RUnlock()
+	for i := range matches {
+		err := strem.Send(&matches[i]) // 在流中发送匹配的订单
+		if err != nil {
+			return fmt.Errorf("error sending message to stream: %v", err)
+		}
+		log.Print("Matching Order Found: ", matches[i].Id)
+	}
 	return nil
 }
 
@@ -133,7 +181,12 @@ func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) erro
 		if err == io.EOF {          // 检查流是否已经结束
 			return stream.SendAndClose(&wrappers.StringValue{Value: "Orders processed " + ordersStr}) // 服务端发送响应
 		}
+		if err != nil {
+			return err
+		}
+		orderMu.Lock()
 		orderMap[order.Id] = *order
+		orderMu.Unlock()
 
 		log.Println("Order ID ", order.Id, ": Updated")
 		ordersStr += order.Id + ","
@@ -141,16 +194,30 @@ func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) erro
 }
 
 func main() {
+	flag.Parse()
 	initSampleData()
+	messages, err := loadCatalogs(*localesDir)
+	if err != nil {
+		log.Fatalf("failed to load message catalogs: %v", err)
+	}
 	lis, err := net.Listen("tcp", port)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	s := grpc.NewServer()
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			messages.unaryServerInterceptor,  // 为错误附加本地化消息
+			validationUnaryServerInterceptor, // 校验请求消息
+		),
+		grpc.ChainStreamInterceptor(
+			messages.streamServerInterceptor,  // 为错误附加本地化消息
+			validationStreamServerInterceptor, // 校验流消息
+		),
+	)
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(s, healthServer)
 	pb.RegisterOrderManagementServer(s, &server{})
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
-	}
+	os.Exit(serve(s, lis, healthServer))
 }
 
 func initSampleData() {
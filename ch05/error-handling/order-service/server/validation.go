@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"math"
+	pb "ordermgt/server/ecommerce"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// check 校验 field 字段的值 v，返回全部违规项
+// field 是 JSON 路径风格的字段名，例如 ordersList[1].price
+type check func(field string, v interface{}) []*epb.BadRequest_FieldViolation
+
+// fieldRule 声明一个字段及其需要满足的校验规则
+type fieldRule struct {
+	field  string
+	value  func(m interface{}) interface{}
+	checks []check
+}
+
+var orderIdPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Order 的校验规则
+var orderRules = []fieldRule{
+	{"id", func(m interface{}) interface{} { return m.(*pb.Order).Id },
+		[]check{required, matches(orderIdPattern)}},
+	{"items", func(m interface{}) interface{} { return m.(*pb.Order).Items },
+		[]check{notEmpty, each(required)}}, // 同一商品可以在订单中出现多次
+	{"price", func(m interface{}) interface{} { return m.(*pb.Order).Price },
+		[]check{finite, nonNegative}},
+	{"destination", func(m interface{}) interface{} { return m.(*pb.Order).Destination },
+		[]check{required}},
+}
+
+// CombinedShipment 的校验规则
+var combinedShipmentRules = []fieldRule{
+	{"id", func(m interface{}) interface{} { return m.(*pb.CombinedShipment).Id },
+		[]check{required}},
+	{"status", func(m interface{}) interface{} { return m.(*pb.CombinedShipment).Status },
+		[]check{required}},
+	{"ordersList", func(m interface{}) interface{} { return m.(*pb.CombinedShipment).OrdersList },
+		[]check{notEmpty, each(nested(orderRules)), unique(func(v interface{}) string { return v.(*pb.Order).Id })}},
+}
+
+// rulesFor 返回消息类型对应的校验规则，没有规则的消息不做校验
+func rulesFor(m interface{}) []fieldRule {
+	switch m.(type) {
+	case *pb.Order:
+		return orderRules
+	case *pb.CombinedShipment:
+		return combinedShipmentRules
+	}
+	return nil
+}
+
+// validate 按规则校验消息，一次性返回所有违规项
+func validate(prefix string, m interface{}, rules []fieldRule) []*epb.BadRequest_FieldViolation {
+	var violations []*epb.BadRequest_FieldViolation
+	for _, rule := range rules {
+		field := rule.field
+		if prefix != "" {
+			field = prefix + "." + field
+		}
+		v := rule.value(m)
+		for _, c := range rule.checks {
+			violations = append(violations, c(field, v)...)
+		}
+	}
+	return violations
+}
+
+func violation(field, format string, a ...interface{}) []*epb.BadRequest_FieldViolation {
+	return []*epb.BadRequest_FieldViolation{{Field: field, Description: fmt.Sprintf(format, a...)}}
+}
+
+func required(field string, v interface{}) []*epb.BadRequest_FieldViolation {
+	if strings.TrimSpace(v.(string)) == "" {
+		return violation(field, "must not be empty")
+	}
+	return nil
+}
+
+func matches(re *regexp.Regexp) check {
+	return func(field string, v interface{}) []*epb.BadRequest_FieldViolation {
+		if s := v.(string); s != "" && !re.MatchString(s) {
+			return violation(field, "value %q does not match %s", s, re)
+		}
+		return nil
+	}
+}
+
+func finite(field string, v interface{}) []*epb.BadRequest_FieldViolation {
+	if f := float64(v.(float32)); math.IsNaN(f) || math.IsInf(f, 0) {
+		return violation(field, "must be a finite number, got %v", f)
+	}
+	return nil
+}
+
+func nonNegative(field string, v interface{}) []*epb.BadRequest_FieldViolation {
+	if f := v.(float32); f < 0 {
+		return violation(field, "must not be negative, got %v", f)
+	}
+	return nil
+}
+
+func notEmpty(field string, v interface{}) []*epb.BadRequest_FieldViolation {
+	if reflect.ValueOf(v).Len() == 0 {
+		return violation(field, "must contain at least one element")
+	}
+	return nil
+}
+
+// each 对列表中的每个元素执行校验，字段名带上元素下标
+func each(checks ...check) check {
+	return func(field string, v interface{}) []*epb.BadRequest_FieldViolation {
+		var violations []*epb.BadRequest_FieldViolation
+		list := reflect.ValueOf(v)
+		for i := 0; i < list.Len(); i++ {
+			elemField := fmt.Sprintf("%s[%d]", field, i)
+			for _, c := range checks {
+				violations = append(violations, c(elemField, list.Index(i).Interface())...)
+			}
+		}
+		return violations
+	}
+}
+
+// nested 使用嵌套消息自身的规则校验子消息
+func nested(rules []fieldRule) check {
+	return func(field string, v interface{}) []*epb.BadRequest_FieldViolation {
+		if reflect.ValueOf(v).IsNil() {
+			return violation(field, "must not be null")
+		}
+		return validate(field, v, rules)
+	}
+}
+
+// unique 检查列表中按 key 计算的值是否重复
+func unique(key func(v interface{}) string) check {
+	return func(field string, v interface{}) []*epb.BadRequest_FieldViolation {
+		var violations []*epb.BadRequest_FieldViolation
+		seen := make(map[string]int)
+		list := reflect.ValueOf(v)
+		for i := 0; i < list.Len(); i++ {
+			if list.Index(i).Kind() == reflect.Ptr && list.Index(i).IsNil() {
+				continue
+			}
+			k := key(list.Index(i).Interface())
+			if first, found := seen[k]; found {
+				violations = append(violations, violation(fmt.Sprintf("%s[%d]", field, i),
+					"duplicate of %s[%d] (%q)", field, first, k)...)
+				continue
+			}
+			seen[k] = i
+		}
+		return violations
+	}
+}
+
+// validationError 将违规项组装成携带 BadRequest 详情的错误状态
+func validationError(c codes.Code, violations []*epb.BadRequest_FieldViolation) error {
+	errorStatus := status.New(c, "Invalid information received")
+	ds, err := errorStatus.WithDetails(&epb.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return errorStatus.Err()
+	}
+	return ds.Err()
+}
+
+// 校验一元 RPC 的请求消息
+func validationUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if violations := validate("", req, rulesFor(req)); len(violations) > 0 {
+		log.Printf("%s : invalid request, %d violation(s)", info.FullMethod, len(violations))
+		return nil, validationError(codes.InvalidArgument, violations)
+	}
+	return handler(ctx, req)
+}
+
+// validatingStream 校验流中收发的每一条消息
+type validatingStream struct {
+	grpc.ServerStream
+	method string
+}
+
+func (w *validatingStream) RecvMsg(m interface{}) error {
+	if err := w.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if violations := validate("", m, rulesFor(m)); len(violations) > 0 {
+		log.Printf("%s : invalid message received, %d violation(s)", w.method, len(violations))
+		return validationError(codes.InvalidArgument, violations)
+	}
+	return nil
+}
+
+// 服务端发送的消息不合法属于服务端的问题，因此返回 Internal
+func (w *validatingStream) SendMsg(m interface{}) error {
+	if violations := validate("", m, rulesFor(m)); len(violations) > 0 {
+		log.Printf("%s : invalid message sent, %d violation(s)", w.method, len(violations))
+		return validationError(codes.Internal, violations)
+	}
+	return w.ServerStream.SendMsg(m)
+}
+
+func validationStreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &validatingStream{ServerStream: ss, method: info.FullMethod})
+}
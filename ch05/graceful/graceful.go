@@ -0,0 +1,71 @@
+// Package graceful 让 gRPC 服务器在收到 SIGINT/SIGTERM 时优雅退出。
+//
+// 收到信号后健康状态设为 NOT_SERVING，依次执行关闭回调，关闭 ShuttingDown 返回的信道通知长期存在的流结束，
+// 然后调用 GracefulStop 等待正在处理的 RPC 结束，超过排空期限或再次收到信号时调用 Stop。
+// Recv 和 Flush 供流式 RPC 在等待客户端时响应退出信号，并在退出前发送已经收到的数据。
+package graceful
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// 进程退出码
+const (
+	ExitOK           = 0 // 所有 RPC 在排空期限内结束
+	ExitServeFailed  = 1 // Serve 返回错误
+	ExitDrainTimeout = 2 // 排空超时（或再次收到信号），强制关闭了剩余的连接
+)
+
+var shuttingDown = make(chan struct{})
+
+// ShuttingDown 返回的信道在收到退出信号后关闭，流式 RPC 据此发送缓存的数据并结束
+func ShuttingDown() <-chan struct{} {
+	return shuttingDown
+}
+
+// Serve 运行 s 直到收到 SIGINT/SIGTERM，然后优雅退出，最多等待 drainTimeout。返回进程退出码
+func Serve(s *grpc.Server, lis net.Listener, healthServer *health.Server, drainTimeout time.Duration, onShutdown ...func()) int {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- s.Serve(lis) }()
+	select {
+	case err := <-serveErr:
+		log.Printf("failed to serve: %v", err)
+		return ExitServeFailed
+	case sig := <-sigs:
+		log.Printf("received %v, draining for up to %v", sig, drainTimeout)
+	}
+
+	healthServer.Shutdown() // 所有服务报告 NOT_SERVING，之后的状态更新被忽略
+	for _, f := range onShutdown {
+		f()
+	}
+	close(shuttingDown)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		log.Println("server stopped gracefully")
+		return ExitOK
+	case <-time.After(drainTimeout):
+		log.Printf("drain timed out after %v, forcing stop", drainTimeout)
+	case sig := <-sigs:
+		log.Printf("received %v again, forcing stop", sig)
+	}
+	s.Stop()
+	return ExitDrainTimeout
+}
@@ -0,0 +1,58 @@
+package graceful
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Received 是 Recv 从流中收到的一条消息
+type Received struct {
+	Msg interface{}
+	Err error
+}
+
+// Recv 在单独的协程中循环调用 recv 接收消息，使流式 RPC 在等待客户端时也能响应 ShuttingDown。
+// 信道容量为 1，处理函数还没有取走的消息留在信道中，退出时由 Flush 取出。
+// 收到错误（包括 io.EOF）后协程结束；ctx（流的上下文）被取消时协程关闭信道后退出，处理函数据此返回
+func Recv(ctx context.Context, recv func() (interface{}, error)) <-chan Received {
+	results := make(chan Received, 1)
+	go func() {
+		defer close(results)
+		for {
+			msg, err := recv()
+			select {
+			case results <- Received{Msg: msg, Err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return results
+}
+
+// Flush 在服务器退出前把已经从流中读出、还没有处理的消息依次交给 add，然后调用 send 发送缓存的数据，
+// 最后以 Unavailable 结束流，客户端需要在其他实例上重新提交尚未得到响应的数据
+func Flush(received <-chan Received, add func(msg interface{}) error, send func() error) error {
+	for pending := true; pending; {
+		select {
+		case r, ok := <-received:
+			if !ok || r.Err != nil {
+				pending = false
+				break
+			}
+			if err := add(r.Msg); err != nil {
+				return err
+			}
+		default:
+			pending = false
+		}
+	}
+	if err := send(); err != nil {
+		return err
+	}
+	return status.Error(codes.Unavailable, "server is shutting down")
+}
@@ -0,0 +1,63 @@
+package graceful
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"testing"
+	"time"
+)
+
+// 流的上下文被取消时，即使最后的错误没有送达，信道也会关闭，处理函数不会一直阻塞
+func TestRecvClosesOnCancel(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		received := Recv(ctx, func() (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		cancel()
+		select {
+		case r, ok := <-received:
+			if ok && r.Err == nil {
+				t.Fatalf("got message %v after cancellation, want error or closed channel", r.Msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("channel neither delivered the error nor closed after cancellation")
+		}
+	}
+}
+
+func TestFlush(t *testing.T) {
+	msgs := []interface{}{"a", "b"}
+	recv := func() (interface{}, error) {
+		if len(msgs) == 0 {
+			return nil, io.EOF
+		}
+		msg := msgs[0]
+		msgs = msgs[1:]
+		return msg, nil
+	}
+	received := Recv(context.Background(), recv)
+	time.Sleep(50 * time.Millisecond) // 让协程把第一条消息放入信道
+
+	var added []interface{}
+	sent := false
+	err := Flush(received, func(msg interface{}) error {
+		added = append(added, msg)
+		return nil
+	}, func() error {
+		sent = true
+		return nil
+	})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("Flush returned %v, want Unavailable", err)
+	}
+	if len(added) == 0 || added[0] != "a" {
+		t.Errorf("Flush added %v, want the pending message a first", added)
+	}
+	if !sent {
+		t.Error("Flush did not send the cached data")
+	}
+}
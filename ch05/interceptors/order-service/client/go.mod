@@ -2,13 +2,18 @@ module ordermgt/client
 
 go 1.17
 
-require google.golang.org/grpc v1.41.0
+require (
+	github.com/golang/protobuf v1.4.3
+	google.golang.org/grpc v1.41.0
+	registry v0.0.0
+)
 
 require (
-	github.com/golang/protobuf v1.4.3 // indirect
 	golang.org/x/net v0.0.0-20200822124328-c89045814202 // indirect
 	golang.org/x/sys v0.0.0-20200323222414-85ca7c5b95cd // indirect
 	golang.org/x/text v0.3.0 // indirect
 	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
 	google.golang.org/protobuf v1.25.0 // indirect
 )
+
+replace registry => ../../../registry
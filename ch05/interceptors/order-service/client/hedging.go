@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hedgingPolicy 与服务配置中的 hedgingPolicy 格式相同。grpc-go 只实现了 retryPolicy，
+// 对冲请求由客户端拦截器完成：先发送一次请求，每隔 hedgingDelay 再发送一次，直到达到 maxAttempts；
+// 第一个成功的响应被采用，其余请求被取消。收到 nonFatalStatusCodes 中的错误时立即发送下一次请求，
+// 收到其他错误时直接返回
+type hedgingPolicy struct {
+	MaxAttempts         int          `json:"maxAttempts"`
+	HedgingDelay        string       `json:"hedgingDelay"`
+	NonFatalStatusCodes []codes.Code `json:"nonFatalStatusCodes"` // 例如 "UNAVAILABLE"
+
+	delay    time.Duration
+	nonFatal map[codes.Code]bool
+}
+
+// serviceConfigJSON 只包含对冲需要的字段
+type serviceConfigJSON struct {
+	MethodConfig []struct {
+		Name []struct {
+			Service string `json:"service"`
+			Method  string `json:"method"`
+		} `json:"name"`
+		HedgingPolicy *hedgingPolicy `json:"hedgingPolicy"`
+	} `json:"methodConfig"`
+}
+
+// parseHedgingPolicies 从服务配置中取出各方法的对冲策略，key 为 "/service/method" 或 "/service/"
+func parseHedgingPolicies(config string) (map[string]*hedgingPolicy, error) {
+	policies := make(map[string]*hedgingPolicy)
+	if config == "" {
+		return policies, nil
+	}
+	var sc serviceConfigJSON
+	if err := json.Unmarshal([]byte(config), &sc); err != nil {
+		return nil, err
+	}
+	for _, mc := range sc.MethodConfig {
+		p := mc.HedgingPolicy
+		if p == nil {
+			continue
+		}
+		if p.MaxAttempts < 2 {
+			continue // 只有一次请求时无需对冲
+		}
+		if p.MaxAttempts > 5 {
+			p.MaxAttempts = 5 // 与 retryPolicy 的上限相同
+		}
+		if p.HedgingDelay != "" {
+			d, err := time.ParseDuration(p.HedgingDelay)
+			if err != nil {
+				return nil, err
+			}
+			p.delay = d
+		}
+		p.nonFatal = make(map[codes.Code]bool)
+		for _, c := range p.NonFatalStatusCodes {
+			p.nonFatal[c] = true
+		}
+		for _, n := range mc.Name {
+			policies["/"+n.Service+"/"+n.Method] = p
+		}
+	}
+	return policies, nil
+}
+
+// hedgingUnaryClientInterceptor 根据 serviceConfig 返回的服务配置对一元 RPC 执行对冲请求。
+// 只处理一元 RPC：流 RPC 的消息在各次尝试之间无法回放，为流方法配置的 hedgingPolicy 会被忽略，
+// 流方法应当使用 retryPolicy
+func hedgingUnaryClientInterceptor(serviceConfig func() string) grpc.UnaryClientInterceptor {
+	var mu sync.Mutex
+	var lastConfig string
+	policies := map[string]*hedgingPolicy{}
+
+	policyFor := func(method string) *hedgingPolicy {
+		mu.Lock()
+		defer mu.Unlock()
+		if config := serviceConfig(); config != lastConfig {
+			parsed, err := parseHedgingPolicies(config)
+			if err != nil {
+				log.Printf("invalid hedgingPolicy in service config: %v", err)
+			} else {
+				policies = parsed
+			}
+			lastConfig = config
+		}
+		if p, ok := policies[method]; ok {
+			return p
+		}
+		return policies[method[:strings.LastIndex(method, "/")+1]]
+	}
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		p := policyFor(method)
+		msg, ok := reply.(proto.Message)
+		if p == nil || !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		return hedge(ctx, p, msg, func(ctx context.Context, reply proto.Message) error {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		})
+	}
+}
+
+type attemptResult struct {
+	reply proto.Message
+	err   error
+}
+
+// hedge 按照策略并发发送请求，每次请求使用独立的响应消息，采用的响应复制到 reply
+func hedge(ctx context.Context, p *hedgingPolicy, reply proto.Message, call func(context.Context, proto.Message) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // 取消仍在进行的其他请求
+
+	results := make(chan attemptResult, p.MaxAttempts)
+	started, finished := 0, 0
+	start := func() {
+		started++
+		attemptReply := reflect.New(reflect.TypeOf(reply).Elem()).Interface().(proto.Message)
+		go func() {
+			results <- attemptResult{reply: attemptReply, err: call(ctx, attemptReply)}
+		}()
+	}
+	start()
+
+	timer := time.NewTimer(p.delay)
+	defer timer.Stop()
+	var lastErr error
+	for {
+		var next <-chan time.Time
+		if started < p.MaxAttempts {
+			next = timer.C
+		}
+		select {
+		case <-next:
+			start()
+			timer.Reset(p.delay)
+		case r := <-results:
+			finished++
+			if r.err == nil {
+				reply.Reset()
+				proto.Merge(reply, r.reply)
+				return nil
+			}
+			lastErr = r.err
+			if !p.nonFatal[status.Code(r.err)] {
+				return r.err
+			}
+			if started < p.MaxAttempts {
+				// 非致命错误：立即发送下一次请求
+				start()
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(p.delay)
+			} else if finished == started {
+				return lastErr
+			}
+		}
+	}
+}
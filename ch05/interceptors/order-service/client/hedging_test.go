@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/status"
+	"net"
+	pb "ordermgt/client/ecommerce"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const hedgingDelay = 100 * time.Millisecond
+
+// hedgingConfig 为 getOrder 配置对冲：第一次请求 hedgingDelay 内没有响应时向另一个后端再发送一次
+const hedgingConfig = `{
+  "loadBalancingConfig": [{"round_robin": {}}],
+  "methodConfig": [{
+    "name": [{"service": "ecommerce.OrderManagement", "method": "getOrder"}],
+    "hedgingPolicy": {"maxAttempts": 2, "hedgingDelay": "0.1s", "nonFatalStatusCodes": ["UNAVAILABLE"]}
+  }]
+}`
+
+// hedgingBackends 是两个订单服务后端共用的状态：订单 102 的第一次请求一直不响应，直到请求被取消；其他订单立即返回
+type hedgingBackends struct {
+	attempts  int32        // 订单 102 的请求次数
+	slow      atomic.Value // 收到订单 102 第一次请求的后端名称
+	cancelled chan error   // 慢请求结束时上下文的错误
+}
+
+type hedgingBackend struct {
+	pb.UnimplementedOrderManagementServer
+	name   string
+	shared *hedgingBackends
+}
+
+func (b *hedgingBackend) GetOrder(ctx context.Context, id *wrappers.StringValue) (*pb.Order, error) {
+	if id.Value == "102" && atomic.AddInt32(&b.shared.attempts, 1) == 1 {
+		b.shared.slow.Store(b.name)
+		select {
+		case <-ctx.Done():
+			b.shared.cancelled <- ctx.Err()
+			return nil, status.FromContextError(ctx.Err()).Err()
+		case <-time.After(10 * time.Second):
+			b.shared.cancelled <- nil
+			return &pb.Order{Id: id.Value, Description: b.name}, nil
+		}
+	}
+	return &pb.Order{Id: id.Value, Description: b.name}, nil
+}
+
+// startHedgingBackends 启动两个后端，通过 round_robin 连接它们，对冲请求发往另一个后端
+func startHedgingBackends(t *testing.T) (pb.OrderManagementClient, *hedgingBackends) {
+	t.Helper()
+	shared := &hedgingBackends{cancelled: make(chan error, 1)}
+	var addrs []resolver.Address
+	for _, name := range []string{"backend-1", "backend-2"} {
+		lis, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		s := grpc.NewServer()
+		pb.RegisterOrderManagementServer(s, &hedgingBackend{name: name, shared: shared})
+		go s.Serve(lis)
+		t.Cleanup(s.Stop)
+		addrs = append(addrs, resolver.Address{Addr: lis.Addr().String()})
+	}
+	r := manual.NewBuilderWithScheme("test")
+	r.InitialState(resolver.State{Addresses: addrs})
+	conn, err := grpc.Dial(r.Scheme()+":///ordermgt",
+		grpc.WithResolvers(r),
+		grpc.WithDefaultServiceConfig(hedgingConfig),
+		grpc.WithUnaryInterceptor(hedgingUnaryClientInterceptor(func() string { return hedgingConfig })),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	client := pb.NewOrderManagementClient(conn)
+
+	// 等待两个后端都进入 picker，之后 round_robin 交替选择它们
+	seen := make(map[string]bool)
+	for deadline := time.Now().Add(5 * time.Second); len(seen) < 2; {
+		if time.Now().After(deadline) {
+			t.Fatalf("backends not all reached, saw %v", seen)
+		}
+		order, err := client.GetOrder(context.Background(), &wrappers.StringValue{Value: "101"}, grpc.WaitForReady(true))
+		if err != nil {
+			t.Fatalf("getOrder: %v", err)
+		}
+		seen[order.GetDescription()] = true
+	}
+	return client, shared
+}
+
+// 第一个后端不响应时，hedgingDelay 后发往另一个后端的对冲请求先返回并被采用，慢的请求随即被取消
+func TestHedgingSlowFirstBackend(t *testing.T) {
+	client, backends := startHedgingBackends(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	order, err := client.GetOrder(ctx, &wrappers.StringValue{Value: "102"}, grpc.WaitForReady(true))
+	took := time.Since(start)
+	if err != nil {
+		t.Fatalf("getOrder: %v", err)
+	}
+	if n := atomic.LoadInt32(&backends.attempts); n != 2 {
+		t.Errorf("backends saw %d attempts, want 2", n)
+	}
+	if slow, _ := backends.slow.Load().(string); order.GetId() != "102" || order.GetDescription() == "" || order.GetDescription() == slow {
+		t.Errorf("reply = %v, want order 102 from the backend other than %s", order, slow)
+	}
+	if took < hedgingDelay || took > hedgingDelay+time.Second {
+		t.Errorf("getOrder took %v, want a little more than the hedging delay %v", took, hedgingDelay)
+	}
+
+	select {
+	case err := <-backends.cancelled:
+		if status.Code(status.FromContextError(err).Err()) != codes.Canceled {
+			t.Errorf("slow attempt ended with %v, want it cancelled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("slow attempt was not cancelled after the hedged attempt won")
+	}
+}
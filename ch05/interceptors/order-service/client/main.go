@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"flag"
 	"github.com/golang/protobuf/ptypes/wrappers"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 	"io"
 	"log"
 	pb "ordermgt/client/ecommerce"
@@ -12,13 +14,37 @@ import (
 
 const (
 	address = "localhost:50051"
+	// 服务端在 trailer 中返回的各跳截止时间预算和耗时
+	budgetTrailerKey = "x-deadline-budget"
 )
 
+var registryAddr = flag.String("registry", "", "address of the service registry, dials "+address+" directly when empty")
+
 func main() {
-	conn, err := grpc.Dial(address, grpc.WithInsecure(),
-		grpc.WithUnaryInterceptor(orderUnaryClientInterceptor), // 传入一元拦截器
-		grpc.WithStreamInterceptor(clientStreamInterceptor), // 注册流拦截器
-	)
+	flag.Parse()
+	target := address
+	opts := []grpc.DialOption{grpc.WithInsecure(),
+		grpc.WithChainUnaryInterceptor(
+			requestIdUnaryClientInterceptor, // 生成请求 ID，需要最先执行
+			orderUnaryClientInterceptor,     // 传入一元拦截器
+		),
+		grpc.WithChainStreamInterceptor(
+			requestIdStreamClientInterceptor, // 生成请求 ID，需要最先执行
+			clientStreamInterceptor,          // 注册流拦截器
+		),
+	}
+	if *registryAddr != "" {
+		registryConn, err := grpc.Dial(*registryAddr, grpc.WithInsecure())
+		if err != nil {
+			log.Fatalf("did not connect to registry: %v", err)
+		}
+		defer registryConn.Close()
+		target = registryTarget
+		opts = append(opts, registryDialOptions(registryConn)...)
+	}
+	dialCtx, dialCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer dialCancel()
+	conn, err := grpc.DialContext(dialCtx, target, opts...)
 	if err != nil {
 		log.Fatalf("did not connect: %v", err)
 	}
@@ -28,8 +54,15 @@ func main() {
 	defer cancel()
 
 	// 获取订单
-	retrievedOrder, err := orderMgtClient.GetOrder(ctx, &wrappers.StringValue{Value: "106"})
+	var trailer metadata.MD
+	retrievedOrder, err := orderMgtClient.GetOrder(ctx, &wrappers.StringValue{Value: "106"}, grpc.Trailer(&trailer))
+	if err != nil {
+		log.Printf("GetOrder failed: %v", err) // 重试或对冲请求都失败
+	}
 	log.Print("GetOrder Response -> : ", retrievedOrder)
+	for _, hop := range trailer.Get(budgetTrailerKey) {
+		log.Print("Deadline budget : ", hop)
+	}
 
 	searchStream, _ := orderMgtClient.SearchOrders(ctx, &wrappers.StringValue{Value: "Google"})
 	for {
@@ -37,6 +70,10 @@ func main() {
 		if err == io.EOF {
 			break
 		}
+		if err != nil {
+			log.Printf("SearchOrders failed: %v", err)
+			break
+		}
 		log.Print("Search Result: ", searchOrder)
 	}
 
@@ -108,6 +145,10 @@ func asyncClientBidirectionalRPC(streamProcOrder pb.OrderManagement_ProcessOrder
 		if errProcOrder == io.EOF {
 			break
 		}
+		if errProcOrder != nil {
+			log.Printf("ProcessOrders failed: %v", errProcOrder)
+			break
+		}
 		log.Print("Combined shipment : ", combinedShipment.OrdersList)
 	}
 	<-c
@@ -117,9 +158,9 @@ func asyncClientBidirectionalRPC(streamProcOrder pb.OrderManagement_ProcessOrder
 // RPC 上下文、方法字符串、要发送的请求、CallOption 配置
 func orderUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
 	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-	log.Println("Method: " + method)
+	logf(ctx, "Method: %s", method)
 	err := invoker(ctx, method, req, reply, cc, opts...) // 通过 UnaryInvoker 调用 RPC 方法
-	log.Println(reply)
+	logf(ctx, "%v", reply)
 	return err
 }
 
@@ -128,30 +169,31 @@ func orderUnaryClientInterceptor(ctx context.Context, method string, req, reply
 // method string,streamer Streamer, opts ...CallOption) (ClientStream, error)
 func clientStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
 	method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
-	log.Println("======= [Client Interceptor] ", method)
+	logf(ctx, "======= [Client Interceptor] %s", method)
 	s, err := streamer(ctx, desc, cc, method, opts...)
 	if err != nil {
 		return nil, err
 	}
-	return newWrappedStream(s), nil // 包装 ClientStream，使用拦截逻辑重载其方法并返回客户端应用程序
+	return newWrappedStream(ctx, s), nil // 包装 ClientStream，使用拦截逻辑重载其方法并返回客户端应用程序
 }
 
 type wrappedStream struct {
 	grpc.ClientStream
+	ctx context.Context // 用于日志；调用 ClientStream.Context() 会使 RPC 不再重试
 }
 
 func (w *wrappedStream) RecvMsg(m interface{}) error {
-	log.Printf("======= [Client Stream Interceptor] "+
+	logf(w.ctx, "======= [Client Stream Interceptor] "+
 		"Receive a message (Type: %T) at %v", m, time.Now().Format(time.RFC3339))
 	return w.ClientStream.RecvMsg(m)
 }
 
 func (w *wrappedStream) SendMsg(m interface{}) error {
-	log.Printf("====== [Client Stream Interceptor] " +
+	logf(w.ctx, "====== [Client Stream Interceptor] "+
 		"Send a message (Type: %T) at %v", m, time.Now().Format(time.RFC3339))
 	return w.ClientStream.SendMsg(m)
 }
 
-func newWrappedStream(s grpc.ClientStream) grpc.ClientStream {
-	return &wrappedStream{s}
+func newWrappedStream(ctx context.Context, s grpc.ClientStream) grpc.ClientStream {
+	return &wrappedStream{s, ctx}
 }
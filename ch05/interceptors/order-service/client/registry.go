@@ -0,0 +1,29 @@
+package main
+
+import (
+	"google.golang.org/grpc"
+	_ "google.golang.org/grpc/health" // 启用客户端健康检查
+	"registry"
+)
+
+// 服务端在注册中心中使用的服务名
+const (
+	registryService = "ordermgt"
+	registryTarget  = registry.Scheme + ":///" + registryService // "registry:///ordermgt"
+)
+
+// registryDialOptions 使用注册中心的 watch 解析器，实例上线、下线或心跳超时后地址列表会自动更新。
+// 注册中心下发的服务配置（超时、retryPolicy 等）优先于这里的默认服务配置，retryPolicy 由 grpc 默认启用；
+// grpc 不支持的 hedgingPolicy 由 hedgingUnaryClientInterceptor 执行，只对一元 RPC 生效
+func registryDialOptions(registryConn *grpc.ClientConn) []grpc.DialOption {
+	builder := registry.NewResolverBuilder(registryConn)
+	return []grpc.DialOption{
+		grpc.WithResolvers(builder),
+		grpc.WithBlock(), // 等待第一次解析完成，使第一个 RPC 就能使用注册中心下发的服务配置
+		// 健康检查报告 OrderManagement 为 NOT_SERVING 的实例（例如下游不可用）不会被选择
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin":{}}], "healthCheckConfig": {"serviceName": "ecommerce.OrderManagement"}}`),
+		grpc.WithChainUnaryInterceptor(hedgingUnaryClientInterceptor(func() string {
+			return builder.ServiceConfig(registryService)
+		})),
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"log"
+	"strconv"
+	"time"
+)
+
+// requestIdKey 是贯穿各跳调用的请求 ID 元数据 key
+const requestIdKey = "x-request-id"
+
+// requestIdFrom 从传出元数据中读取请求 ID
+func requestIdFrom(ctx context.Context) string {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if ids := md.Get(requestIdKey); len(ids) > 0 {
+		return ids[0]
+	}
+	return ""
+}
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// logf 输出带请求 ID 前缀的日志
+func logf(ctx context.Context, format string, v ...interface{}) {
+	log.Printf("[%s] %s", requestIdFrom(ctx), fmt.Sprintf(format, v...))
+}
+
+// outgoingRequestId 调用方没有指定请求 ID 时生成新的 ID
+func outgoingRequestId(ctx context.Context) context.Context {
+	if requestIdFrom(ctx) != "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, requestIdKey, newRequestId())
+}
+
+// 一元拦截器：为每次调用附加请求 ID，并检查服务端在响应头中回传的 ID
+func requestIdUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx = outgoingRequestId(ctx)
+	var header metadata.MD
+	err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&header))...)
+	if ids := header.Get(requestIdKey); len(ids) > 0 && ids[0] != requestIdFrom(ctx) {
+		logf(ctx, "server echoed a different request id %s", ids[0])
+	}
+	return err
+}
+
+// 流拦截器：为每个流附加请求 ID
+func requestIdStreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+	method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(outgoingRequestId(ctx), desc, cc, method, opts...)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"sync"
+	"time"
+)
+
+// 截止时间预算：调用下游服务时传递剩余时间减去 deadlineMargin（留给本服务处理下游响应），
+// 剩余时间不足 minBudget 时不再调用，直接返回 DeadlineExceeded。
+// 每一跳的预算和实际耗时写入响应 trailer 的 budgetTrailerKey 中，下游返回的记录会一并转发，便于排查超时
+const budgetTrailerKey = "x-deadline-budget"
+
+var (
+	deadlineMargin = flag.Duration("deadline-margin", 50*time.Millisecond, "time kept back from the remaining deadline when calling downstream services")
+	minBudget      = flag.Duration("min-budget", 20*time.Millisecond, "calls fail fast with DeadlineExceeded when less time than this remains")
+)
+
+// budget 是一次 RPC 的截止时间预算，记录本跳及下游各跳的消耗
+type budget struct {
+	mu      sync.Mutex
+	records []string
+}
+
+type budgetCtxKey struct{}
+
+func budgetFrom(ctx context.Context) *budget {
+	b, _ := ctx.Value(budgetCtxKey{}).(*budget)
+	return b
+}
+
+func (b *budget) add(records ...string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.records = append(b.records, records...)
+}
+
+func (b *budget) trailer() metadata.MD {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return metadata.MD{budgetTrailerKey: append([]string(nil), b.records...)}
+}
+
+// remaining 返回 ctx 的剩余时间，没有截止时间时 ok 为 false
+func remaining(ctx context.Context) (d time.Duration, ok bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+func formatBudget(d time.Duration, ok bool) string {
+	if !ok {
+		return "none"
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// checkBudget 在剩余时间不足 minBudget 时返回 DeadlineExceeded
+func checkBudget(ctx context.Context, method string) error {
+	if d, ok := remaining(ctx); ok && d < *minBudget {
+		return status.Errorf(codes.DeadlineExceeded, "%s: remaining budget %v below %v", method, d.Round(time.Millisecond), *minBudget)
+	}
+	return nil
+}
+
+// downstreamContext 返回调用下游服务使用的上下文，截止时间提前 deadlineMargin；预算不足时返回 DeadlineExceeded
+func downstreamContext(ctx context.Context, method string) (context.Context, context.CancelFunc, error) {
+	d, ok := remaining(ctx)
+	if !ok {
+		return ctx, func() {}, nil
+	}
+	if d-*deadlineMargin < *minBudget {
+		return nil, nil, status.Errorf(codes.DeadlineExceeded, "%s: remaining budget %v minus margin %v below %v",
+			method, d.Round(time.Millisecond), *deadlineMargin, *minBudget)
+	}
+	ctx, cancel := context.WithTimeout(ctx, d-*deadlineMargin)
+	return ctx, cancel, nil
+}
+
+// hopRecord 格式化一跳的记录，例如 "ordermgt:50051 /ecommerce.OrderManagement/GetOrder budget=1.998s used=12ms code=OK"
+func hopRecord(hop, method string, d time.Duration, ok bool, used time.Duration, err error) string {
+	return fmt.Sprintf("%s %s budget=%s used=%v code=%s", hop, method, formatBudget(d, ok), used.Round(time.Microsecond), status.Code(err))
+}
+
+func serverHop() string {
+	return "ordermgt" + *port
+}
+
+// 一元拦截器：预算不足时不执行处理函数，结束时将本跳和下游的记录写入 trailer。
+// 记录中的方法取自 grpc.Method，与客户端调用的路径相同，生成代码中一元方法的 FullMethod 大小写不同
+func budgetUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	start := time.Now()
+	method, _ := grpc.Method(ctx)
+	d, ok := remaining(ctx)
+	b := &budget{}
+	defer func() {
+		b.add(hopRecord(serverHop(), method, d, ok, time.Since(start), err))
+		if err := grpc.SetTrailer(ctx, b.trailer()); err != nil {
+			logf(ctx, "failed to set trailer: %v", err)
+		}
+	}()
+	if err := checkBudget(ctx, method); err != nil {
+		return nil, err
+	}
+	return handler(context.WithValue(ctx, budgetCtxKey{}, b), req)
+}
+
+// 流拦截器
+func budgetStreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	start := time.Now()
+	ctx := ss.Context()
+	method, _ := grpc.Method(ctx)
+	d, ok := remaining(ctx)
+	b := &budget{}
+	defer func() {
+		b.add(hopRecord(serverHop(), method, d, ok, time.Since(start), err))
+		ss.SetTrailer(b.trailer())
+	}()
+	if err := checkBudget(ctx, method); err != nil {
+		return err
+	}
+	return handler(srv, &budgetServerStream{ServerStream: ss, ctx: context.WithValue(ctx, budgetCtxKey{}, b)})
+}
+
+// budgetServerStream 使处理函数能够通过流的上下文取得预算
+type budgetServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *budgetServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// 下游调用的一元拦截器：缩短截止时间，记录下游调用的预算和耗时，并转发下游 trailer 中的记录
+func budgetUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	b := budgetFrom(ctx)
+	callCtx, cancel, err := downstreamContext(ctx, method)
+	if err != nil {
+		d, ok := remaining(ctx)
+		b.add(hopRecord(serverHop()+" ->", method, d-*deadlineMargin, ok, 0, err)) // 没有调用下游
+		return err
+	}
+	defer cancel()
+	d, ok := remaining(callCtx)
+	start := time.Now()
+	var trailer metadata.MD
+	err = invoker(callCtx, method, req, reply, cc, append(opts, grpc.Trailer(&trailer))...)
+	b.add(trailer.Get(budgetTrailerKey)...)
+	b.add(hopRecord(serverHop()+" ->", method, d, ok, time.Since(start), err))
+	return err
+}
+
+// 下游调用的流拦截器，流结束时记录
+func budgetStreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+	method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	b := budgetFrom(ctx)
+	callCtx, cancel, err := downstreamContext(ctx, method)
+	if err != nil {
+		d, ok := remaining(ctx)
+		b.add(hopRecord(serverHop()+" ->", method, d-*deadlineMargin, ok, 0, err)) // 没有调用下游
+		return nil, err
+	}
+	d, ok := remaining(callCtx)
+	s, err := streamer(callCtx, desc, cc, method, opts...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &budgetClientStream{ClientStream: s, budget: b, method: method, remaining: d, hasDeadline: ok,
+		start: time.Now(), cancel: cancel, serverStreams: desc.ServerStreams}, nil
+}
+
+// budgetClientStream 在流结束（RecvMsg 返回错误，或客户端流 RPC 收到响应）时记录本次调用
+type budgetClientStream struct {
+	grpc.ClientStream
+	budget        *budget
+	method        string
+	remaining     time.Duration
+	hasDeadline   bool
+	start         time.Time
+	cancel        context.CancelFunc
+	serverStreams bool
+	once          sync.Once
+}
+
+func (s *budgetClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || !s.serverStreams {
+		s.once.Do(func() {
+			result := err
+			if result == io.EOF {
+				result = nil
+			}
+			s.budget.add(s.Trailer().Get(budgetTrailerKey)...)
+			s.budget.add(hopRecord(serverHop()+" ->", s.method, s.remaining, s.hasDeadline, time.Since(s.start), result))
+			s.cancel()
+		})
+	}
+	return err
+}
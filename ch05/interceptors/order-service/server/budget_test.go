@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	pb "ordermgt/server/ecommerce"
+	ppb "ordermgt/server/productinfo"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// productInfoServer 是下游的 ProductInfo 服务：记录每次 GetProduct 看到的剩余时间，
+// 处理 delay 后像 ch02 的 ProductInfo 服务一样把本跳的记录写入 trailer
+type productInfoServer struct {
+	delay time.Duration
+
+	mu        sync.Mutex
+	remaining []time.Duration
+}
+
+func (s *productInfoServer) AddProduct(ctx context.Context, in *ppb.Product) (*ppb.ProductID, error) {
+	return &ppb.ProductID{Value: in.Name}, nil
+}
+
+func (s *productInfoServer) GetProduct(ctx context.Context, in *ppb.ProductID) (*ppb.Product, error) {
+	d, _ := remaining(ctx)
+	s.mu.Lock()
+	s.remaining = append(s.remaining, d)
+	s.mu.Unlock()
+	time.Sleep(s.delay)
+	method, _ := grpc.Method(ctx)
+	grpc.SetTrailer(ctx, metadata.Pairs(budgetTrailerKey, fmt.Sprintf("productinfo:test %s budget=%s", method, formatBudget(d, true))))
+	return &ppb.Product{Id: in.Value, Name: in.Value}, nil
+}
+
+func (s *productInfoServer) calls() []time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]time.Duration(nil), s.remaining...)
+}
+
+// startBudgetServers 在 bufconn 上启动下游 ProductInfo 和带全部拦截器的订单服务，
+// 订单服务使用与 run 相同的客户端拦截器调用下游，返回订单服务的客户端
+func startBudgetServers(t *testing.T, products *productInfoServer) pb.OrderManagementClient {
+	t.Helper()
+	dial := func(lis *bufconn.Listener, opts ...grpc.DialOption) *grpc.ClientConn {
+		conn, err := grpc.Dial("bufnet", append(opts, grpc.WithInsecure(),
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }))...)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+
+	productLis := bufconn.Listen(1 << 20)
+	ps := grpc.NewServer()
+	ppb.RegisterProductInfoServer(ps, products)
+	go ps.Serve(productLis)
+	t.Cleanup(ps.Stop)
+	productConn := dial(productLis,
+		grpc.WithChainUnaryInterceptor(requestIdUnaryClientInterceptor, budgetUnaryClientInterceptor),
+		grpc.WithChainStreamInterceptor(requestIdStreamClientInterceptor, budgetStreamClientInterceptor))
+
+	orderServer := &server{productClient: ppb.NewProductInfoClient(productConn)}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var err error
+	if orderServer.productIds, err = registerProducts(ctx, orderServer.productClient); err != nil {
+		t.Fatalf("registerProducts: %v", err)
+	}
+	orderLis := bufconn.Listen(1 << 20)
+	s := newServer()
+	pb.RegisterOrderManagementServer(s, orderServer)
+	go s.Serve(orderLis)
+	t.Cleanup(s.Stop)
+	return pb.NewOrderManagementClient(dial(orderLis))
+}
+
+// setBudgetFlags 在测试期间修改 -deadline-margin 和 -min-budget
+func setBudgetFlags(t *testing.T, margin, min time.Duration) {
+	oldMargin, oldMin := *deadlineMargin, *minBudget
+	*deadlineMargin, *minBudget = margin, min
+	t.Cleanup(func() { *deadlineMargin, *minBudget = oldMargin, oldMin })
+}
+
+func getOrderWithTrailer(client pb.OrderManagementClient, id string, timeout time.Duration) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var trailer metadata.MD
+	_, err := client.GetOrder(ctx, &wrappers.StringValue{Value: id}, grpc.Trailer(&trailer))
+	return trailer.Get(budgetTrailerKey), err
+}
+
+// hasRecord 判断记录中是否有以 prefix 开头并以 suffix 结尾的一条
+func hasRecord(records []string, prefix, suffix string) bool {
+	for _, r := range records {
+		if strings.HasPrefix(r, prefix) && strings.HasSuffix(r, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// 下游的截止时间比调用方的剩余时间提前 deadlineMargin，下游的记录随订单服务的 trailer 转发给调用方
+func TestBudgetPropagatesDeadline(t *testing.T) {
+	setBudgetFlags(t, 200*time.Millisecond, 20*time.Millisecond)
+	products := &productInfoServer{}
+	client := startBudgetServers(t, products)
+
+	const timeout = 2 * time.Second
+	records, err := getOrderWithTrailer(client, "102", timeout)
+	if err != nil {
+		t.Fatalf("getOrder: %v", err)
+	}
+	calls := products.calls()
+	if len(calls) != 2 {
+		t.Fatalf("ProductInfo saw %d calls, want one per item (2)", len(calls))
+	}
+	for _, d := range calls {
+		if d > timeout-*deadlineMargin || d < timeout-*deadlineMargin-500*time.Millisecond {
+			t.Errorf("downstream deadline %v, want a little less than %v", d, timeout-*deadlineMargin)
+		}
+	}
+
+	const getProduct = "/ecommerce.ProductInfo/getProduct"
+	n := 0
+	for _, r := range records {
+		if strings.HasPrefix(r, "productinfo:test "+getProduct) {
+			n++
+		}
+	}
+	if n != 2 {
+		t.Errorf("trailer has %d forwarded ProductInfo records, want 2: %q", n, records)
+	}
+	if !hasRecord(records, serverHop()+" -> "+getProduct, "code=OK") {
+		t.Errorf("trailer lacks the downstream call record: %q", records)
+	}
+	// 方法取自 grpc.Method，与客户端调用的路径大小写相同
+	if last := records[len(records)-1]; !strings.HasPrefix(last, serverHop()+" /ecommerce.OrderManagement/getOrder ") || !strings.HasSuffix(last, "code=OK") {
+		t.Errorf("last record = %q, want this hop's getOrder record", last)
+	}
+}
+
+// 第一次下游调用用掉大部分预算后，剩余时间减去余量不足 minBudget，第二次调用不发出，直接返回 DeadlineExceeded
+func TestBudgetFailsFastDownstream(t *testing.T) {
+	setBudgetFlags(t, 100*time.Millisecond, 100*time.Millisecond)
+	products := &productInfoServer{delay: 850 * time.Millisecond}
+	client := startBudgetServers(t, products)
+
+	records, err := getOrderWithTrailer(client, "102", time.Second)
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("getOrder = %v, want DeadlineExceeded", err)
+	}
+	if !strings.Contains(status.Convert(err).Message(), "minus margin") {
+		t.Errorf("error %q does not come from the budget check", err)
+	}
+	if n := len(products.calls()); n != 1 {
+		t.Errorf("ProductInfo saw %d calls, want 1", n)
+	}
+	if !hasRecord(records, serverHop()+" -> /ecommerce.ProductInfo/getProduct", "used=0s code=DeadlineExceeded") {
+		t.Errorf("trailer lacks the skipped downstream call: %q", records)
+	}
+	if !hasRecord(records, serverHop()+" /ecommerce.OrderManagement/getOrder", "code=DeadlineExceeded") {
+		t.Errorf("trailer lacks this hop's record: %q", records)
+	}
+}
+
+// 调用方传入的剩余时间已经不足 minBudget 时，不执行处理函数，也不调用下游
+func TestBudgetFailsFastOnArrival(t *testing.T) {
+	setBudgetFlags(t, 50*time.Millisecond, time.Second)
+	products := &productInfoServer{}
+	client := startBudgetServers(t, products)
+
+	records, err := getOrderWithTrailer(client, "102", 500*time.Millisecond)
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("getOrder = %v, want DeadlineExceeded", err)
+	}
+	if n := len(products.calls()); n != 0 {
+		t.Errorf("ProductInfo saw %d calls, want 0", n)
+	}
+	if len(records) != 1 || !hasRecord(records, serverHop()+" /ecommerce.OrderManagement/getOrder", "code=DeadlineExceeded") {
+		t.Errorf("trailer = %q, want only this hop's record", records)
+	}
+}
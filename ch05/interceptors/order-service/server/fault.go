@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"math/rand"
+	"strings"
+)
+
+// 故障注入：按比例直接返回 Unavailable，用于验证客户端的重试和对冲请求
+var failRate = flag.Float64("fail-rate", 0, "fraction of RPCs failed with Unavailable before reaching the handler, for retry testing")
+
+// injectFault 按 failRate 的比例返回 Unavailable。拦截器需在请求 ID 拦截器之前执行：已经收到响应头的 RPC 不会被客户端重试
+func injectFault(method string) error {
+	if strings.HasPrefix(method, "/grpc.health.") {
+		return nil // 健康检查不受影响，否则实例会被客户端摘除
+	}
+	if *failRate > 0 && rand.Float64() < *failRate {
+		log.Printf("injected fault: %s", method)
+		return status.Errorf(codes.Unavailable, "injected fault on %s", *port)
+	}
+	return nil
+}
+
+func faultUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := injectFault(info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func faultStreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := injectFault(info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"io/ioutil"
+	"net"
+	pb "ordermgt/server/ecommerce"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	initSampleData()
+	m.Run()
+}
+
+// attemptCounter 在故障注入之前记录服务端收到的每次尝试
+type attemptCounter struct {
+	mu       sync.Mutex
+	attempts map[string]int
+}
+
+func (c *attemptCounter) add(method string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attempts[method]++
+}
+
+func (c *attemptCounter) get(method string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.attempts[method]
+}
+
+// registryServiceConfig 读取注册中心为 ordermgt 下发的服务配置，测试与实际使用的 retryPolicy 一致
+func registryServiceConfig(t *testing.T) string {
+	t.Helper()
+	data, err := ioutil.ReadFile("../../../registry/service-configs.json")
+	if err != nil {
+		t.Fatalf("read service configs: %v", err)
+	}
+	var configs map[string]json.RawMessage
+	if err := json.Unmarshal(data, &configs); err != nil {
+		t.Fatalf("parse service configs: %v", err)
+	}
+	return string(configs[serviceName])
+}
+
+func startServer(t *testing.T) (pb.OrderManagementClient, *attemptCounter) {
+	t.Helper()
+	counter := &attemptCounter{attempts: make(map[string]int)}
+	s := newServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			method, _ := grpc.Method(ctx) // 与客户端调用的路径相同，生成代码中的 FullMethod 大小写不同
+			counter.add(method)
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			counter.add(info.FullMethod)
+			return handler(srv, ss)
+		}),
+	)
+	pb.RegisterOrderManagementServer(s, &server{})
+	healthpb.RegisterHealthServer(s, newHealthServer())
+	lis := bufconn.Listen(1 << 20)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithDefaultServiceConfig(registryServiceConfig(t)),
+	)
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewOrderManagementClient(conn), counter
+}
+
+// setFailRate 在测试期间修改 -fail-rate
+func setFailRate(t *testing.T, rate float64) {
+	old := *failRate
+	*failRate = rate
+	t.Cleanup(func() { *failRate = old })
+}
+
+func searchOrders(client pb.OrderManagementClient) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.SearchOrders(ctx, &wrappers.StringValue{Value: "Google"})
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for {
+		_, err := stream.Recv()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// 注入的 Unavailable 在响应头之前返回，searchOrders 按 retryPolicy 重试到 maxAttempts 次
+func TestRetryOnInjectedFault(t *testing.T) {
+	client, counter := startServer(t)
+	const method = "/ecommerce.OrderManagement/searchOrders"
+
+	setFailRate(t, 1)
+	if _, err := searchOrders(client); status.Code(err) != codes.Unavailable {
+		t.Fatalf("searchOrders with every attempt failing: %v, want Unavailable", err)
+	}
+	if got := counter.get(method); got != 4 {
+		t.Errorf("server saw %d attempts, want 4 (retryPolicy maxAttempts)", got)
+	}
+
+	*failRate = 0
+	n, err := searchOrders(client)
+	if err != nil || n == 0 {
+		t.Fatalf("searchOrders without faults returned %d orders, %v", n, err)
+	}
+	if got := counter.get(method); got != 5 {
+		t.Errorf("server saw %d attempts in total, want 5: a successful call is not retried", got)
+	}
+}
+
+// getOrder 没有 retryPolicy（对冲由客户端拦截器完成），注入的故障直接返回给调用方
+func TestNoRetryWithoutPolicy(t *testing.T) {
+	client, counter := startServer(t)
+	setFailRate(t, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.GetOrder(ctx, &wrappers.StringValue{Value: "106"}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("GetOrder: %v, want Unavailable", err)
+	}
+	if got := counter.get("/ecommerce.OrderManagement/getOrder"); got != 1 {
+		t.Errorf("server saw %d attempts, want 1", got)
+	}
+}
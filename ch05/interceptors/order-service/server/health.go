@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"log"
+)
+
+// 健康检查中 OrderManagement 服务的名称，"" 表示整个服务器
+const orderServiceName = "ecommerce.OrderManagement"
+
+// newHealthServer 创建 grpc.health.v1 健康检查服务，服务器和 OrderManagement 初始均为 SERVING
+func newHealthServer() *health.Server {
+	hs := health.NewServer()
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus(orderServiceName, healthpb.HealthCheckResponse_SERVING)
+	return hs
+}
+
+// watchDependency 跟踪下游连接的状态：下游不可用时 OrderManagement 报告 NOT_SERVING，
+// 客户端的负载均衡器会将请求发往其他实例；服务器整体状态不受影响
+func watchDependency(ctx context.Context, hs *health.Server, conn *grpc.ClientConn) {
+	state := conn.GetState()
+	for {
+		switch state {
+		case connectivity.Idle:
+			conn.Connect() // 连接失败后会回到 Idle，需要主动重连，状态保持不变
+		case connectivity.Ready:
+			hs.SetServingStatus(orderServiceName, healthpb.HealthCheckResponse_SERVING)
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			log.Printf("downstream %s is %v, %s is NOT_SERVING", conn.Target(), state, orderServiceName)
+			hs.SetServingStatus(orderServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return
+		}
+		state = conn.GetState()
+	}
+}
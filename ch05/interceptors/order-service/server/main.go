@@ -2,33 +2,57 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"github.com/golang/protobuf/ptypes/wrappers"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"graceful"
 	"io"
 	"log"
 	"net"
 	pb "ordermgt/server/ecommerce"
+	ppb "ordermgt/server/productinfo"
+	"os"
 	"strings"
 	"time"
 )
 
-const (
-	port           = ":50051"
-	orderBatchSize = 3
-)
+const orderBatchSize = 3
 
 var orderMap = make(map[string]pb.Order)
 
+var (
+	port            = flag.String("port", ":50051", "listen address, use different ports to run several instances")
+	productInfoAddr = flag.String("productinfo", "", "address of the downstream ProductInfo service, disabled when empty")
+	registryAddr    = flag.String("registry", "", "address of the service registry, the instance is not registered when empty")
+)
+
 type server struct {
-	orderMap map[string]*pb.Order
+	orderMap      map[string]*pb.Order
+	productClient ppb.ProductInfoClient // 下游 ProductInfo 服务
+	productIds    map[string]string     // 商品名称 -> ProductInfo 中的商品 ID
 }
 
 func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) error {
 	batchMarker := 1
 	var combinedShipmentMap = make(map[string]pb.CombinedShipment)
+	orderIds := recvOrderIds(stream)
 	for {
-		orderId, err := stream.Recv()
+		var r graceful.Received
+		var ok bool
+		select {
+		case r, ok = <-orderIds:
+			if !ok { // 流的上下文已取消
+				return status.FromContextError(stream.Context().Err()).Err()
+			}
+		case <-shuttingDown:
+			return flushShipments(stream, combinedShipmentMap, orderIds, lookupOrder) // 服务器退出前发送已缓存的批次
+		}
+		orderId, _ := r.Msg.(*wrappers.StringValue)
+		err := r.Err
 		log.Printf("Reading Proc order ; %s", orderId)
 		if err == io.EOF {
 			log.Printf("EOF : %s", orderId)
@@ -76,6 +100,25 @@ func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) er
 
 func (s *server) GetOrder(ctx context.Context, orderId *wrappers.StringValue) (*pb.Order, error) {
 	ord := orderMap[orderId.Value]
+	if s.productClient != nil {
+		// 调用下游服务查询商品信息，请求 ID 由 requestIdUnaryClientInterceptor 传递
+		for _, item := range ord.Items {
+			productId, found := s.productIds[item]
+			if !found {
+				logf(ctx, "Product %q is not registered with ProductInfo", item)
+				continue
+			}
+			product, err := s.productClient.GetProduct(ctx, &ppb.ProductID{Value: productId})
+			if status.Code(err) == codes.DeadlineExceeded {
+				return nil, err // 预算已经用完，不再查询其余商品
+			}
+			if err != nil {
+				logf(ctx, "GetProduct %q failed: %v", item, err)
+				continue
+			}
+			logf(ctx, "Product : %s", product)
+		}
+	}
 	return &ord, nil
 }
 
@@ -104,6 +147,10 @@ func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) erro
 		if err == io.EOF {          // 检查流是否已经结束
 			return stream.SendAndClose(&wrappers.StringValue{Value: "Orders processed " + ordersStr}) // 服务端发送响应
 		}
+		if err != nil { // 服务器强制停止、流被重置或取消时 Recv 返回其他错误
+			log.Println(err)
+			return err
+		}
 		orderMap[order.Id] = *order
 
 		log.Println("Order ID ", order.Id, ": Updated")
@@ -116,13 +163,13 @@ func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) erro
 func orderUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 	// 前置处理逻辑，可以拦截处理
 	// 检查传入的参数，获取当前 RPC 的信息
-	log.Println("========= [Server Interceptor] ", info.FullMethod)
+	logf(ctx, "========= [Server Interceptor] %s", info.FullMethod)
 
 	// 调用 UnaryHandler 完成一元 RPC 的正常执行
 	m, err := handler(ctx, req)
 
 	// 后置处理逻辑，这里可以处理响应信息
-	log.Printf(" Post Proc Message: %s", m)
+	logf(ctx, " Post Proc Message: %s", m)
 	return m, err // 发送响应
 }
 
@@ -134,13 +181,13 @@ type wrappedStream struct { // grpc.ServerStream 的包装器流
 
 // RecvMsg 处理流接收到的消息
 func (w *wrappedStream) RecvMsg(m interface{}) error {
-	log.Printf("======= [Server Stream Interceptor Wrapper] "+"Receive a message (Type: %T) at %s", m, time.Now().Format(time.RFC3339))
+	logf(w.Context(), "======= [Server Stream Interceptor Wrapper] "+"Receive a message (Type: %T) at %s", m, time.Now().Format(time.RFC3339))
 	return w.ServerStream.RecvMsg(m)
 }
 
 // SendMsg 处理流发送的消息
 func (w *wrappedStream) SendMsg(m interface{}) error {
-	log.Printf("======= [Server Stream Interceptor Wrapper] "+" Send a message (Type: %T) at %v", m,
+	logf(w.Context(), "======= [Server Stream Interceptor Wrapper] "+" Send a message (Type: %T) at %v", m,
 		time.Now().Format(time.RFC3339))
 	return w.ServerStream.SendMsg(m)
 }
@@ -152,28 +199,93 @@ func newWrappedStream(s grpc.ServerStream) grpc.ServerStream {
 
 // 流拦截器的实现
 func orderServerStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-	log.Println("======= [Server Stream Interceptor] ", info.FullMethod)
+	logf(ss.Context(), "======= [Server Stream Interceptor] %s", info.FullMethod)
 	err := handler(srv, newWrappedStream(ss))
 	if err != nil {
-		log.Printf("RPC failed with error %v", err)
+		logf(ss.Context(), "RPC failed with error %v", err)
 	}
 	return err
 }
 
 func main() {
+	flag.Parse()
+	os.Exit(run()) // os.Exit 不执行延迟调用，由 run 关闭连接后返回退出码
+}
+
+func run() int {
 	initSampleData()
-	lis, err := net.Listen("tcp", port)
+	orderServer := &server{}
+	healthServer := newHealthServer()
+	if *productInfoAddr != "" {
+		conn, err := grpc.Dial(*productInfoAddr, grpc.WithInsecure(),
+			grpc.WithChainUnaryInterceptor(
+				requestIdUnaryClientInterceptor, // 向下游传递请求 ID
+				budgetUnaryClientInterceptor,    // 传递扣除余量后的截止时间，记录下游耗时
+			),
+			grpc.WithChainStreamInterceptor(
+				requestIdStreamClientInterceptor, // 向下游传递请求 ID
+				budgetStreamClientInterceptor,    // 传递扣除余量后的截止时间，记录下游耗时
+			),
+		)
+		if err != nil {
+			log.Printf("did not connect: %v", err)
+			return graceful.ExitServeFailed
+		}
+		defer conn.Close()
+		orderServer.productClient = ppb.NewProductInfoClient(conn)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		orderServer.productIds, err = registerProducts(ctx, orderServer.productClient)
+		cancel()
+		if err != nil {
+			log.Printf("failed to register products with ProductInfo: %v", err)
+			return graceful.ExitServeFailed
+		}
+		go watchDependency(context.Background(), healthServer, conn)
+	}
+	lis, err := net.Listen("tcp", *port)
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		log.Printf("failed to listen: %v", err)
+		return graceful.ExitServeFailed
 	}
-	s := grpc.NewServer(
-		grpc.UnaryInterceptor(orderUnaryServerInterceptor),   // 注册一元拦截器
-		grpc.StreamInterceptor(orderServerStreamInterceptor), // 注册流拦截器
-	)
-	pb.RegisterOrderManagementServer(s, &server{})
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+	var onShutdown []func()
+	if *registryAddr != "" {
+		registrar, conn, err := register(*registryAddr, *port)
+		if err != nil {
+			log.Printf("failed to register: %v", err)
+			return graceful.ExitServeFailed
+		}
+		// 排空连接前先注销，客户端的解析器不再返回本实例
+		onShutdown = append(onShutdown, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := registrar.Close(ctx); err != nil {
+				log.Printf("failed to deregister: %v", err)
+			}
+			conn.Close()
+		})
 	}
+	s := newServer()
+	pb.RegisterOrderManagementServer(s, orderServer)
+	healthpb.RegisterHealthServer(s, healthServer)
+	return serve(s, lis, healthServer, onShutdown...)
+}
+
+// newServer 创建带有全部拦截器的 gRPC 服务器，opts 中的 grpc.UnaryInterceptor/StreamInterceptor 在这些拦截器之前执行
+func newServer(opts ...grpc.ServerOption) *grpc.Server {
+	return grpc.NewServer(append(opts,
+		grpc.ChainUnaryInterceptor(
+			faultUnaryServerInterceptor,     // 故障注入，默认关闭；需在发送响应头之前返回错误，客户端才会重试
+			requestIdUnaryServerInterceptor, // 确定请求 ID
+			budgetUnaryServerInterceptor,    // 截止时间预算，各跳耗时写入 trailer
+			orderUnaryServerInterceptor,     // 注册一元拦截器
+		),
+		grpc.ChainStreamInterceptor(
+			faultStreamServerInterceptor,     // 故障注入，默认关闭；需在发送响应头之前返回错误，客户端才会重试
+			requestIdStreamServerInterceptor, // 确定请求 ID
+			budgetStreamServerInterceptor,    // 截止时间预算，各跳耗时写入 trailer
+			orderServerStreamInterceptor,     // 注册流拦截器
+		),
+	)...)
 }
 
 func initSampleData() {
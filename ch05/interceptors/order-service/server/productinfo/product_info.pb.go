@@ -0,0 +1,254 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: productinfo/product_info.proto
+
+package ecommerce
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type Product struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Description          string   `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	Price                float32  `protobuf:"fixed32,4,opt,name=price,proto3" json:"price,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Product) Reset()         { *m = Product{} }
+func (m *Product) String() string { return proto.CompactTextString(m) }
+func (*Product) ProtoMessage()    {}
+func (*Product) Descriptor() ([]byte, []int) {
+	return fileDescriptor_76be3e1612675571, []int{0}
+}
+
+func (m *Product) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Product.Unmarshal(m, b)
+}
+func (m *Product) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Product.Marshal(b, m, deterministic)
+}
+func (m *Product) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Product.Merge(m, src)
+}
+func (m *Product) XXX_Size() int {
+	return xxx_messageInfo_Product.Size(m)
+}
+func (m *Product) XXX_DiscardUnknown() {
+	xxx_messageInfo_Product.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Product proto.InternalMessageInfo
+
+func (m *Product) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Product) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Product) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+func (m *Product) GetPrice() float32 {
+	if m != nil {
+		return m.Price
+	}
+	return 0
+}
+
+type ProductID struct {
+	Value                string   `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ProductID) Reset()         { *m = ProductID{} }
+func (m *ProductID) String() string { return proto.CompactTextString(m) }
+func (*ProductID) ProtoMessage()    {}
+func (*ProductID) Descriptor() ([]byte, []int) {
+	return fileDescriptor_76be3e1612675571, []int{1}
+}
+
+func (m *ProductID) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ProductID.Unmarshal(m, b)
+}
+func (m *ProductID) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ProductID.Marshal(b, m, deterministic)
+}
+func (m *ProductID) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProductID.Merge(m, src)
+}
+func (m *ProductID) XXX_Size() int {
+	return xxx_messageInfo_ProductID.Size(m)
+}
+func (m *ProductID) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProductID.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProductID proto.InternalMessageInfo
+
+func (m *ProductID) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*Product)(nil), "ecommerce.Product")
+	proto.RegisterType((*ProductID)(nil), "ecommerce.ProductID")
+}
+
+func init() { proto.RegisterFile("productinfo/product_info.proto", fileDescriptor_76be3e1612675571) }
+
+var fileDescriptor_76be3e1612675571 = []byte{
+	// 200 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x92, 0x2b, 0x28, 0xca, 0x4f,
+	0x29, 0x4d, 0x2e, 0xc9, 0xcc, 0x4b, 0xcb, 0xd7, 0x87, 0xb2, 0xe3, 0x41, 0x1c, 0xbd, 0x82, 0xa2,
+	0xfc, 0x92, 0x7c, 0x21, 0xce, 0xd4, 0xe4, 0xfc, 0xdc, 0xdc, 0xd4, 0xa2, 0xe4, 0x54, 0xa5, 0x54,
+	0x2e, 0xf6, 0x00, 0x88, 0x02, 0x21, 0x3e, 0x2e, 0xa6, 0xcc, 0x14, 0x09, 0x46, 0x05, 0x46, 0x0d,
+	0xce, 0x20, 0xa6, 0xcc, 0x14, 0x21, 0x21, 0x2e, 0x96, 0xbc, 0xc4, 0xdc, 0x54, 0x09, 0x26, 0xb0,
+	0x08, 0x98, 0x2d, 0xa4, 0xc0, 0xc5, 0x9d, 0x92, 0x5a, 0x9c, 0x5c, 0x94, 0x59, 0x50, 0x92, 0x99,
+	0x9f, 0x27, 0xc1, 0x0c, 0x96, 0x42, 0x16, 0x12, 0x12, 0xe1, 0x62, 0x2d, 0x28, 0xca, 0x4c, 0x4e,
+	0x95, 0x60, 0x51, 0x60, 0xd4, 0x60, 0x0a, 0x82, 0x70, 0x94, 0x14, 0xb9, 0x38, 0xa1, 0xd6, 0x78,
+	0xba, 0x80, 0x94, 0x94, 0x25, 0xe6, 0x94, 0xa6, 0x42, 0xed, 0x82, 0x70, 0x8c, 0x6a, 0xb9, 0xb8,
+	0x61, 0x4a, 0xf2, 0xd2, 0xf2, 0x85, 0xcc, 0xb8, 0xb8, 0x12, 0x53, 0x52, 0x60, 0x6e, 0x13, 0xd2,
+	0x83, 0x3b, 0x59, 0x0f, 0x2a, 0x26, 0x25, 0x82, 0x29, 0xe6, 0xe9, 0x02, 0xd2, 0x97, 0x9e, 0x5a,
+	0x02, 0xd3, 0x87, 0x55, 0x8d, 0x14, 0x16, 0xd3, 0x92, 0xd8, 0xc0, 0x41, 0x63, 0x0c, 0x18, 0x00,
+	0x21, 0x24, 0xe7, 0xca, 0x3c, 0x01, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// ProductInfoClient is the client API for ProductInfo service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type ProductInfoClient interface {
+	AddProduct(ctx context.Context, in *Product, opts ...grpc.CallOption) (*ProductID, error)
+	GetProduct(ctx context.Context, in *ProductID, opts ...grpc.CallOption) (*Product, error)
+}
+
+type productInfoClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewProductInfoClient(cc *grpc.ClientConn) ProductInfoClient {
+	return &productInfoClient{cc}
+}
+
+func (c *productInfoClient) AddProduct(ctx context.Context, in *Product, opts ...grpc.CallOption) (*ProductID, error) {
+	out := new(ProductID)
+	err := c.cc.Invoke(ctx, "/ecommerce.ProductInfo/addProduct", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productInfoClient) GetProduct(ctx context.Context, in *ProductID, opts ...grpc.CallOption) (*Product, error) {
+	out := new(Product)
+	err := c.cc.Invoke(ctx, "/ecommerce.ProductInfo/getProduct", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ProductInfoServer is the server API for ProductInfo service.
+type ProductInfoServer interface {
+	AddProduct(context.Context, *Product) (*ProductID, error)
+	GetProduct(context.Context, *ProductID) (*Product, error)
+}
+
+func RegisterProductInfoServer(s *grpc.Server, srv ProductInfoServer) {
+	s.RegisterService(&_ProductInfo_serviceDesc, srv)
+}
+
+func _ProductInfo_AddProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Product)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductInfoServer).AddProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ecommerce.ProductInfo/AddProduct",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductInfoServer).AddProduct(ctx, req.(*Product))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductInfo_GetProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProductID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductInfoServer).GetProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ecommerce.ProductInfo/GetProduct",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductInfoServer).GetProduct(ctx, req.(*ProductID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _ProductInfo_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "ecommerce.ProductInfo",
+	HandlerType: (*ProductInfoServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "addProduct",
+			Handler:    _ProductInfo_AddProduct_Handler,
+		},
+		{
+			MethodName: "getProduct",
+			Handler:    _ProductInfo_GetProduct_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "productinfo/product_info.proto",
+}
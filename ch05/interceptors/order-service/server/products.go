@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	ppb "ordermgt/server/productinfo"
+)
+
+// registerProducts 把示例订单中的商品添加到 ProductInfo 服务，返回商品名称到商品 ID 的映射。
+// ProductInfo 的商品 ID 是 addProduct 时生成的 UUID，不能直接用商品名称查询
+func registerProducts(ctx context.Context, client ppb.ProductInfoClient) (map[string]string, error) {
+	ids := make(map[string]string)
+	for _, order := range orderMap {
+		for _, item := range order.Items {
+			if _, found := ids[item]; found {
+				continue
+			}
+			id, err := client.AddProduct(ctx, &ppb.Product{Name: item})
+			if err != nil {
+				return nil, err
+			}
+			ids[item] = id.GetValue()
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"log"
+	"registry"
+	"registry/registrypb"
+	"strings"
+	"time"
+)
+
+// 在注册中心中使用的服务名，客户端通过 registry:///ordermgt 发现所有实例
+const serviceName = "ordermgt"
+
+const registryTTL = 10 * time.Second
+
+// register 将当前实例注册到注册中心，返回的 Registrar 会持续发送心跳，退出前需要 Close
+func register(registryAddr, listenAddr string) (*registry.Registrar, *grpc.ClientConn, error) {
+	conn, err := grpc.Dial(registryAddr, grpc.WithInsecure())
+	if err != nil {
+		return nil, nil, err
+	}
+	addr := listenAddr
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr // ":50051" 注册为 "localhost:50051"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r, err := registry.Register(ctx, conn, &registrypb.Instance{Service: serviceName, Addr: addr}, registryTTL)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	log.Printf("registered %s as %s with registry %s", addr, serviceName, registryAddr)
+	return r, conn, nil
+}
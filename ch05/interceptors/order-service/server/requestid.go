@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"log"
+	"strconv"
+	"time"
+)
+
+// requestIdKey 是贯穿各跳调用的请求 ID 元数据 key
+const requestIdKey = "x-request-id"
+
+type requestIdCtxKey struct{}
+
+// withRequestId 将请求 ID 保存到上下文中，供处理函数、日志和下游调用使用
+func withRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdCtxKey{}, id)
+}
+
+// requestIdFrom 依次从上下文、传出元数据中查找请求 ID
+func requestIdFrom(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIdCtxKey{}).(string); ok {
+		return id
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if ids := md.Get(requestIdKey); len(ids) > 0 {
+		return ids[0]
+	}
+	return ""
+}
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// logf 输出带请求 ID 前缀的日志
+func logf(ctx context.Context, format string, v ...interface{}) {
+	log.Printf("[%s] %s", requestIdFrom(ctx), fmt.Sprintf(format, v...))
+}
+
+// incomingRequestId 读取客户端传入的请求 ID，没有时生成新的 ID
+func incomingRequestId(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ids := md.Get(requestIdKey); len(ids) > 0 && ids[0] != "" {
+		return withRequestId(ctx, ids[0])
+	}
+	return withRequestId(ctx, newRequestId())
+}
+
+// 一元拦截器：确定请求 ID 并在响应头中回传
+func requestIdUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx = incomingRequestId(ctx)
+	if err := grpc.SetHeader(ctx, metadata.Pairs(requestIdKey, requestIdFrom(ctx))); err != nil {
+		logf(ctx, "failed to set header: %v", err)
+	}
+	return handler(ctx, req)
+}
+
+// requestIdServerStream 替换流的上下文，使处理函数能够取得请求 ID
+type requestIdServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *requestIdServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// 流拦截器：确定请求 ID 并在响应头中回传
+func requestIdStreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := incomingRequestId(ss.Context())
+	if err := ss.SetHeader(metadata.Pairs(requestIdKey, requestIdFrom(ctx))); err != nil {
+		logf(ctx, "failed to set header: %v", err)
+	}
+	return handler(srv, &requestIdServerStream{ServerStream: ss, ctx: ctx})
+}
+
+// outgoingRequestId 将请求 ID 写入传出元数据，已经存在时保持不变
+func outgoingRequestId(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if len(md.Get(requestIdKey)) > 0 {
+		return ctx
+	}
+	id := requestIdFrom(ctx)
+	if id == "" {
+		id = newRequestId()
+		ctx = withRequestId(ctx, id)
+	}
+	return metadata.AppendToOutgoingContext(ctx, requestIdKey, id)
+}
+
+// 下游调用的一元拦截器：将当前请求的 ID 传递给下游服务（例如 ProductInfo）
+func requestIdUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx = outgoingRequestId(ctx)
+	logf(ctx, "calling downstream %s", method)
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// 下游调用的流拦截器
+func requestIdStreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+	method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	ctx = outgoingRequestId(ctx)
+	logf(ctx, "calling downstream %s", method)
+	return streamer(ctx, desc, cc, method, opts...)
+}
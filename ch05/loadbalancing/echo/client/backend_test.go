@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/status"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testBackend 是在测试进程中运行的 Echo 后端，通过健康状态或注入错误模拟故障
+type testBackend struct {
+	ecpb.UnimplementedEchoServer
+	addr     string
+	latency  time.Duration // 每个请求的处理耗时
+	health   *health.Server
+	failing  int32 // 非 0 时所有请求以 Unavailable 失败，健康检查仍然正常
+	requests int64 // 收到的请求数
+}
+
+func (b *testBackend) UnaryEcho(ctx context.Context, req *ecpb.EchoRequest) (*ecpb.EchoResponse, error) {
+	atomic.AddInt64(&b.requests, 1)
+	if atomic.LoadInt32(&b.failing) != 0 {
+		return nil, status.Error(codes.Unavailable, "injected failure")
+	}
+	if b.latency > 0 {
+		select {
+		case <-time.After(b.latency):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return &ecpb.EchoResponse{Message: req.GetMessage()}, nil
+}
+
+// setServing 修改后端的整体健康状态，客户端健康检查会据此让 SubConn 进入或离开 Ready
+func (b *testBackend) setServing(serving bool) {
+	s := healthpb.HealthCheckResponse_SERVING
+	if !serving {
+		s = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	b.health.SetServingStatus("", s)
+}
+
+// setFailing 开始或停止注入错误
+func (b *testBackend) setFailing(failing bool) {
+	var v int32
+	if failing {
+		v = 1
+	}
+	atomic.StoreInt32(&b.failing, v)
+}
+
+// startTestBackend 在随机端口上启动后端，测试结束时关闭
+func startTestBackend(t *testing.T, latency time.Duration) *testBackend {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	b := &testBackend{addr: lis.Addr().String(), latency: latency, health: health.NewServer()}
+	s := grpc.NewServer()
+	ecpb.RegisterEchoServer(s, b)
+	healthpb.RegisterHealthServer(s, b.health)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return b
+}
+
+// dialBackends 通过手动解析器连接 addrs，使用 policy 负载均衡策略
+func dialBackends(t *testing.T, policy string, params map[string]interface{}, addrs []resolver.Address) ecpb.EchoClient {
+	t.Helper()
+	r := manual.NewBuilderWithScheme("test")
+	r.InitialState(resolver.State{Addresses: addrs})
+	conn, err := grpc.Dial(r.Scheme()+":///echo",
+		grpc.WithResolvers(r),
+		grpc.WithDefaultServiceConfig(serviceConfig(policy, params)),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return ecpb.NewEchoClient(conn)
+}
+
+// callPeer 发送一个 RPC 并返回处理它的后端地址，kv 是附加到请求元数据中的键值对
+func callPeer(t *testing.T, client ecpb.EchoClient, kv ...string) string {
+	t.Helper()
+	var p peer.Peer
+	ctx, cancel := context.WithTimeout(metadata.AppendToOutgoingContext(context.Background(), kv...), 5*time.Second)
+	defer cancel()
+	if _, err := client.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "test"}, grpc.Peer(&p), grpc.WaitForReady(true)); err != nil {
+		t.Fatalf("UnaryEcho: %v", err)
+	}
+	return p.Addr.String()
+}
+
+// countPeers 依次发送 n 个 RPC，返回各后端处理的数量
+func countPeers(t *testing.T, client ecpb.EchoClient, n int) map[string]int {
+	t.Helper()
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[callPeer(t, client)]++
+	}
+	return counts
+}
+
+// waitForPeers 发送 RPC 直到 want 中的后端都处理过请求，即它们的 SubConn 都已进入 picker
+func waitForPeers(t *testing.T, client ecpb.EchoClient, want ...string) {
+	t.Helper()
+	seen := make(map[string]bool)
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		seen[callPeer(t, client)] = true
+		missing := false
+		for _, addr := range want {
+			missing = missing || !seen[addr]
+		}
+		if !missing {
+			return
+		}
+	}
+	t.Fatalf("backends %v not all reached, saw %v", want, seen)
+}
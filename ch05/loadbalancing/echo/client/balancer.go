@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+	"log"
+	"reflect"
+	"sync"
+)
+
+// attrBalancer 与 grpc 的 base 负载均衡器类似：为每个地址维护一个 SubConn，由 PickerBuilder 根据
+// 就绪的 SubConn 生成 picker。不同之处在于解析器推送的地址属性（例如权重、可用区）发生变化时
+// 也会重新生成 picker，使自定义负载均衡器能够立即使用新的属性
+type attrBalancerBuilder struct {
+	name              string
+	newPickerBuilder  func() base.PickerBuilder
+	config            base.Config
+	parsePickerConfig func(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error)
+}
+
+// newBalancerBuilder 创建负载均衡器，每个 ClientConn 通过 newPickerBuilder 得到自己的 PickerBuilder，
+// 因此 PickerBuilder 可以在多次生成 picker 之间保存状态（例如每个 SubConn 的请求数）。
+// 通过 balancer.Register 注册后可在服务配置中以 name 引用
+func newBalancerBuilder(name string, newPickerBuilder func() base.PickerBuilder, config base.Config) *attrBalancerBuilder {
+	return &attrBalancerBuilder{name: name, newPickerBuilder: newPickerBuilder, config: config}
+}
+
+// withConfigParser 设置 PickerBuilder 参数的解析函数，解析结果通过 configurablePickerBuilder.UpdateConfig 传入
+func (b *attrBalancerBuilder) withConfigParser(parse func(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error)) *attrBalancerBuilder {
+	b.parsePickerConfig = parse
+	return b
+}
+
+// attrBalancerConfig 是所有 attrBalancer 共用的配置，outlierDetection 可以和各负载均衡器自己的参数写在一起，例如：
+// {"loadBalancingConfig": [{"least_request": {"outlierDetection": {"interval": "1s", "errorRateThreshold": 0.5}}}]}
+type attrBalancerConfig struct {
+	serviceconfig.LoadBalancingConfig
+
+	outlierDetection *outlierDetectionConfig
+	picker           serviceconfig.LoadBalancingConfig
+}
+
+func (b *attrBalancerBuilder) ParseConfig(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	var raw struct {
+		OutlierDetection *outlierDetectionConfig `json:"outlierDetection"`
+	}
+	if err := json.Unmarshal(js, &raw); err != nil {
+		return nil, fmt.Errorf("%s: invalid config %s: %v", b.name, js, err)
+	}
+	cfg := &attrBalancerConfig{outlierDetection: raw.OutlierDetection}
+	if cfg.outlierDetection != nil {
+		if err := cfg.outlierDetection.validate(); err != nil {
+			return nil, fmt.Errorf("%s: invalid outlierDetection: %v", b.name, err)
+		}
+	}
+	if b.parsePickerConfig != nil {
+		picker, err := b.parsePickerConfig(js)
+		if err != nil {
+			return nil, err
+		}
+		cfg.picker = picker
+	}
+	return cfg, nil
+}
+
+func (b *attrBalancerBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	return &attrBalancer{
+		name:          b.name,
+		cc:            cc,
+		pickerBuilder: b.newPickerBuilder(),
+		config:        b.config,
+		subConns:      make(map[resolver.Address]*subConnInfo),
+		scStates:      make(map[balancer.SubConn]connectivity.State),
+		csEvltr:       &balancer.ConnectivityStateEvaluator{},
+		state:         connectivity.Connecting,
+		picker:        base.NewErrPicker(balancer.ErrNoSubConnAvailable),
+	}
+}
+
+func (b *attrBalancerBuilder) Name() string { return b.name }
+
+// configurablePickerBuilder 是需要服务配置中负载均衡参数的 PickerBuilder，
+// UpdateConfig 返回 true 表示配置发生了变化，需要重新生成 picker
+type configurablePickerBuilder interface {
+	base.PickerBuilder
+	UpdateConfig(cfg serviceconfig.LoadBalancingConfig) bool
+}
+
+// addressAwarePickerBuilder 是需要全部地址（包括未就绪的）的 PickerBuilder，例如按可用区计算健康比例，
+// UpdateAddresses 返回 true 表示需要重新生成 picker
+type addressAwarePickerBuilder interface {
+	base.PickerBuilder
+	UpdateAddresses(addrs []resolver.Address) bool
+}
+
+type subConnInfo struct {
+	subConn balancer.SubConn
+	attrs   *attributes.Attributes
+}
+
+type attrBalancer struct {
+	name          string
+	cc            balancer.ClientConn
+	pickerBuilder base.PickerBuilder
+	config        base.Config
+
+	subConns map[resolver.Address]*subConnInfo // key 为去掉属性后的地址
+	scStates map[balancer.SubConn]connectivity.State
+	csEvltr  *balancer.ConnectivityStateEvaluator
+	state    connectivity.State
+	picker   balancer.Picker
+
+	resolverErr error
+	connErr     error
+
+	// 启用离群检测后定时器协程也会修改状态并生成 picker，所有方法都需要持有 mu
+	mu      sync.Mutex
+	outlier *outlierDetector
+}
+
+func (b *attrBalancer) UpdateClientConnState(s balancer.ClientConnState) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.resolverErr = nil
+	regenerate := false
+	if cfg, ok := s.BalancerConfig.(*attrBalancerConfig); ok {
+		if pb, ok := b.pickerBuilder.(configurablePickerBuilder); ok && cfg.picker != nil {
+			regenerate = pb.UpdateConfig(cfg.picker)
+		}
+		if b.updateOutlierDetection(cfg.outlierDetection) {
+			regenerate = true
+		}
+	}
+	if pb, ok := b.pickerBuilder.(addressAwarePickerBuilder); ok && pb.UpdateAddresses(s.ResolverState.Addresses) {
+		regenerate = true
+	}
+	addrsSet := make(map[resolver.Address]bool)
+	for _, a := range s.ResolverState.Addresses {
+		key := a
+		key.Attributes = nil
+		addrsSet[key] = true
+		info, ok := b.subConns[key]
+		if !ok {
+			sc, err := b.cc.NewSubConn([]resolver.Address{a}, balancer.NewSubConnOptions{HealthCheckEnabled: b.config.HealthCheck})
+			if err != nil {
+				log.Printf("balancer: failed to create SubConn for %s: %v", a.Addr, err)
+				continue
+			}
+			b.subConns[key] = &subConnInfo{subConn: sc, attrs: a.Attributes}
+			b.scStates[sc] = connectivity.Idle
+			b.csEvltr.RecordTransition(connectivity.Shutdown, connectivity.Idle)
+			sc.Connect()
+			continue
+		}
+		if !reflect.DeepEqual(info.attrs, a.Attributes) {
+			info.attrs = a.Attributes
+			regenerate = b.scStates[info.subConn] == connectivity.Ready || regenerate
+		}
+		b.cc.UpdateAddresses(info.subConn, []resolver.Address{a})
+	}
+	for key, info := range b.subConns {
+		if !addrsSet[key] {
+			b.cc.RemoveSubConn(info.subConn)
+			delete(b.subConns, key)
+			if b.outlier != nil {
+				b.outlier.remove(info.subConn)
+			}
+			// scStates 中的记录在 SubConn 变为 Shutdown 时删除
+		}
+	}
+	if len(s.ResolverState.Addresses) == 0 {
+		b.resolverErrorLocked(errors.New("produced zero addresses"))
+		return balancer.ErrBadResolverState
+	}
+	if regenerate {
+		b.regeneratePicker()
+		b.cc.UpdateState(balancer.State{ConnectivityState: b.state, Picker: b.picker})
+	}
+	return nil
+}
+
+func (b *attrBalancer) ResolverError(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.resolverErrorLocked(err)
+}
+
+// resolverErrorLocked 记录解析器错误，没有可用的 SubConn 时进入 TransientFailure，调用者需持有 mu
+func (b *attrBalancer) resolverErrorLocked(err error) {
+	b.resolverErr = err
+	if len(b.subConns) == 0 {
+		b.state = connectivity.TransientFailure
+	}
+	if b.state != connectivity.TransientFailure {
+		return
+	}
+	b.regeneratePicker()
+	b.cc.UpdateState(balancer.State{ConnectivityState: b.state, Picker: b.picker})
+}
+
+// regeneratePicker 在 TransientFailure 时返回错误 picker，否则由 pickerBuilder 根据就绪的 SubConn 生成
+func (b *attrBalancer) regeneratePicker() {
+	if b.state == connectivity.TransientFailure {
+		b.picker = base.NewErrPicker(b.mergeErrors())
+		return
+	}
+	readySCs := make(map[balancer.SubConn]base.SubConnInfo)
+	for key, info := range b.subConns {
+		if b.scStates[info.subConn] == connectivity.Ready {
+			addr := key
+			addr.Attributes = info.attrs
+			readySCs[info.subConn] = base.SubConnInfo{Address: addr}
+		}
+	}
+	if b.outlier == nil {
+		b.picker = b.pickerBuilder.Build(base.PickerBuildInfo{ReadySCs: readySCs})
+		return
+	}
+	b.picker = b.outlier.wrap(b.pickerBuilder.Build(base.PickerBuildInfo{ReadySCs: b.outlier.filter(readySCs)}))
+}
+
+func (b *attrBalancer) mergeErrors() error {
+	switch {
+	case b.connErr == nil:
+		return fmt.Errorf("last resolver error: %v", b.resolverErr)
+	case b.resolverErr == nil:
+		return fmt.Errorf("last connection error: %v", b.connErr)
+	}
+	return fmt.Errorf("last connection error: %v; last resolver error: %v", b.connErr, b.resolverErr)
+}
+
+func (b *attrBalancer) UpdateSubConnState(sc balancer.SubConn, state balancer.SubConnState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := state.ConnectivityState
+	oldS, ok := b.scStates[sc]
+	if !ok {
+		return
+	}
+	// 进入 TransientFailure 后忽略 Idle/Connecting，避免所有后端都不可用时整体状态一直是 Connecting
+	if oldS == connectivity.TransientFailure && (s == connectivity.Connecting || s == connectivity.Idle) {
+		if s == connectivity.Idle {
+			sc.Connect()
+		}
+		return
+	}
+	b.scStates[sc] = s
+	switch s {
+	case connectivity.Idle:
+		sc.Connect()
+	case connectivity.Shutdown:
+		delete(b.scStates, sc)
+	case connectivity.TransientFailure:
+		b.connErr = state.ConnectionError
+	}
+	b.state = b.csEvltr.RecordTransition(oldS, s)
+
+	if (s == connectivity.Ready) != (oldS == connectivity.Ready) || b.state == connectivity.TransientFailure {
+		b.regeneratePicker()
+	}
+	b.cc.UpdateState(balancer.State{ConnectivityState: b.state, Picker: b.picker})
+}
+
+func (b *attrBalancer) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.outlier != nil {
+		b.outlier.stop()
+		b.outlier = nil
+	}
+}
+
+func (b *attrBalancer) ExitIdle() {}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/resolver"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSubConn 和 fakeClientConn 记录负载均衡器的调用，不建立真实连接
+type fakeSubConn struct {
+	addrs []resolver.Address
+}
+
+func (sc *fakeSubConn) UpdateAddresses(addrs []resolver.Address) { sc.addrs = addrs }
+func (sc *fakeSubConn) Connect()                                 {}
+
+type fakeClientConn struct {
+	mu       sync.Mutex
+	subConns []*fakeSubConn
+	states   []balancer.State
+}
+
+func (cc *fakeClientConn) NewSubConn(addrs []resolver.Address, _ balancer.NewSubConnOptions) (balancer.SubConn, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	sc := &fakeSubConn{addrs: addrs}
+	cc.subConns = append(cc.subConns, sc)
+	return sc, nil
+}
+
+func (cc *fakeClientConn) RemoveSubConn(balancer.SubConn)                       {}
+func (cc *fakeClientConn) UpdateAddresses(balancer.SubConn, []resolver.Address) {}
+func (cc *fakeClientConn) ResolveNow(resolver.ResolveNowOptions)                {}
+func (cc *fakeClientConn) Target() string                                       { return "fake:///echo" }
+func (cc *fakeClientConn) UpdateState(s balancer.State) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.states = append(cc.states, s)
+}
+
+func (cc *fakeClientConn) lastState() balancer.State {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.states[len(cc.states)-1]
+}
+
+// 解析器推送空的地址列表时 UpdateClientConnState 曾经重复获取 mu 而死锁
+func TestUpdateClientConnStateZeroAddresses(t *testing.T) {
+	cc := &fakeClientConn{}
+	b := newBalancerBuilder("test_rr", func() base.PickerBuilder { return &wrrPickerBuilder{} }, base.Config{}).
+		Build(cc, balancer.BuildOptions{})
+
+	if err := b.UpdateClientConnState(balancer.ClientConnState{
+		ResolverState: resolver.State{Addresses: []resolver.Address{{Addr: "localhost:50051"}}},
+	}); err != nil {
+		t.Fatalf("UpdateClientConnState(1 address) = %v", err)
+	}
+	b.UpdateSubConnState(cc.subConns[0], balancer.SubConnState{ConnectivityState: connectivity.Ready})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- b.UpdateClientConnState(balancer.ClientConnState{ResolverState: resolver.State{}})
+	}()
+	select {
+	case err := <-done:
+		if err != balancer.ErrBadResolverState {
+			t.Errorf("UpdateClientConnState(0 addresses) = %v, want %v", err, balancer.ErrBadResolverState)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("UpdateClientConnState(0 addresses) deadlocked") // 不调用 Close，它同样会阻塞
+	}
+	defer b.Close()
+	if got := cc.lastState().ConnectivityState; got != connectivity.TransientFailure {
+		t.Errorf("state = %v, want %v", got, connectivity.TransientFailure)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/resolver"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// 文件解析器：file:///path/to/endpoints.json
+// 定期检查文件是否变化，变化时将新的后端列表推送给 ClientConn
+const (
+	fileScheme           = "file"
+	defaultWatchInterval = time.Second
+)
+
+// 地址属性的 key，负载均衡器通过 resolver.Address.Attributes 读取
+type attributeKey string
+
+const (
+	weightKey = attributeKey("weight") // uint32，默认为 1
+	zoneKey   = attributeKey("zone")   // string
+)
+
+// endpointsFile 是后端列表文件的格式，例如：
+// {"endpoints": [{"addr": "localhost:50051", "weight": 3, "zone": "rack-a"}]}
+type endpointsFile struct {
+	Endpoints []struct {
+		Addr   string `json:"addr"`
+		Weight uint32 `json:"weight,omitempty"`
+		Zone   string `json:"zone,omitempty"`
+	} `json:"endpoints"`
+}
+
+// parseEndpoints 解析文件内容并转换为带属性的地址列表
+func parseEndpoints(data []byte) ([]resolver.Address, error) {
+	var f endpointsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	if len(f.Endpoints) == 0 {
+		return nil, errors.New("no endpoints")
+	}
+	addrs := make([]resolver.Address, 0, len(f.Endpoints))
+	for i, e := range f.Endpoints {
+		if e.Addr == "" {
+			return nil, fmt.Errorf("endpoints[%d]: empty addr", i)
+		}
+		weight := e.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		addrs = append(addrs, resolver.Address{
+			Addr:       e.Addr,
+			Attributes: attributes.New(weightKey, weight, zoneKey, e.Zone),
+		})
+	}
+	return addrs, nil
+}
+
+type fileResolverBuilder struct {
+	interval time.Duration // 检查文件变化的间隔
+}
+
+func (b *fileResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	if target.Endpoint == "" {
+		return nil, errors.New("file resolver: missing path")
+	}
+	interval := b.interval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	r := &fileResolver{
+		path:       "/" + target.Endpoint, // file:///tmp/endpoints.json 的 Endpoint 为 tmp/endpoints.json
+		cc:         cc,
+		interval:   interval,
+		resolveNow: make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	r.wg.Add(1)
+	go r.watch()
+	return r, nil
+}
+
+func (*fileResolverBuilder) Scheme() string { return fileScheme } // "file"
+
+type fileResolver struct {
+	path       string
+	cc         resolver.ClientConn
+	interval   time.Duration
+	resolveNow chan struct{}
+	done       chan struct{}
+	closeOnce  sync.Once
+	wg         sync.WaitGroup
+
+	// 以下字段只在 watch 协程中访问
+	modTime time.Time
+	size    int64
+	lastErr string
+}
+
+// watch 首次立即解析，之后按间隔检查文件，ResolveNow 会触发一次强制解析
+func (r *fileResolver) watch() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	r.resolve(true)
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.resolve(false)
+		case <-r.resolveNow:
+			r.resolve(true)
+		}
+	}
+}
+
+// resolve 在文件变化（或 force 为 true）时重新解析；出错时通过 ReportError 通知 ClientConn，
+// 同一错误只报告一次，ClientConn 会继续使用上一次成功解析的地址
+func (r *fileResolver) resolve(force bool) {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		r.reportError(err, force)
+		return
+	}
+	if !force && info.ModTime().Equal(r.modTime) && info.Size() == r.size {
+		return
+	}
+	data, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		r.reportError(err, force)
+		return
+	}
+	addrs, err := parseEndpoints(data)
+	if err != nil {
+		r.reportError(fmt.Errorf("%s: %v", r.path, err), force)
+		return
+	}
+	r.modTime, r.size, r.lastErr = info.ModTime(), info.Size(), ""
+	log.Printf("file resolver: %s -> %d address(es)", r.path, len(addrs))
+	if err := r.cc.UpdateState(resolver.State{Addresses: addrs}); err != nil {
+		log.Printf("file resolver: update state: %v", err)
+	}
+}
+
+func (r *fileResolver) reportError(err error, force bool) {
+	if !force && err.Error() == r.lastErr {
+		return
+	}
+	r.lastErr = err.Error()
+	// 下次文件恢复时需要重新推送地址
+	r.modTime, r.size = time.Time{}, 0
+	log.Printf("file resolver: %v", err)
+	r.cc.ReportError(err)
+}
+
+func (r *fileResolver) ResolveNow(o resolver.ResolveNowOptions) {
+	select {
+	case r.resolveNow <- struct{}{}:
+	default: // 已有待处理的解析请求
+	}
+}
+
+// Close 停止 watch 协程并等待其退出
+func (r *fileResolver) Close() {
+	r.closeOnce.Do(func() { close(r.done) })
+	r.wg.Wait()
+}
+
+func init() {
+	resolver.Register(&fileResolverBuilder{})
+}
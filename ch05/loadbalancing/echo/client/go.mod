@@ -2,14 +2,19 @@ module echo/client
 
 go 1.17
 
-require google.golang.org/grpc/examples v0.0.0-20211021223902-4f21cde702d9
+require (
+	google.golang.org/grpc v1.41.0
+	google.golang.org/grpc/examples v0.0.0-20211021223902-4f21cde702d9
+	resolver-builder v0.0.0
+)
 
 require (
 	github.com/golang/protobuf v1.4.3 // indirect
-	golang.org/x/net v0.0.0-20190311183353-d8887717615a // indirect
-	golang.org/x/sys v0.0.0-20190215142949-d0b11bdaac8a // indirect
+	golang.org/x/net v0.0.0-20200822124328-c89045814202 // indirect
+	golang.org/x/sys v0.0.0-20200323222414-85ca7c5b95cd // indirect
 	golang.org/x/text v0.3.0 // indirect
 	google.golang.org/genproto v0.0.0-20200806141610-86f49bd18e98 // indirect
-	google.golang.org/grpc v1.36.0 // indirect
 	google.golang.org/protobuf v1.25.0 // indirect
 )
+
+replace resolver-builder => ../../../resolver-builder
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	_ "google.golang.org/grpc/health" // 启用客户端健康检查
+	"log"
+)
+
+// 服务配置中的 healthCheckConfig 使 SubConn 在连接建立后调用 grpc.health.v1.Health/Watch，
+// 后端报告 NOT_SERVING 时 SubConn 进入 TransientFailure，负载均衡器不再选择它。
+// 后端没有实现健康检查服务时视为健康
+var (
+	healthService    = flag.String("health-service", "", "service name used in health checks, empty for the overall server health")
+	outlierDetection = flag.Bool("outlier-detection", false, "eject backends whose error rate spikes")
+)
+
+// serviceConfig 返回使用 policy 负载均衡策略并启用健康检查的服务配置，params 为策略的参数
+func serviceConfig(policy string, params map[string]interface{}) string {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	if *outlierDetection && policy != "round_robin" && policy != "pick_first" {
+		params["outlierDetection"] = map[string]interface{}{
+			"interval":           "1s",
+			"baseEjectionTime":   "5s",
+			"errorRateThreshold": 0.3,
+			"minimumRequests":    5,
+		}
+	}
+	sc := map[string]interface{}{
+		"loadBalancingConfig": []map[string]interface{}{{policy: params}},
+		"healthCheckConfig":   map[string]string{"serviceName": *healthService},
+	}
+	b, err := json.Marshal(sc)
+	if err != nil {
+		log.Fatalf("invalid service config: %v", err)
+	}
+	return string(b)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"math/rand"
+	"sync/atomic"
+)
+
+// 最少未完成请求负载均衡器：记录每个 SubConn 正在进行的 RPC 数，使用 power-of-two-choices，
+// 即随机选出两个后端，取未完成请求较少的一个。流在关闭前一直计入未完成请求
+const leastRequestName = "least_request"
+
+type leastRequestPickerBuilder struct {
+	// 计数按后端地址保存，并在多次生成 picker 之间保留：后端暂时离开就绪集合或重连换了 SubConn 后，
+	// 之前发出、仍在进行的 RPC 完成时仍能减到同一个计数上。只在 Build 中访问，不需要加锁
+	inflight map[string]*int64
+}
+
+func newLeastRequestPickerBuilder() base.PickerBuilder {
+	return &leastRequestPickerBuilder{inflight: make(map[string]*int64)}
+}
+
+func (b *leastRequestPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	p := &leastRequestPicker{}
+	for sc, scInfo := range info.ReadySCs {
+		n, ok := b.inflight[scInfo.Address.Addr]
+		if !ok {
+			n = new(int64)
+			b.inflight[scInfo.Address.Addr] = n
+		}
+		p.entries = append(p.entries, leastRequestEntry{subConn: sc, inflight: n})
+	}
+	return p
+}
+
+type leastRequestEntry struct {
+	subConn  balancer.SubConn
+	inflight *int64
+}
+
+type leastRequestPicker struct {
+	entries []leastRequestEntry
+}
+
+func (p *leastRequestPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	e := p.entries[0]
+	if n := len(p.entries); n > 1 {
+		i, j := twoChoices(n)
+		e = p.entries[i]
+		if atomic.LoadInt64(p.entries[j].inflight) < atomic.LoadInt64(e.inflight) {
+			e = p.entries[j]
+		}
+	}
+	atomic.AddInt64(e.inflight, 1)
+	return balancer.PickResult{
+		SubConn: e.subConn,
+		Done: func(balancer.DoneInfo) { // 一元 RPC 完成或流结束时调用
+			atomic.AddInt64(e.inflight, -1)
+		},
+	}, nil
+}
+
+// twoChoices 返回 [0, n) 中两个不同的随机下标
+func twoChoices(n int) (int, int) {
+	i := rand.Intn(n)
+	j := rand.Intn(n - 1)
+	if j >= i {
+		j++
+	}
+	return i, j
+}
+
+func init() {
+	balancer.Register(newBalancerBuilder(leastRequestName, newLeastRequestPickerBuilder, base.Config{HealthCheck: true}))
+}
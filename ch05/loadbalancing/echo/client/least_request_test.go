@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/resolver"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// runLoad 由 concurrency 个调用方共发送 n 个 RPC，返回排好序的延迟
+func runLoad(t *testing.T, client ecpb.EchoClient, n, concurrency int) []time.Duration {
+	t.Helper()
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		latencies []time.Duration
+		errs      int
+	)
+	requests := make(chan struct{})
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range requests {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				start := time.Now()
+				_, err := client.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "load"})
+				elapsed := time.Since(start)
+				cancel()
+
+				mu.Lock()
+				if err != nil {
+					errs++
+				} else {
+					latencies = append(latencies, elapsed)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		requests <- struct{}{}
+	}
+	close(requests)
+	wg.Wait()
+	if errs > 0 {
+		t.Fatalf("%d of %d RPCs failed", errs, n)
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies
+}
+
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	return latencies[int(float64(len(latencies)-1)*p)]
+}
+
+// 一个后端变慢时，least_request 把请求转向其他后端，尾延迟低于 round_robin
+func TestLeastRequestSlowBackend(t *testing.T) {
+	const (
+		fast = 2 * time.Millisecond
+		slow = 100 * time.Millisecond
+	)
+	backends := []*testBackend{startTestBackend(t, fast), startTestBackend(t, fast), startTestBackend(t, slow)}
+	var addrs []resolver.Address
+	var want []string
+	for _, b := range backends {
+		addrs = append(addrs, resolver.Address{Addr: b.addr})
+		want = append(want, b.addr)
+	}
+
+	p90 := make(map[string]time.Duration)
+	for _, policy := range []string{"round_robin", leastRequestName} {
+		client := dialBackends(t, policy, nil, addrs)
+		waitForPeers(t, client, want...)
+		latencies := runLoad(t, client, 600, 8)
+		p90[policy] = percentile(latencies, 0.9)
+		t.Logf("%s: p50=%v p90=%v p99=%v", policy, percentile(latencies, 0.5), p90[policy], percentile(latencies, 0.99))
+	}
+	if p90[leastRequestName] >= p90["round_robin"]/2 {
+		t.Errorf("least_request p90 %v, want below half of round_robin p90 %v", p90[leastRequestName], p90["round_robin"])
+	}
+}
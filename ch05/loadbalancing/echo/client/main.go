@@ -2,11 +2,12 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"google.golang.org/grpc"
 	ecpb "google.golang.org/grpc/examples/features/proto/echo"
-	"google.golang.org/grpc/resolver"
 	"log"
+	"resolver-builder"
 	"time"
 )
 
@@ -17,16 +18,39 @@ const (
 
 var addrs = []string{"localhost:50051", "localhost:50052"}
 
+// 指定后端列表文件时使用 file:///path/to/endpoints.json，文件变化后地址会实时更新
+var endpointsPath = flag.String("endpoints", "", "absolute path of an endpoints JSON file, uses the static example resolver when empty")
+
+var localZone = flag.String("zone", "rack-a", "zone of this client, used by the zone_aware balancer")
+
+// target 返回拨号地址
+func target() string {
+	if *endpointsPath != "" {
+		return fmt.Sprintf("%s://%s", fileScheme, *endpointsPath) // "file:///path/to/endpoints.json"
+	}
+	return fmt.Sprintf("%s:///%s", exampleScheme, exampleServiceName) // "example:///lb.example.grpc.io"
+}
+
 func callUnaryEcho(c ecpb.EchoClient, message string) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 	r, err := c.UnaryEcho(ctx, &ecpb.EchoRequest{Message: message})
 	if err != nil {
-		log.Fatalf("could not greet: %v", err)
+		// 某个后端故障时不退出，健康检查和离群检测会让后续请求避开它
+		log.Printf("could not greet: %v", err)
+		return
 	}
 	fmt.Println(r.Message)
 }
 
+// echoMessage 使用请求的消息内容作为一致性哈希的 key
+func echoMessage(req interface{}) string {
+	if r, ok := req.(*ecpb.EchoRequest); ok {
+		return r.GetMessage()
+	}
+	return ""
+}
+
 func makeRPCs(cc *grpc.ClientConn, n int) {
 	hwc := ecpb.NewEchoClient(cc)
 	for i := 0; i < n; i++ {
@@ -35,8 +59,9 @@ func makeRPCs(cc *grpc.ClientConn, n int) {
 }
 
 func main() {
+	flag.Parse()
 	pickfirstConn, err := grpc.Dial(
-		fmt.Sprintf("%s:///%s", exampleScheme, exampleServiceName), // "example:///lb.example.grpc.io"
+		target(),
 		// grpc.WithBalancerName("pick_first"), // "pick_first" is the default, so this DialOption is not necessary.
 		grpc.WithInsecure(),
 	)
@@ -50,8 +75,8 @@ func main() {
 
 	// Make another ClientConn with round_robin policy.
 	roundrobinConn, err := grpc.Dial(
-		fmt.Sprintf("%s:///%s", exampleScheme, exampleServiceName), // // "example:///lb.example.grpc.io"
-		grpc.WithBalancerName("round_robin"), // This sets the initial balancing policy.
+		target(),
+		grpc.WithDefaultServiceConfig(serviceConfig("round_robin", nil)), // This sets the initial balancing policy.
 		grpc.WithInsecure(),
 	)
 	if err != nil {
@@ -61,42 +86,57 @@ func main() {
 
 	log.Println("==== Calling helloworld.Greeter/SayHello with round_robin ====")
 	makeRPCs(roundrobinConn, 10)
-}
 
-// Name resolver implementation
+	// 使用加权轮询，权重由解析器的地址属性提供（见 endpoints.json 中的 weight）
+	weightedConn, err := grpc.Dial(
+		target(),
+		grpc.WithDefaultServiceConfig(serviceConfig(weightedRoundRobinName, nil)),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		log.Fatalf("did not connect: %v", err)
+	}
+	defer weightedConn.Close()
 
-type exampleResolverBuilder struct{}
+	log.Println("==== Calling helloworld.Greeter/SayHello with weighted_round_robin ====")
+	makeRPCs(weightedConn, 12)
 
-func (*exampleResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOption) (resolver.Resolver, error) {
-	r := &exampleResolver{
-		target: target,
-		cc:     cc,
-		addrsStore: map[string][]string{
-			exampleServiceName: addrs, // "lb.example.grpc.io": "localhost:50051", "localhost:50052"
-		},
+	// 使用一致性哈希，拦截器把请求中的订单 ID 写入 x-order-id，相同订单 ID 总是发往同一个后端
+	ringHashConn, err := grpc.Dial(
+		target(),
+		grpc.WithDefaultServiceConfig(serviceConfig(ringHashName, map[string]interface{}{"hashKey": orderIdKey})),
+		grpc.WithUnaryInterceptor(hashKeyUnaryClientInterceptor(orderIdKey, echoMessage)),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		log.Fatalf("did not connect: %v", err)
 	}
-	r.start()
-	return r, nil
-}
-func (*exampleResolverBuilder) Scheme() string { return exampleScheme } // "example"
+	defer ringHashConn.Close()
 
-type exampleResolver struct {
-	target     resolver.Target
-	cc         resolver.ClientConn
-	addrsStore map[string][]string
-}
+	log.Println("==== Calling helloworld.Greeter/SayHello with metadata_ring_hash ====")
+	ringHashClient := ecpb.NewEchoClient(ringHashConn)
+	for i := 0; i < 12; i++ {
+		callUnaryEcho(ringHashClient, fmt.Sprintf("order-%d", 101+i%3))
+	}
 
-func (r *exampleResolver) start() {
-	addrStrs := r.addrsStore[r.target.Endpoint]
-	addrs := make([]resolver.Address, len(addrStrs))
-	for i, s := range addrStrs {
-		addrs[i] = resolver.Address{Addr: s}
+	// 使用可用区感知负载均衡，优先选择 -zone 所在可用区（见 endpoints.json 中的 zone）的后端
+	zoneConn, err := grpc.Dial(
+		target(),
+		grpc.WithDefaultServiceConfig(serviceConfig(zoneAwareName, map[string]interface{}{"zone": *localZone})),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		log.Fatalf("did not connect: %v", err)
 	}
-	r.cc.UpdateState(resolver.State{Addresses: addrs})
+	defer zoneConn.Close()
+
+	log.Printf("==== Calling helloworld.Greeter/SayHello with zone_aware in %s ====", *localZone)
+	makeRPCs(zoneConn, 10)
 }
-func (*exampleResolver) ResolveNow(o resolver.ResolveNowOption) {}
-func (*exampleResolver) Close()                                 {}
 
+// 静态解析器：example:///lb.example.grpc.io 解析为 addrs
 func init() {
-	resolver.Register(&exampleResolverBuilder{})
+	resolverbuilder.Register(exampleScheme, map[string][]string{
+		exampleServiceName: addrs, // "lb.example.grpc.io": "localhost:50051", "localhost:50052"
+	})
 }
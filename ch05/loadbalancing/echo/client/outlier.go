@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// 离群检测：统计每个后端在一个周期内的错误率，超过阈值的后端被暂时摘除（不参与 picker 生成），
+// 摘除时间为 baseEjectionTime 乘以连续被摘除的次数。健康检查只能发现服务端主动报告的 NOT_SERVING，
+// 离群检测则能发现健康检查正常、但请求大量失败的后端
+type outlierDetectionConfig struct {
+	Interval           string  `json:"interval,omitempty"`           // 统计周期，默认 10s
+	BaseEjectionTime   string  `json:"baseEjectionTime,omitempty"`   // 默认 30s
+	ErrorRateThreshold float64 `json:"errorRateThreshold,omitempty"` // 默认 0.5
+	MinimumRequests    int64   `json:"minimumRequests,omitempty"`    // 请求数少于该值的后端不做判断，默认 10
+	MaxEjectionPercent int     `json:"maxEjectionPercent,omitempty"` // 最多摘除的后端比例，默认 50
+
+	interval         time.Duration
+	baseEjectionTime time.Duration
+}
+
+func (c *outlierDetectionConfig) validate() error {
+	var err error
+	if c.Interval == "" {
+		c.Interval = "10s"
+	}
+	if c.interval, err = time.ParseDuration(c.Interval); err != nil || c.interval <= 0 {
+		return errors.New("interval must be a positive duration")
+	}
+	if c.BaseEjectionTime == "" {
+		c.BaseEjectionTime = "30s"
+	}
+	if c.baseEjectionTime, err = time.ParseDuration(c.BaseEjectionTime); err != nil || c.baseEjectionTime <= 0 {
+		return errors.New("baseEjectionTime must be a positive duration")
+	}
+	if c.ErrorRateThreshold == 0 {
+		c.ErrorRateThreshold = 0.5
+	}
+	if c.ErrorRateThreshold < 0 || c.ErrorRateThreshold > 1 {
+		return errors.New("errorRateThreshold must be in (0, 1]")
+	}
+	if c.MinimumRequests == 0 {
+		c.MinimumRequests = 10
+	}
+	if c.MaxEjectionPercent == 0 {
+		c.MaxEjectionPercent = 50
+	}
+	if c.MaxEjectionPercent < 0 || c.MaxEjectionPercent > 100 {
+		return errors.New("maxEjectionPercent must be in [0, 100]")
+	}
+	return nil
+}
+
+type callStats struct {
+	success int64
+	failure int64
+}
+
+// outlierDetector 的字段由 attrBalancer.mu 保护，callStats 的计数使用原子操作
+type outlierDetector struct {
+	config outlierDetectionConfig
+	ticker *time.Ticker
+	done   chan struct{}
+
+	stats     map[balancer.SubConn]*callStats
+	addrs     map[balancer.SubConn]string
+	ejected   map[balancer.SubConn]time.Time // 摘除到期时间
+	ejections map[balancer.SubConn]int       // 连续被摘除的次数
+}
+
+// updateOutlierDetection 启用、更新或关闭离群检测，返回是否需要重新生成 picker，调用时需持有 b.mu
+func (b *attrBalancer) updateOutlierDetection(cfg *outlierDetectionConfig) bool {
+	if b.outlier != nil {
+		if cfg != nil && b.outlier.config == *cfg {
+			return false
+		}
+		b.outlier.stop()
+		b.outlier = nil
+	}
+	if cfg == nil {
+		return false
+	}
+	d := &outlierDetector{
+		config:    *cfg,
+		ticker:    time.NewTicker(cfg.interval),
+		done:      make(chan struct{}),
+		stats:     make(map[balancer.SubConn]*callStats),
+		addrs:     make(map[balancer.SubConn]string),
+		ejected:   make(map[balancer.SubConn]time.Time),
+		ejections: make(map[balancer.SubConn]int),
+	}
+	b.outlier = d
+	go func() {
+		for {
+			select {
+			case <-d.done:
+				return
+			case now := <-d.ticker.C:
+				b.evaluateOutliers(d, now)
+			}
+		}
+	}()
+	return true
+}
+
+// evaluateOutliers 在每个统计周期结束时执行，摘除或恢复后端后重新生成 picker
+func (b *attrBalancer) evaluateOutliers(d *outlierDetector, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.outlier != d || !d.evaluate(b.name, now) {
+		return
+	}
+	b.regeneratePicker()
+	b.cc.UpdateState(balancer.State{ConnectivityState: b.state, Picker: b.picker})
+}
+
+func (d *outlierDetector) evaluate(name string, now time.Time) bool {
+	changed := false
+	for sc, until := range d.ejected {
+		if now.After(until) {
+			delete(d.ejected, sc)
+			log.Printf("%s: %s un-ejected", name, d.addrs[sc])
+			changed = true
+		}
+	}
+	maxEjected := len(d.stats) * d.config.MaxEjectionPercent / 100
+	for sc, st := range d.stats {
+		success, failure := atomic.SwapInt64(&st.success, 0), atomic.SwapInt64(&st.failure, 0)
+		total := success + failure
+		if _, ok := d.ejected[sc]; ok || total < d.config.MinimumRequests {
+			continue
+		}
+		rate := float64(failure) / float64(total)
+		if rate <= d.config.ErrorRateThreshold {
+			d.ejections[sc] = 0
+			continue
+		}
+		if len(d.ejected) >= maxEjected {
+			log.Printf("%s: %s error rate %.2f, not ejected: max ejection percent reached", name, d.addrs[sc], rate)
+			continue
+		}
+		d.ejections[sc]++
+		duration := d.config.baseEjectionTime * time.Duration(d.ejections[sc])
+		d.ejected[sc] = now.Add(duration)
+		log.Printf("%s: %s error rate %.2f, ejected for %v", name, d.addrs[sc], rate, duration)
+		changed = true
+	}
+	return changed
+}
+
+// filter 去掉被摘除的后端，所有后端都被摘除时保留全部
+func (d *outlierDetector) filter(readySCs map[balancer.SubConn]base.SubConnInfo) map[balancer.SubConn]base.SubConnInfo {
+	for sc, info := range readySCs {
+		if _, ok := d.stats[sc]; !ok {
+			d.stats[sc] = &callStats{}
+			d.addrs[sc] = info.Address.Addr
+		}
+	}
+	filtered := make(map[balancer.SubConn]base.SubConnInfo, len(readySCs))
+	for sc, info := range readySCs {
+		if _, ok := d.ejected[sc]; !ok {
+			filtered[sc] = info
+		}
+	}
+	if len(filtered) == 0 {
+		return readySCs
+	}
+	return filtered
+}
+
+// wrap 包装 picker，在每个 RPC 完成时记录结果
+func (d *outlierDetector) wrap(p balancer.Picker) balancer.Picker {
+	stats := make(map[balancer.SubConn]*callStats, len(d.stats))
+	for sc, st := range d.stats {
+		stats[sc] = st
+	}
+	return &outlierPicker{Picker: p, stats: stats}
+}
+
+func (d *outlierDetector) remove(sc balancer.SubConn) {
+	delete(d.stats, sc)
+	delete(d.addrs, sc)
+	delete(d.ejected, sc)
+	delete(d.ejections, sc)
+}
+
+func (d *outlierDetector) stop() {
+	d.ticker.Stop()
+	close(d.done)
+}
+
+type outlierPicker struct {
+	balancer.Picker
+	stats map[balancer.SubConn]*callStats // 只读的快照
+}
+
+func (p *outlierPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	result, err := p.Picker.Pick(info)
+	if err != nil {
+		return result, err
+	}
+	st, ok := p.stats[result.SubConn]
+	if !ok {
+		return result, nil
+	}
+	done := result.Done
+	result.Done = func(di balancer.DoneInfo) {
+		if isServerError(di.Err) {
+			atomic.AddInt64(&st.failure, 1)
+		} else {
+			atomic.AddInt64(&st.success, 1)
+		}
+		if done != nil {
+			done(di)
+		}
+	}
+	return result, nil
+}
+
+// isServerError 判断错误是否由后端引起，客户端取消、参数错误等不计入错误率
+func isServerError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Internal, codes.Unknown, codes.DataLoss, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/resolver"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tryPeer 发送一个 RPC，返回处理它的后端地址和错误
+func tryPeer(client ecpb.EchoClient) (string, error) {
+	var p peer.Peer
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := client.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "test"}, grpc.Peer(&p), grpc.WaitForReady(true))
+	if p.Addr == nil {
+		return "", err
+	}
+	return p.Addr.String(), err
+}
+
+// 健康检查正常、但请求大量失败的后端在一个统计周期后被摘除，摘除期间不再收到请求；
+// baseEjectionTime 到期后恢复，再次参与负载均衡
+func TestOutlierEjection(t *testing.T) {
+	const (
+		interval         = 200 * time.Millisecond
+		baseEjectionTime = time.Second
+	)
+	good, bad := startTestBackend(t, 0), startTestBackend(t, 0)
+	client := dialBackends(t, weightedRoundRobinName, map[string]interface{}{
+		"outlierDetection": map[string]interface{}{
+			"interval":           interval.String(),
+			"baseEjectionTime":   baseEjectionTime.String(),
+			"errorRateThreshold": 0.5,
+			"minimumRequests":    5,
+		},
+	}, []resolver.Address{{Addr: good.addr}, {Addr: bad.addr}})
+	waitForPeers(t, client, good.addr, bad.addr)
+
+	// 注入错误后持续发送请求，直到连续 50 个请求都由正常的后端成功处理
+	bad.setFailing(true)
+	deadline := time.Now().Add(10 * interval)
+	for streak := 0; streak < 50; {
+		if time.Now().After(deadline) {
+			t.Fatalf("failing backend %s was not ejected within %v", bad.addr, 10*interval)
+		}
+		addr, err := tryPeer(client)
+		if err != nil || addr != good.addr {
+			streak = 0
+			continue
+		}
+		streak++
+	}
+	ejectedAt := time.Now()
+
+	// 摘除期间失败的后端收不到请求，即使它已经恢复
+	bad.setFailing(false)
+	requests := atomic.LoadInt64(&bad.requests)
+	for time.Since(ejectedAt) < baseEjectionTime/2 {
+		if _, err := tryPeer(client); err != nil {
+			t.Fatalf("UnaryEcho while %s is ejected: %v", bad.addr, err)
+		}
+	}
+	if n := atomic.LoadInt64(&bad.requests) - requests; n != 0 {
+		t.Errorf("ejected backend %s received %d requests", bad.addr, n)
+	}
+
+	// 摘除到期后的下一个统计周期恢复
+	for atomic.LoadInt64(&bad.requests) == requests {
+		if time.Since(ejectedAt) > baseEjectionTime+3*interval {
+			t.Fatalf("backend %s was not un-ejected within %v", bad.addr, baseEjectionTime+3*interval)
+		}
+		if _, err := tryPeer(client); err != nil {
+			t.Fatalf("UnaryEcho: %v", err)
+		}
+	}
+	if took := time.Since(ejectedAt); took < baseEjectionTime-2*interval {
+		t.Errorf("backend %s un-ejected after %v, want about %v", bad.addr, took, baseEjectionTime)
+	}
+	waitForPeers(t, client, good.addr, bad.addr)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/serviceconfig"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync/atomic"
+)
+
+// 一致性哈希负载均衡器：对元数据 hashKey（默认 x-order-id）的值做哈希，相同订单 ID 的请求总是发往同一个后端。
+// 调用方可以在元数据中显式给出 key，也可以使用 hashKeyUnaryClientInterceptor 从请求消息中取得；两者都没有时请求随机分配。
+// 每个后端在哈希环上放置 权重 × virtualNodes 个虚拟节点（权重最多按 maxRingWeight 计），数量与其他后端无关，
+// 后端增减时只有相邻区间的 key 会迁移。
+// 有界负载（bounded load）：某个后端未完成的请求数超过平均值的 loadFactor 倍时，沿哈希环顺延到下一个后端
+const ringHashName = "metadata_ring_hash"
+
+const orderIdKey = "x-order-id"
+
+// maxRingWeight 限制单个后端的权重，避免解析器给出很大的权重时哈希环占用过多内存
+const maxRingWeight = 100
+
+// ringHashConfig 是服务配置中的参数，例如：
+// {"loadBalancingConfig": [{"metadata_ring_hash": {"hashKey": "x-order-id", "virtualNodes": 100, "loadFactor": 1.25}}]}
+type ringHashConfig struct {
+	serviceconfig.LoadBalancingConfig `json:"-"`
+
+	HashKey      string  `json:"hashKey,omitempty"`
+	VirtualNodes int     `json:"virtualNodes,omitempty"` // 每单位权重的虚拟节点数
+	LoadFactor   float64 `json:"loadFactor,omitempty"`   // 不大于 1 时关闭有界负载
+}
+
+var defaultRingHashConfig = ringHashConfig{HashKey: orderIdKey, VirtualNodes: 100, LoadFactor: 1.25}
+
+func parseRingHashConfig(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	cfg := defaultRingHashConfig
+	if err := json.Unmarshal(js, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: invalid config %s: %v", ringHashName, js, err)
+	}
+	if cfg.VirtualNodes <= 0 || cfg.VirtualNodes > 1<<12 {
+		return nil, fmt.Errorf("%s: virtualNodes %d out of range", ringHashName, cfg.VirtualNodes)
+	}
+	return &cfg, nil
+}
+
+type ringHashPickerBuilder struct {
+	config   ringHashConfig
+	inflight map[balancer.SubConn]*int64
+}
+
+func newRingHashPickerBuilder() base.PickerBuilder {
+	return &ringHashPickerBuilder{config: defaultRingHashConfig, inflight: make(map[balancer.SubConn]*int64)}
+}
+
+func (b *ringHashPickerBuilder) UpdateConfig(cfg serviceconfig.LoadBalancingConfig) bool {
+	c, ok := cfg.(*ringHashConfig)
+	if !ok || *c == b.config {
+		return false
+	}
+	b.config = *c
+	return true
+}
+
+func (b *ringHashPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	inflight := make(map[balancer.SubConn]*int64, len(info.ReadySCs))
+	for sc := range info.ReadySCs {
+		if n, ok := b.inflight[sc]; ok {
+			inflight[sc] = n
+		} else {
+			inflight[sc] = new(int64)
+		}
+	}
+	b.inflight = inflight
+
+	p := &ringHashPicker{hashKey: b.config.HashKey, loadFactor: b.config.LoadFactor, backends: len(info.ReadySCs)}
+	for sc, scInfo := range info.ReadySCs {
+		// 虚拟节点的数量和位置只取决于该后端的地址和权重，与其他后端无关
+		replicas := ringWeight(scInfo) * int64(b.config.VirtualNodes)
+		for i := int64(0); i < replicas; i++ {
+			p.ring = append(p.ring, ringEntry{
+				hash:     hashString(scInfo.Address.Addr + "_" + strconv.FormatInt(i, 10)),
+				subConn:  sc,
+				inflight: inflight[sc],
+			})
+		}
+	}
+	sort.Slice(p.ring, func(i, j int) bool { return p.ring[i].hash < p.ring[j].hash })
+	for _, n := range inflight {
+		p.inflight = append(p.inflight, n)
+	}
+	return p
+}
+
+// ringWeight 返回后端在哈希环上的权重，不超过 maxRingWeight
+func ringWeight(info base.SubConnInfo) int64 {
+	if w := addressWeight(info); w < maxRingWeight {
+		return w
+	}
+	return maxRingWeight
+}
+
+// hashString 对 s 做 FNV-1a 哈希，再用 MurmurHash3 的 fmix64 打散各位。
+// 只有末尾几个字符不同的字符串（如虚拟节点 "addr_0"、"addr_1"）的 FNV 值高位相近，会在环上扎堆，后端分到的 key 很不均匀
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+type ringEntry struct {
+	hash     uint64
+	subConn  balancer.SubConn
+	inflight *int64
+}
+
+type ringHashPicker struct {
+	hashKey    string
+	loadFactor float64
+	backends   int
+	ring       []ringEntry
+	inflight   []*int64 // 所有就绪后端的计数，用于计算平均负载
+}
+
+func (p *ringHashPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	var h uint64
+	md, _ := metadata.FromOutgoingContext(info.Ctx)
+	if keys := md.Get(p.hashKey); len(keys) > 0 {
+		h = hashString(keys[0])
+	} else {
+		h = rand.Uint64() // 没有 key 的请求随机分配
+	}
+	start := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= h })
+
+	e := p.ring[start%len(p.ring)]
+	if p.loadFactor > 1 {
+		limit := p.loadLimit()
+		// 顺着哈希环寻找第一个未超过负载上限的后端，都超过时使用原来的后端
+		for i := 0; i < len(p.ring); i++ {
+			candidate := p.ring[(start+i)%len(p.ring)]
+			if atomic.LoadInt64(candidate.inflight)+1 <= limit {
+				e = candidate
+				break
+			}
+		}
+	}
+	atomic.AddInt64(e.inflight, 1)
+	return balancer.PickResult{
+		SubConn: e.subConn,
+		Done: func(balancer.DoneInfo) {
+			atomic.AddInt64(e.inflight, -1)
+		},
+	}, nil
+}
+
+// loadLimit 返回每个后端允许的未完成请求数：ceil(loadFactor * (总请求数 + 1) / 后端数)
+func (p *ringHashPicker) loadLimit() int64 {
+	var total int64
+	for _, n := range p.inflight {
+		total += atomic.LoadInt64(n)
+	}
+	return int64(math.Ceil(p.loadFactor * float64(total+1) / float64(p.backends)))
+}
+
+// hashKeyUnaryClientInterceptor 将 keyFor 从请求中取得的值写入元数据 key，供一致性哈希使用；
+// 调用方已经在元数据中给出 key 时不覆盖
+func hashKeyUnaryClientInterceptor(key string, keyFor func(req interface{}) string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		if v := keyFor(req); v != "" && len(md.Get(key)) == 0 {
+			ctx = metadata.AppendToOutgoingContext(ctx, key, v)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func init() {
+	balancer.Register(newBalancerBuilder(ringHashName, newRingHashPickerBuilder, base.Config{HealthCheck: true}).withConfigParser(parseRingHashConfig))
+}
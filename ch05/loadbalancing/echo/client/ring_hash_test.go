@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+	"math"
+	"testing"
+)
+
+// buildPicker 为 weights 中的每个后端生成 SubConn 并构建哈希环，关闭有界负载，使每个 key 只取决于哈希环
+func buildPicker(t *testing.T, weights map[string]uint32) *ringHashPicker {
+	t.Helper()
+	info := base.PickerBuildInfo{ReadySCs: make(map[balancer.SubConn]base.SubConnInfo)}
+	for addr, w := range weights {
+		a := resolver.Address{Addr: addr, Attributes: attributes.New(weightKey, w)}
+		info.ReadySCs[&fakeSubConn{addrs: []resolver.Address{a}}] = base.SubConnInfo{Address: a}
+	}
+	b := newRingHashPickerBuilder()
+	cfg := defaultRingHashConfig
+	cfg.LoadFactor = 0
+	b.(*ringHashPickerBuilder).UpdateConfig(&cfg)
+	return b.Build(info).(*ringHashPicker)
+}
+
+// buildRing 同 buildPicker，返回各后端的虚拟节点数
+func buildRing(t *testing.T, weights map[string]uint32) map[string]int {
+	t.Helper()
+	p := buildPicker(t, weights)
+	vnodes := make(map[string]int)
+	for _, e := range p.ring {
+		vnodes[e.subConn.(*fakeSubConn).addrs[0].Addr]++
+	}
+	return vnodes
+}
+
+// 每个后端的虚拟节点数只取决于自己的权重，增加后端不会改变已有后端在环上的位置
+func TestRingHashVirtualNodes(t *testing.T) {
+	weights := map[string]uint32{"10.0.0.1:50051": 1, "10.0.0.2:50051": 3}
+	before := buildRing(t, weights)
+	weights["10.0.0.3:50051"] = 2
+	after := buildRing(t, weights)
+
+	for addr, w := range weights {
+		want := int(w) * defaultRingHashConfig.VirtualNodes
+		if after[addr] != want {
+			t.Errorf("backend %s with weight %d has %d virtual nodes, want %d", addr, w, after[addr], want)
+		}
+		if n, ok := before[addr]; ok && n != after[addr] {
+			t.Errorf("backend %s virtual nodes changed from %d to %d after adding a backend", addr, n, after[addr])
+		}
+	}
+}
+
+// 相同的 x-order-id 总是发往同一个后端
+func TestRingHashStickyKey(t *testing.T) {
+	var addrs []resolver.Address
+	for i := 0; i < 3; i++ {
+		addrs = append(addrs, resolver.Address{Addr: startTestBackend(t, 0).addr})
+	}
+	client := dialBackends(t, ringHashName, map[string]interface{}{"hashKey": orderIdKey}, addrs)
+	waitForPeers(t, client, addrs[0].Addr, addrs[1].Addr, addrs[2].Addr)
+
+	for i := 101; i < 111; i++ {
+		orderId := fmt.Sprintf("order-%d", i)
+		first := callPeer(t, client, orderIdKey, orderId)
+		for j := 0; j < 5; j++ {
+			if got := callPeer(t, client, orderIdKey, orderId); got != first {
+				t.Fatalf("%s went to %s, then to %s", orderId, first, got)
+			}
+		}
+	}
+}
+
+// 权重超过 maxRingWeight 的后端按 maxRingWeight 放置虚拟节点
+func TestRingHashWeightClamped(t *testing.T) {
+	vnodes := buildRing(t, map[string]uint32{"10.0.0.1:50051": math.MaxUint32, "10.0.0.2:50051": 2})
+	if want := maxRingWeight * defaultRingHashConfig.VirtualNodes; vnodes["10.0.0.1:50051"] != want {
+		t.Errorf("backend with weight %d has %d virtual nodes, want %d", uint32(math.MaxUint32), vnodes["10.0.0.1:50051"], want)
+	}
+	if want := 2 * defaultRingHashConfig.VirtualNodes; vnodes["10.0.0.2:50051"] != want {
+		t.Errorf("backend with weight 2 has %d virtual nodes, want %d", vnodes["10.0.0.2:50051"], want)
+	}
+}
+
+// pickKeys 返回每个 key 被分配到的后端地址
+func pickKeys(t *testing.T, p *ringHashPicker, keys []string) map[string]string {
+	t.Helper()
+	picked := make(map[string]string, len(keys))
+	for _, key := range keys {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), orderIdKey, key)
+		res, err := p.Pick(balancer.PickInfo{Ctx: ctx})
+		if err != nil {
+			t.Fatalf("Pick(%s): %v", key, err)
+		}
+		picked[key] = res.SubConn.(*fakeSubConn).addrs[0].Addr
+		res.Done(balancer.DoneInfo{})
+	}
+	return picked
+}
+
+// 移除一个后端时只有原来分配给它的 key 迁移，约占 1/N，其余 key 仍然发往原来的后端
+func TestRingHashRemovalMovesOneNth(t *testing.T) {
+	const n = 5
+	weights := make(map[string]uint32)
+	for i := 1; i <= n; i++ {
+		weights[fmt.Sprintf("10.0.0.%d:50051", i)] = 1
+	}
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("order-%d", i)
+	}
+	before := pickKeys(t, buildPicker(t, weights), keys)
+	const removed = "10.0.0.3:50051"
+	delete(weights, removed)
+	after := pickKeys(t, buildPicker(t, weights), keys)
+
+	moved := 0
+	for _, key := range keys {
+		if before[key] == after[key] {
+			continue
+		}
+		moved++
+		if before[key] != removed {
+			t.Fatalf("%s moved from %s to %s, only keys of the removed backend should move", key, before[key], after[key])
+		}
+	}
+	// 100 个虚拟节点时各后端分到的 key 与 1/N 的偏差在 ±30% 以内
+	if got, want := float64(moved)/float64(len(keys)), 1.0/n; got < want*0.7 || got > want*1.3 {
+		t.Errorf("%.1f%% of keys moved, want about %.1f%%", got*100, want*100)
+	}
+}
+
+// 拦截器把请求消息写入 x-order-id，调用方显式给出的 key 不被覆盖
+func TestHashKeyInterceptor(t *testing.T) {
+	interceptor := hashKeyUnaryClientInterceptor(orderIdKey, echoMessage)
+	keyOf := func(ctx context.Context) string {
+		var got string
+		interceptor(ctx, "/grpc.examples.echo.Echo/UnaryEcho", &ecpb.EchoRequest{Message: "order-101"}, nil, nil,
+			func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				md, _ := metadata.FromOutgoingContext(ctx)
+				if v := md.Get(orderIdKey); len(v) == 1 {
+					got = v[0]
+				}
+				return nil
+			})
+		return got
+	}
+	if got := keyOf(context.Background()); got != "order-101" {
+		t.Errorf("key = %q, want the request message", got)
+	}
+	if got := keyOf(metadata.AppendToOutgoingContext(context.Background(), orderIdKey, "order-999")); got != "order-999" {
+		t.Errorf("key = %q, want the explicit metadata", got)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"log"
+	"sort"
+	"sync"
+)
+
+// 平滑加权轮询（smooth weighted round-robin）负载均衡器，权重来自解析器地址属性 weightKey。
+// 权重为 3:1 时选择序列为 a a b a，而不是 a a a b，请求在一个周期内均匀分散
+const weightedRoundRobinName = "weighted_round_robin"
+
+type wrrPickerBuilder struct{}
+
+func (*wrrPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	p := &wrrPicker{}
+	for sc, scInfo := range info.ReadySCs {
+		weight := addressWeight(scInfo)
+		p.entries = append(p.entries, &wrrEntry{subConn: sc, addr: scInfo.Address.Addr, weight: weight})
+		p.total += weight
+	}
+	// 按地址排序，使相同的权重配置总是得到相同的选择序列
+	sort.Slice(p.entries, func(i, j int) bool { return p.entries[i].addr < p.entries[j].addr })
+	for _, e := range p.entries {
+		log.Printf("%s: %s weight %d/%d", weightedRoundRobinName, e.addr, e.weight, p.total)
+	}
+	return p
+}
+
+// addressWeight 读取地址的权重，没有设置时为 1
+func addressWeight(info base.SubConnInfo) int64 {
+	if w, ok := info.Address.Attributes.Value(weightKey).(uint32); ok && w > 0 {
+		return int64(w)
+	}
+	return 1
+}
+
+type wrrEntry struct {
+	subConn balancer.SubConn
+	addr    string
+	weight  int64
+	current int64
+}
+
+type wrrPicker struct {
+	mu      sync.Mutex
+	entries []*wrrEntry
+	total   int64
+}
+
+// Pick 每次将所有后端的 current 加上各自的权重，选出 current 最大的后端并减去总权重
+func (p *wrrPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var best *wrrEntry
+	for _, e := range p.entries {
+		e.current += e.weight
+		if best == nil || e.current > best.current {
+			best = e
+		}
+	}
+	best.current -= p.total
+	return balancer.PickResult{SubConn: best.subConn}, nil
+}
+
+func init() {
+	balancer.Register(newBalancerBuilder(weightedRoundRobinName, func() base.PickerBuilder { return &wrrPickerBuilder{} }, base.Config{HealthCheck: true}))
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/resolver"
+	"math"
+	"testing"
+)
+
+func TestWeightedRoundRobinSplit(t *testing.T) {
+	weights := []uint32{1, 2, 5}
+	var addrs []resolver.Address
+	var want []string
+	for _, w := range weights {
+		b := startTestBackend(t, 0)
+		addrs = append(addrs, resolver.Address{Addr: b.addr, Attributes: attributes.New(weightKey, w)})
+		want = append(want, b.addr)
+	}
+	client := dialBackends(t, weightedRoundRobinName, nil, addrs)
+	waitForPeers(t, client, want...)
+
+	const n = 800
+	counts := countPeers(t, client, n)
+	var total uint32
+	for _, w := range weights {
+		total += w
+	}
+	for i, addr := range want {
+		expected := float64(n) * float64(weights[i]) / float64(total)
+		if got := float64(counts[addr]); math.Abs(got-expected) > 0.05*float64(n) {
+			t.Errorf("backend %s with weight %d got %v of %d RPCs, want %v ± 5%%", addr, weights[i], got, n, expected)
+		}
+	}
+}
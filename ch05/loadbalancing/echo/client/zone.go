@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+	"log"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// 可用区感知负载均衡器：优先把请求发往客户端所在可用区（地址属性 zoneKey）的后端，
+// 当本区健康后端的比例低于 failoverThreshold 时，按 zonePriority 的顺序切换到其他可用区。
+// 所有可用区都低于阈值时使用全部健康后端。同一可用区内使用加权轮询
+const zoneAwareName = "zone_aware"
+
+// zoneAwareConfig 例如：
+// {"loadBalancingConfig": [{"zone_aware": {"zone": "rack-a", "zonePriority": ["rack-b"], "failoverThreshold": 0.5}}]}
+type zoneAwareConfig struct {
+	serviceconfig.LoadBalancingConfig `json:"-"`
+
+	Zone              string   `json:"zone"`
+	ZonePriority      []string `json:"zonePriority,omitempty"`      // 本区之后的切换顺序，未列出的可用区按名称排在最后
+	FailoverThreshold float64  `json:"failoverThreshold,omitempty"` // 默认 0.5
+}
+
+func parseZoneAwareConfig(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	cfg := &zoneAwareConfig{FailoverThreshold: 0.5}
+	if err := json.Unmarshal(js, cfg); err != nil {
+		return nil, fmt.Errorf("%s: invalid config %s: %v", zoneAwareName, js, err)
+	}
+	if cfg.FailoverThreshold < 0 || cfg.FailoverThreshold > 1 {
+		return nil, fmt.Errorf("%s: failoverThreshold must be in [0, 1]", zoneAwareName)
+	}
+	return cfg, nil
+}
+
+type zoneAwarePickerBuilder struct {
+	config zoneAwareConfig
+	totals map[string]int // 可用区 -> 解析器给出的后端总数
+	active string         // 当前使用的可用区，只在切换时打印日志
+}
+
+func newZoneAwarePickerBuilder() base.PickerBuilder {
+	return &zoneAwarePickerBuilder{config: zoneAwareConfig{FailoverThreshold: 0.5}}
+}
+
+func (b *zoneAwarePickerBuilder) UpdateConfig(cfg serviceconfig.LoadBalancingConfig) bool {
+	c, ok := cfg.(*zoneAwareConfig)
+	if !ok {
+		return false
+	}
+	changed := c.Zone != b.config.Zone || c.FailoverThreshold != b.config.FailoverThreshold ||
+		strings.Join(c.ZonePriority, ",") != strings.Join(b.config.ZonePriority, ",")
+	b.config = *c
+	return changed
+}
+
+func (b *zoneAwarePickerBuilder) UpdateAddresses(addrs []resolver.Address) bool {
+	totals := make(map[string]int)
+	for _, a := range addrs {
+		totals[addressZone(a)]++
+	}
+	changed := len(totals) != len(b.totals)
+	for zone, n := range totals {
+		if b.totals[zone] != n {
+			changed = true
+		}
+	}
+	b.totals = totals
+	return changed
+}
+
+// addressZone 读取地址的可用区，没有设置时为 ""
+func addressZone(addr resolver.Address) string {
+	zone, _ := addr.Attributes.Value(zoneKey).(string)
+	return zone
+}
+
+// zoneOrder 返回可用区的尝试顺序：本区、zonePriority、其余可用区
+func (b *zoneAwarePickerBuilder) zoneOrder() []string {
+	seen := make(map[string]bool)
+	var order []string
+	for _, zone := range append([]string{b.config.Zone}, b.config.ZonePriority...) {
+		if !seen[zone] {
+			seen[zone] = true
+			order = append(order, zone)
+		}
+	}
+	var rest []string
+	for zone := range b.totals {
+		if !seen[zone] {
+			rest = append(rest, zone)
+		}
+	}
+	sort.Strings(rest)
+	return append(order, rest...)
+}
+
+func (b *zoneAwarePickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	ready := make(map[string]map[balancer.SubConn]base.SubConnInfo)
+	for sc, scInfo := range info.ReadySCs {
+		zone := addressZone(scInfo.Address)
+		if ready[zone] == nil {
+			ready[zone] = make(map[balancer.SubConn]base.SubConnInfo)
+		}
+		ready[zone][sc] = scInfo
+	}
+
+	for _, zone := range b.zoneOrder() {
+		total := b.totals[zone]
+		if total == 0 || len(ready[zone]) == 0 {
+			continue
+		}
+		if healthy := float64(len(ready[zone])) / float64(total); healthy >= b.config.FailoverThreshold {
+			b.activate(zone, fmt.Sprintf("%d/%d healthy", len(ready[zone]), total))
+			return newZonePicker(zone, ready[zone])
+		}
+	}
+	b.activate("*", fmt.Sprintf("no zone above threshold %.2f, %d healthy backends", b.config.FailoverThreshold, len(info.ReadySCs)))
+	return newZonePicker("*", info.ReadySCs)
+}
+
+// activate 记录当前使用的可用区，"*" 表示全部可用区
+func (b *zoneAwarePickerBuilder) activate(zone, reason string) {
+	if zone == b.active {
+		return
+	}
+	log.Printf("%s: local zone %q, routing to %q (%s)", zoneAwareName, b.config.Zone, zone, reason)
+	b.active = zone
+}
+
+// zonePicker 在选定的可用区内按权重轮询
+type zonePicker struct {
+	zone     string
+	subConns []balancer.SubConn // 按权重重复
+	next     uint32
+}
+
+func newZonePicker(zone string, scs map[balancer.SubConn]base.SubConnInfo) *zonePicker {
+	p := &zonePicker{zone: zone}
+	for sc, scInfo := range scs {
+		for i := int64(0); i < addressWeight(scInfo); i++ {
+			p.subConns = append(p.subConns, sc)
+		}
+	}
+	return p
+}
+
+func (p *zonePicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	n := atomic.AddUint32(&p.next, 1)
+	return balancer.PickResult{SubConn: p.subConns[int(n)%len(p.subConns)]}, nil
+}
+
+func init() {
+	balancer.Register(newBalancerBuilder(zoneAwareName, newZoneAwarePickerBuilder, base.Config{HealthCheck: true}).withConfigParser(parseZoneAwareConfig))
+}
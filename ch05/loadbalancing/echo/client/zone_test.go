@@ -0,0 +1,67 @@
+package main
+
+import (
+	"google.golang.org/grpc/attributes"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/resolver"
+	"testing"
+	"time"
+)
+
+// waitForOnlyPeers 发送 RPC 直到连续一轮请求都由 want 中的后端处理且每个后端都处理过，
+// 健康检查结果需要一段时间才能传到负载均衡器
+func waitForOnlyPeers(t *testing.T, client ecpb.EchoClient, want ...string) {
+	t.Helper()
+	var counts map[string]int
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		counts = countPeers(t, client, 10*len(want))
+		ok := len(counts) == len(want)
+		for _, addr := range want {
+			ok = ok && counts[addr] > 0
+		}
+		if ok {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("want RPCs spread over %v only, last round got %v", want, counts)
+}
+
+// 客户端位于 rack-a，切换顺序为 rack-c、rack-b：本区健康后端不足一半时切到 rack-c，
+// rack-c 也不可用时切到 rack-b，rack-a 恢复后切回
+func TestZoneAwareFailover(t *testing.T) {
+	var backends []*testBackend
+	var addrs []resolver.Address
+	for _, zone := range []string{"rack-a", "rack-a", "rack-b", "rack-b", "rack-c", "rack-c"} {
+		b := startTestBackend(t, 0)
+		backends = append(backends, b)
+		addrs = append(addrs, resolver.Address{Addr: b.addr, Attributes: attributes.New(weightKey, uint32(1), zoneKey, zone)})
+	}
+	rackA := []string{backends[0].addr, backends[1].addr}
+	rackB := []string{backends[2].addr, backends[3].addr}
+	rackC := []string{backends[4].addr, backends[5].addr}
+
+	client := dialBackends(t, zoneAwareName, map[string]interface{}{
+		"zone":              "rack-a",
+		"zonePriority":      []string{"rack-c", "rack-b"},
+		"failoverThreshold": 0.5,
+	}, addrs)
+
+	waitForOnlyPeers(t, client, rackA...)
+
+	// 1/2 健康，未低于阈值，留在本区
+	backends[0].setServing(false)
+	waitForOnlyPeers(t, client, backends[1].addr)
+
+	backends[1].setServing(false)
+	waitForOnlyPeers(t, client, rackC...)
+
+	backends[4].setServing(false)
+	backends[5].setServing(false)
+	waitForOnlyPeers(t, client, rackB...)
+
+	backends[0].setServing(true)
+	backends[1].setServing(true)
+	waitForOnlyPeers(t, client, rackA...)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/status"
+	"io"
+	"log"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// backend 是一个 Echo 后端，响应中带有后端地址，便于观察负载均衡的结果
+type backend struct {
+	ecpb.UnimplementedEchoServer
+	addr      string
+	latency   time.Duration // 每条响应前的额外延迟
+	errorRate float64       // 以 Unavailable 失败的 RPC 比例
+
+	requests int64 // 收到的 RPC 数
+	failures int64 // 注入失败的 RPC 数
+}
+
+func (b *backend) tag(message string) *ecpb.EchoResponse {
+	return &ecpb.EchoResponse{Message: fmt.Sprintf("%s from %s", message, b.addr)}
+}
+
+// begin 统计请求并按比例注入错误
+func (b *backend) begin() error {
+	atomic.AddInt64(&b.requests, 1)
+	if b.errorRate > 0 && rand.Float64() < b.errorRate {
+		atomic.AddInt64(&b.failures, 1)
+		return status.Errorf(codes.Unavailable, "injected error from %s", b.addr)
+	}
+	return nil
+}
+
+// delay 模拟处理耗时，客户端取消或超时后立即返回
+func (b *backend) delay(ctx context.Context) error {
+	if b.latency <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(b.latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (b *backend) UnaryEcho(ctx context.Context, req *ecpb.EchoRequest) (*ecpb.EchoResponse, error) {
+	if err := b.begin(); err != nil {
+		return nil, err
+	}
+	if err := b.delay(ctx); err != nil {
+		return nil, err
+	}
+	return b.tag(req.GetMessage()), nil
+}
+
+// ServerStreamingEcho 将消息按空格拆分，逐个返回
+func (b *backend) ServerStreamingEcho(req *ecpb.EchoRequest, stream ecpb.Echo_ServerStreamingEchoServer) error {
+	if err := b.begin(); err != nil {
+		return err
+	}
+	for _, word := range strings.Fields(req.GetMessage()) {
+		if err := b.delay(stream.Context()); err != nil {
+			return err
+		}
+		if err := stream.Send(b.tag(word)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClientStreamingEcho 将收到的消息用空格连接后返回
+func (b *backend) ClientStreamingEcho(stream ecpb.Echo_ClientStreamingEchoServer) error {
+	if err := b.begin(); err != nil {
+		return err
+	}
+	var messages []string
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			if err := b.delay(stream.Context()); err != nil {
+				return err
+			}
+			return stream.SendAndClose(b.tag(strings.Join(messages, " ")))
+		}
+		if err != nil {
+			return err
+		}
+		messages = append(messages, req.GetMessage())
+	}
+}
+
+func (b *backend) BidirectionalStreamingEcho(stream ecpb.Echo_BidirectionalStreamingEchoServer) error {
+	if err := b.begin(); err != nil {
+		return err
+	}
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := b.delay(stream.Context()); err != nil {
+			return err
+		}
+		if err := stream.Send(b.tag(req.GetMessage())); err != nil {
+			return err
+		}
+	}
+}
+
+func (b *backend) logStats() {
+	log.Printf("%s: %d requests, %d injected errors", b.addr,
+		atomic.LoadInt64(&b.requests), atomic.LoadInt64(&b.failures))
+}
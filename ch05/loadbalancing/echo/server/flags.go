@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// backendFlag 是按后端地址设置的参数，格式为 "addr=value,addr=value"，addr 为 "*" 时作用于所有后端，
+// 例如 -latency '*=10ms,:50052=500ms'
+type backendFlag map[string]string
+
+func (f backendFlag) String() string {
+	var pairs []string
+	for addr, v := range f {
+		pairs = append(pairs, addr+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f backendFlag) Set(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("invalid %q, want addr=value", pair)
+		}
+		f[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return nil
+}
+
+// get 返回 addr 的值，没有单独设置时使用 "*" 的值
+func (f backendFlag) get(addr string) (string, bool) {
+	if v, ok := f[addr]; ok {
+		return v, true
+	}
+	v, ok := f["*"]
+	return v, ok
+}
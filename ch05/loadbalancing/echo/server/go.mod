@@ -0,0 +1,17 @@
+module echo/server
+
+go 1.17
+
+require (
+	google.golang.org/grpc v1.41.0
+	google.golang.org/grpc/examples v0.0.0-20211021223902-4f21cde702d9
+)
+
+require (
+	github.com/golang/protobuf v1.4.3 // indirect
+	golang.org/x/net v0.0.0-20200822124328-c89045814202 // indirect
+	golang.org/x/sys v0.0.0-20200323222414-85ca7c5b95cd // indirect
+	golang.org/x/text v0.3.0 // indirect
+	google.golang.org/genproto v0.0.0-20200806141610-86f49bd18e98 // indirect
+	google.golang.org/protobuf v1.25.0 // indirect
+)
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"google.golang.org/grpc"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 在一个进程中启动多个 Echo 后端，供负载均衡客户端使用，例如：
+// go run . -addrs :50051,:50052,:50053 -latency :50052=200ms -error-rate :50053=0.3
+var (
+	addrs      = flag.String("addrs", ":50051,:50052", "comma separated listen addresses, one Echo backend per address")
+	latency    = backendFlag{}
+	errorRate  = backendFlag{}
+	notServing = flag.String("not-serving", "", "comma separated addresses whose health status is NOT_SERVING")
+)
+
+func init() {
+	flag.Var(latency, "latency", "per backend response latency, for example '*=10ms,:50052=500ms'")
+	flag.Var(errorRate, "error-rate", "per backend fraction of RPCs failed with Unavailable, for example ':50053=0.3'")
+}
+
+// newBackend 根据参数创建 addr 上的后端
+func newBackend(addr string) (*backend, error) {
+	b := &backend{addr: addr}
+	if v, ok := latency.get(addr); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, err
+		}
+		b.latency = d
+	}
+	if v, ok := errorRate.get(addr); ok {
+		rate, err := parseErrorRate(v)
+		if err != nil {
+			return nil, err
+		}
+		b.errorRate = rate
+	}
+	return b, nil
+}
+
+// parseErrorRate 解析错误比例，必须在 [0, 1] 范围内
+func parseErrorRate(v string) (float64, error) {
+	rate, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, err
+	}
+	if !(rate >= 0 && rate <= 1) {
+		return 0, fmt.Errorf("error rate %v out of range [0, 1]", v)
+	}
+	return rate, nil
+}
+
+func main() {
+	flag.Parse()
+	// 启动前检查所有 -error-rate，包括没有出现在 -addrs 中的地址
+	for addr, v := range errorRate {
+		if _, err := parseErrorRate(v); err != nil {
+			log.Fatalf("invalid -error-rate for %s: %v", addr, err)
+		}
+	}
+	unhealthy := make(map[string]bool)
+	for _, addr := range strings.Split(*notServing, ",") {
+		unhealthy[strings.TrimSpace(addr)] = true
+	}
+
+	var servers []*grpc.Server
+	var backends []*backend
+	var wg sync.WaitGroup
+	for _, addr := range strings.Split(*addrs, ",") {
+		addr = strings.TrimSpace(addr)
+		b, err := newBackend(addr)
+		if err != nil {
+			log.Fatalf("invalid options for %s: %v", addr, err)
+		}
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			log.Fatalf("failed to listen: %v", err)
+		}
+		s := grpc.NewServer()
+		ecpb.RegisterEchoServer(s, b)
+
+		// 健康检查：-not-serving 中的后端报告 NOT_SERVING，客户端启用健康检查后不会选择它
+		hs := health.NewServer()
+		if unhealthy[addr] {
+			hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+		}
+		healthpb.RegisterHealthServer(s, hs)
+
+		servers = append(servers, s)
+		backends = append(backends, b)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Serve(lis); err != nil {
+				log.Printf("failed to serve: %v", err)
+			}
+		}()
+		log.Printf("Echo backend on %s (latency %v, error rate %.2f, serving %v)", addr, b.latency, b.errorRate, !unhealthy[addr])
+	}
+
+	// Ctrl-C 时输出各后端收到的请求数
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+	for i, s := range servers {
+		s.Stop()
+		backends[i].logStats()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,10 @@
+module mdcodec
+
+go 1.17
+
+require (
+	github.com/golang/protobuf v1.4.3
+	google.golang.org/grpc v1.41.0
+)
+
+require google.golang.org/protobuf v1.25.0 // indirect
@@ -0,0 +1,162 @@
+// Package mdcodec 提供类型化的 gRPC 元数据 key，负责值的编码、解码与校验。
+//
+// 普通 key 的值以 ASCII 文本传输；protobuf 消息使用以 -bin 结尾的二进制 key，
+// gRPC 会在传输时自动对其进行 base64 编码。
+package mdcodec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/metadata"
+	"strings"
+)
+
+const (
+	binarySuffix = "-bin"
+	// DefaultMaxValueSize 是单个值的默认大小上限，gRPC 默认的头部总大小上限为 16KB
+	DefaultMaxValueSize = 8 * 1024
+	// DefaultMaxSize 是整个元数据的默认大小上限
+	DefaultMaxSize = 16 * 1024
+)
+
+var (
+	ErrNotFound      = errors.New("mdcodec: key not found")
+	ErrReservedKey   = errors.New("mdcodec: key uses a reserved prefix")
+	ErrInvalidKey    = errors.New("mdcodec: invalid key")
+	ErrValueTooLarge = errors.New("mdcodec: value too large")
+	ErrInvalidValue  = errors.New("mdcodec: invalid value")
+)
+
+// key 是各类型化 key 共用的部分：名称校验、大小限制和与元数据/上下文的读写
+type key struct {
+	name    string
+	binary  bool
+	maxSize int
+}
+
+// newKey 校验 key 名称：只能包含 0-9 a-z - _ .，不能使用 grpc- 保留前缀或 : 伪头部，
+// 二进制 key 必须以 -bin 结尾，文本 key 则不能以 -bin 结尾
+func newKey(name string, binary bool) (key, error) {
+	name = strings.ToLower(name)
+	if name == "" {
+		return key{}, fmt.Errorf("%w: empty name", ErrInvalidKey)
+	}
+	if strings.HasPrefix(name, "grpc-") || strings.HasPrefix(name, ":") {
+		return key{}, fmt.Errorf("%w: %q", ErrReservedKey, name)
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return key{}, fmt.Errorf("%w: %q contains %q", ErrInvalidKey, name, c)
+		}
+	}
+	if hasSuffix := strings.HasSuffix(name, binarySuffix); hasSuffix != binary {
+		if binary {
+			return key{}, fmt.Errorf("%w: binary key %q must end with %s", ErrInvalidKey, name, binarySuffix)
+		}
+		return key{}, fmt.Errorf("%w: text key %q must not end with %s", ErrInvalidKey, name, binarySuffix)
+	}
+	return key{name: name, binary: binary, maxSize: DefaultMaxValueSize}, nil
+}
+
+func mustKey(name string, binary bool) key {
+	k, err := newKey(name, binary)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+// Name 返回 key 的名称
+func (k key) Name() string {
+	return k.name
+}
+
+// check 校验编码后的值：文本值只能包含可打印 ASCII 字符，并且不能超过大小上限
+func (k key) check(v string) error {
+	if len(v) > k.maxSize {
+		return fmt.Errorf("%w: %s is %d bytes, limit %d", ErrValueTooLarge, k.name, len(v), k.maxSize)
+	}
+	if !k.binary {
+		for i := 0; i < len(v); i++ {
+			if v[i] < 0x20 || v[i] > 0x7E {
+				return fmt.Errorf("%w: %s contains non-printable byte 0x%02x", ErrInvalidValue, k.name, v[i])
+			}
+		}
+	}
+	return nil
+}
+
+// set 替换 md 中 key 的值，替换后元数据总大小超过 DefaultMaxSize 时不修改 md
+func (k key) set(md metadata.MD, v string) error {
+	if err := k.check(v); err != nil {
+		return err
+	}
+	size := Size(md) + entrySize(k.name, v)
+	for _, old := range md[k.name] {
+		size -= entrySize(k.name, old)
+	}
+	if err := checkSize(size, DefaultMaxSize); err != nil {
+		return err
+	}
+	md.Set(k.name, v)
+	return nil
+}
+
+// appendTo 向上下文的待发送元数据追加值，追加后总大小超过 DefaultMaxSize 时返回原来的 ctx
+func (k key) appendTo(ctx context.Context, v string) (context.Context, error) {
+	if err := k.check(v); err != nil {
+		return ctx, err
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if err := checkSize(Size(md)+entrySize(k.name, v), DefaultMaxSize); err != nil {
+		return ctx, err
+	}
+	return metadata.AppendToOutgoingContext(ctx, k.name, v), nil
+}
+
+// get 返回 key 的最后一个值，多个值时以最后设置的为准
+func (k key) get(md metadata.MD) (string, error) {
+	values := md.Get(k.name)
+	if len(values) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, k.name)
+	}
+	return values[len(values)-1], nil
+}
+
+func (k key) incoming(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return k.get(md)
+}
+
+func (k key) valueError(v string, err error) error {
+	return fmt.Errorf("%w: %s=%q: %v", ErrInvalidValue, k.name, v, err)
+}
+
+// Size 计算元数据编码后的近似大小（key 与值的长度之和，每个条目另加 32 字节开销，与 HTTP/2 的计算方式一致）
+func Size(md metadata.MD) int {
+	size := 0
+	for k, values := range md {
+		for _, v := range values {
+			size += entrySize(k, v)
+		}
+	}
+	return size
+}
+
+func entrySize(k, v string) int {
+	return len(k) + len(v) + 32
+}
+
+// CheckSize 检查元数据总大小是否超过 limit。各 key 的写入方法已按 DefaultMaxSize 检查，
+// 直接修改 metadata.MD 或使用更小的上限时可以调用它
+func CheckSize(md metadata.MD, limit int) error {
+	return checkSize(Size(md), limit)
+}
+
+func checkSize(size, limit int) error {
+	if size > limit {
+		return fmt.Errorf("%w: metadata is %d bytes, limit %d", ErrValueTooLarge, size, limit)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package mdcodec
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc/metadata"
+	"strings"
+	"testing"
+	"time"
+)
+
+// outgoingToIncoming 把上下文中待发送的元数据作为服务端收到的元数据
+func outgoingToIncoming(t *testing.T, ctx context.Context) context.Context {
+	t.Helper()
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		t.Fatal("no outgoing metadata")
+	}
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+func TestRoundTrip(t *testing.T) {
+	now := time.Date(2021, 10, 21, 22, 39, 2, 123456789, time.FixedZone("UTC+8", 8*3600))
+
+	t.Run("String", func(t *testing.T) {
+		k := String("x-request-id")
+		md := metadata.MD{}
+		if err := k.Set(md, "req-1"); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		if v, err := k.Get(md); err != nil || v != "req-1" {
+			t.Errorf("Get = %q, %v, want req-1", v, err)
+		}
+		ctx, err := k.AppendToOutgoing(context.Background(), "req-2")
+		if err != nil {
+			t.Fatalf("AppendToOutgoing: %v", err)
+		}
+		if v, err := k.FromIncoming(outgoingToIncoming(t, ctx)); err != nil || v != "req-2" {
+			t.Errorf("FromIncoming = %q, %v, want req-2", v, err)
+		}
+	})
+
+	t.Run("Int", func(t *testing.T) {
+		k := Int("x-records")
+		md := metadata.MD{}
+		if err := k.Set(md, -42); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		if v, err := k.Get(md); err != nil || v != -42 {
+			t.Errorf("Get = %d, %v, want -42", v, err)
+		}
+		ctx, err := k.AppendToOutgoing(context.Background(), 7)
+		if err != nil {
+			t.Fatalf("AppendToOutgoing: %v", err)
+		}
+		if v, err := k.FromIncoming(outgoingToIncoming(t, ctx)); err != nil || v != 7 {
+			t.Errorf("FromIncoming = %d, %v, want 7", v, err)
+		}
+	})
+
+	t.Run("Time", func(t *testing.T) {
+		k := Time("timestamp")
+		md := metadata.MD{}
+		if err := k.Set(md, now); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		if v, err := k.Get(md); err != nil || !v.Equal(now) {
+			t.Errorf("Get = %v, %v, want %v", v, err, now)
+		}
+		ctx, err := k.AppendToOutgoing(context.Background(), now)
+		if err != nil {
+			t.Fatalf("AppendToOutgoing: %v", err)
+		}
+		if v, err := k.FromIncoming(outgoingToIncoming(t, ctx)); err != nil || !v.Equal(now) {
+			t.Errorf("FromIncoming = %v, %v, want %v", v, err, now)
+		}
+	})
+
+	t.Run("Duration", func(t *testing.T) {
+		k := Duration("x-budget")
+		md := metadata.MD{}
+		if err := k.Set(md, 1500*time.Millisecond); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		if v, err := k.Get(md); err != nil || v != 1500*time.Millisecond {
+			t.Errorf("Get = %v, %v, want 1.5s", v, err)
+		}
+		ctx, err := k.AppendToOutgoing(context.Background(), time.Microsecond)
+		if err != nil {
+			t.Fatalf("AppendToOutgoing: %v", err)
+		}
+		if v, err := k.FromIncoming(outgoingToIncoming(t, ctx)); err != nil || v != time.Microsecond {
+			t.Errorf("FromIncoming = %v, %v, want 1µs", v, err)
+		}
+	})
+
+	t.Run("Proto", func(t *testing.T) {
+		k := Proto("x-value-bin", (*wrappers.StringValue)(nil))
+		want := &wrappers.StringValue{Value: "binary \\x00\\xff value"}
+		md := metadata.MD{}
+		if err := k.Set(md, want); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		var got wrappers.StringValue
+		if err := k.Get(md, &got); err != nil || !proto.Equal(&got, want) {
+			t.Errorf("Get = %v, %v, want %v", &got, err, want)
+		}
+		ctx, err := k.AppendToOutgoing(context.Background(), want)
+		if err != nil {
+			t.Fatalf("AppendToOutgoing: %v", err)
+		}
+		got.Reset()
+		if err := k.FromIncoming(outgoingToIncoming(t, ctx), &got); err != nil || !proto.Equal(&got, want) {
+			t.Errorf("FromIncoming = %v, %v, want %v", &got, err, want)
+		}
+
+		// 声明类型之外的消息既不能写入也不能读出
+		if err := k.Set(md, &wrappers.Int64Value{Value: 1}); !errors.Is(err, ErrInvalidValue) {
+			t.Errorf("Set with another message type: %v, want ErrInvalidValue", err)
+		}
+		if err := k.Get(md, &wrappers.Int64Value{}); !errors.Is(err, ErrInvalidValue) {
+			t.Errorf("Get into another message type: %v, want ErrInvalidValue", err)
+		}
+	})
+}
+
+func TestGetErrors(t *testing.T) {
+	md := metadata.Pairs("x-records", "many")
+	if _, err := Int("x-records").Get(md); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Get of a malformed int: %v, want ErrInvalidValue", err)
+	}
+	if _, err := String("x-missing").Get(md); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a missing key: %v, want ErrNotFound", err)
+	}
+	// 多个值时以最后一个为准
+	md.Append("x-records", "1", "2")
+	if v, err := Int("x-records").Get(md); err != nil || v != 2 {
+		t.Errorf("Get = %d, %v, want 2", v, err)
+	}
+}
+
+func TestKeyNames(t *testing.T) {
+	cases := []struct {
+		name   string
+		binary bool
+		want   error
+	}{
+		{"X-Request-Id", false, nil},
+		{"", false, ErrInvalidKey},
+		{"grpc-timeout", false, ErrReservedKey},
+		{":authority", false, ErrReservedKey},
+		{"x request", false, ErrInvalidKey},
+		{"x-data-bin", false, ErrInvalidKey},
+		{"x-data", true, ErrInvalidKey},
+		{"x-data-bin", true, nil},
+	}
+	for _, c := range cases {
+		k, err := newKey(c.name, c.binary)
+		if !errors.Is(err, c.want) {
+			t.Errorf("newKey(%q, %v) = %v, want %v", c.name, c.binary, err, c.want)
+		}
+		if err == nil && k.Name() != strings.ToLower(c.name) {
+			t.Errorf("newKey(%q).Name() = %q, want lower case", c.name, k.Name())
+		}
+	}
+}
+
+func TestValueLimits(t *testing.T) {
+	k := String("x-note")
+	md := metadata.MD{}
+	if err := k.Set(md, "line\nbreak"); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Set with a non-printable byte: %v, want ErrInvalidValue", err)
+	}
+	if err := k.Set(md, strings.Repeat("a", DefaultMaxValueSize+1)); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Set with a large value: %v, want ErrValueTooLarge", err)
+	}
+	if _, err := k.AppendToOutgoing(context.Background(), strings.Repeat("a", DefaultMaxValueSize+1)); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("AppendToOutgoing with a large value: %v, want ErrValueTooLarge", err)
+	}
+}
+
+// 每个值都未超过单值上限，但合计超过 DefaultMaxSize 时写入失败，且不修改已有的元数据
+func TestTotalSizeLimit(t *testing.T) {
+	value := strings.Repeat("a", DefaultMaxValueSize-100)
+	a, b, c := String("x-a"), String("x-b"), String("x-c")
+
+	md := metadata.MD{}
+	if err := a.Set(md, value); err != nil {
+		t.Fatalf("Set x-a: %v", err)
+	}
+	if err := b.Set(md, value); err != nil {
+		t.Fatalf("Set x-b: %v", err)
+	}
+	if err := c.Set(md, value); !errors.Is(err, ErrValueTooLarge) {
+		t.Fatalf("Set x-c: %v, want ErrValueTooLarge", err)
+	}
+	if _, found := md["x-c"]; found {
+		t.Error("x-c written although the total size limit was exceeded")
+	}
+	// 替换已有的值只计算新值
+	if err := a.Set(md, value); err != nil {
+		t.Errorf("replacing x-a: %v", err)
+	}
+	if err := CheckSize(md, DefaultMaxSize); err != nil {
+		t.Errorf("CheckSize: %v", err)
+	}
+
+	ctx, err := a.AppendToOutgoing(context.Background(), value)
+	if err == nil {
+		ctx, err = b.AppendToOutgoing(ctx, value)
+	}
+	if err != nil {
+		t.Fatalf("AppendToOutgoing: %v", err)
+	}
+	if _, err := c.AppendToOutgoing(ctx, value); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("AppendToOutgoing x-c: %v, want ErrValueTooLarge", err)
+	}
+}
@@ -0,0 +1,194 @@
+package mdcodec
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/metadata"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StringKey 是值为字符串的 key
+type StringKey struct{ key }
+
+// String 声明一个字符串 key，名称不合法时 panic，适合在包级变量中声明
+func String(name string) StringKey { return StringKey{mustKey(name, false)} }
+
+func (k StringKey) Set(md metadata.MD, v string) error { return k.set(md, v) }
+
+func (k StringKey) Get(md metadata.MD) (string, error) { return k.get(md) }
+
+func (k StringKey) AppendToOutgoing(ctx context.Context, v string) (context.Context, error) {
+	return k.appendTo(ctx, v)
+}
+
+func (k StringKey) FromIncoming(ctx context.Context) (string, error) { return k.incoming(ctx) }
+
+// IntKey 是值为十进制整数的 key
+type IntKey struct{ key }
+
+func Int(name string) IntKey { return IntKey{mustKey(name, false)} }
+
+func (k IntKey) encode(v int64) string { return strconv.FormatInt(v, 10) }
+
+func (k IntKey) decode(s string, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, k.valueError(s, err)
+	}
+	return v, nil
+}
+
+func (k IntKey) Set(md metadata.MD, v int64) error { return k.set(md, k.encode(v)) }
+
+func (k IntKey) Get(md metadata.MD) (int64, error) { return k.decode(k.get(md)) }
+
+func (k IntKey) AppendToOutgoing(ctx context.Context, v int64) (context.Context, error) {
+	return k.appendTo(ctx, k.encode(v))
+}
+
+func (k IntKey) FromIncoming(ctx context.Context) (int64, error) { return k.decode(k.incoming(ctx)) }
+
+// TimeKey 是值为时间的 key，以 RFC 3339 格式（纳秒精度）传输
+type TimeKey struct{ key }
+
+func Time(name string) TimeKey { return TimeKey{mustKey(name, false)} }
+
+func (k TimeKey) encode(v time.Time) string { return v.UTC().Format(time.RFC3339Nano) }
+
+func (k TimeKey) decode(s string, err error) (time.Time, error) {
+	if err != nil {
+		return time.Time{}, err
+	}
+	v, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, k.valueError(s, err)
+	}
+	return v, nil
+}
+
+func (k TimeKey) Set(md metadata.MD, v time.Time) error { return k.set(md, k.encode(v)) }
+
+func (k TimeKey) Get(md metadata.MD) (time.Time, error) { return k.decode(k.get(md)) }
+
+func (k TimeKey) AppendToOutgoing(ctx context.Context, v time.Time) (context.Context, error) {
+	return k.appendTo(ctx, k.encode(v))
+}
+
+func (k TimeKey) FromIncoming(ctx context.Context) (time.Time, error) {
+	return k.decode(k.incoming(ctx))
+}
+
+// DurationKey 是值为时间段的 key，以 Go 的时间段格式（如 1.5s、300ms）传输，微秒写作 us 以保持 ASCII
+type DurationKey struct{ key }
+
+func Duration(name string) DurationKey { return DurationKey{mustKey(name, false)} }
+
+func (k DurationKey) encode(v time.Duration) string {
+	return strings.Replace(v.String(), "µs", "us", 1)
+}
+
+func (k DurationKey) decode(s string, err error) (time.Duration, error) {
+	if err != nil {
+		return 0, err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, k.valueError(s, err)
+	}
+	return v, nil
+}
+
+func (k DurationKey) Set(md metadata.MD, v time.Duration) error { return k.set(md, k.encode(v)) }
+
+func (k DurationKey) Get(md metadata.MD) (time.Duration, error) { return k.decode(k.get(md)) }
+
+func (k DurationKey) AppendToOutgoing(ctx context.Context, v time.Duration) (context.Context, error) {
+	return k.appendTo(ctx, k.encode(v))
+}
+
+func (k DurationKey) FromIncoming(ctx context.Context) (time.Duration, error) {
+	return k.decode(k.incoming(ctx))
+}
+
+// ProtoKey 是值为 protobuf 消息的二进制 key，名称必须以 -bin 结尾。
+// 只能读写声明时指定类型的消息，Get 解码到调用方传入的同类型消息中
+type ProtoKey struct {
+	key
+	typ reflect.Type
+}
+
+// Proto 声明一个 protobuf 消息 key，消息类型由 example 指定，例如：
+//
+//	var userKey = mdcodec.Proto("x-user-bin", (*pb.User)(nil))
+func Proto(name string, example proto.Message) ProtoKey {
+	return ProtoKey{key: mustKey(name, true), typ: reflect.TypeOf(example)}
+}
+
+// checkType 校验 v 是否为声明时的消息类型
+func (k ProtoKey) checkType(v proto.Message) error {
+	if t := reflect.TypeOf(v); t != k.typ {
+		return fmt.Errorf("%w: %s holds %v, got %v", ErrInvalidValue, k.name, k.typ, t)
+	}
+	return nil
+}
+
+func (k ProtoKey) encode(v proto.Message) (string, error) {
+	if err := k.checkType(v); err != nil {
+		return "", err
+	}
+	b, err := proto.Marshal(v)
+	if err != nil {
+		return "", k.valueError(v.String(), err)
+	}
+	return string(b), nil
+}
+
+func (k ProtoKey) decode(s string, into proto.Message) error {
+	if err := k.checkType(into); err != nil {
+		return err
+	}
+	if err := proto.Unmarshal([]byte(s), into); err != nil {
+		return k.valueError(s, err)
+	}
+	return nil
+}
+
+func (k ProtoKey) Set(md metadata.MD, v proto.Message) error {
+	s, err := k.encode(v)
+	if err != nil {
+		return err
+	}
+	return k.set(md, s)
+}
+
+// Get 把 key 的值解码到 into 中
+func (k ProtoKey) Get(md metadata.MD, into proto.Message) error {
+	s, err := k.get(md)
+	if err != nil {
+		return err
+	}
+	return k.decode(s, into)
+}
+
+func (k ProtoKey) AppendToOutgoing(ctx context.Context, v proto.Message) (context.Context, error) {
+	s, err := k.encode(v)
+	if err != nil {
+		return ctx, err
+	}
+	return k.appendTo(ctx, s)
+}
+
+func (k ProtoKey) FromIncoming(ctx context.Context, into proto.Message) error {
+	s, err := k.incoming(ctx)
+	if err != nil {
+		return err
+	}
+	return k.decode(s, into)
+}
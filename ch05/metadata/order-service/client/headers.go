@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"mdcodec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 与服务端约定的响应头和 trailer key
+var (
+	serverIdHeader      = mdcodec.String("x-server-id")
+	requestIdHeader     = mdcodec.String("x-request-id")
+	serverTimingTrailer = mdcodec.String("server-timing")
+	recordsRecvTrailer  = mdcodec.Int("x-records-received")
+	recordsSentTrailer  = mdcodec.Int("x-records-sent")
+)
+
+// responseMeta 是从响应头和 trailer 中解析出的服务端信息
+type responseMeta struct {
+	ServerId        string
+	RequestId       string
+	ServerTiming    time.Duration // 服务端处理耗时
+	RecordsReceived int64         // 服务端收到的消息数
+	RecordsSent     int64         // 服务端发送的消息数
+}
+
+func (m responseMeta) String() string {
+	return fmt.Sprintf("server=%s request=%s timing=%v received=%d sent=%d",
+		m.ServerId, m.RequestId, m.ServerTiming, m.RecordsReceived, m.RecordsSent)
+}
+
+// parseResponseMeta 将响应头和 trailer 解析为 responseMeta，缺失的字段保持零值
+func parseResponseMeta(header, trailer metadata.MD) (responseMeta, error) {
+	var m responseMeta
+	var err error
+	if m.ServerId, err = serverIdHeader.Get(header); err != nil && !errors.Is(err, mdcodec.ErrNotFound) {
+		return m, err
+	}
+	if m.RequestId, err = requestIdHeader.Get(header); err != nil && !errors.Is(err, mdcodec.ErrNotFound) {
+		return m, err
+	}
+	if v, err := serverTimingTrailer.Get(trailer); err == nil {
+		if m.ServerTiming, err = parseServerTiming(v); err != nil {
+			return m, err
+		}
+	}
+	if m.RecordsReceived, err = recordsRecvTrailer.Get(trailer); err != nil && !errors.Is(err, mdcodec.ErrNotFound) {
+		return m, err
+	}
+	if m.RecordsSent, err = recordsSentTrailer.Get(trailer); err != nil && !errors.Is(err, mdcodec.ErrNotFound) {
+		return m, err
+	}
+	return m, nil
+}
+
+// parseServerTiming 解析 "total;dur=12.5" 形式的值，dur 的单位为毫秒
+func parseServerTiming(v string) (time.Duration, error) {
+	for _, param := range strings.Split(v, ";") {
+		param = strings.TrimSpace(param)
+		if !strings.HasPrefix(param, "dur=") {
+			continue
+		}
+		ms, err := strconv.ParseFloat(strings.TrimPrefix(param, "dur="), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s %q: %v", serverTimingTrailer.Name(), v, err)
+		}
+		return time.Duration(ms * float64(time.Millisecond)), nil
+	}
+	return 0, fmt.Errorf("invalid %s %q: missing dur", serverTimingTrailer.Name(), v)
+}
+
+// unaryResponseMeta 收集一元 RPC 的响应头和 trailer
+// 用法：var rm unaryResponseMeta; client.GetOrder(ctx, req, rm.CallOptions()...); rm.Meta()
+type unaryResponseMeta struct {
+	header  metadata.MD
+	trailer metadata.MD
+}
+
+func (r *unaryResponseMeta) CallOptions() []grpc.CallOption {
+	return []grpc.CallOption{grpc.Header(&r.header), grpc.Trailer(&r.trailer)}
+}
+
+func (r *unaryResponseMeta) Meta() (responseMeta, error) {
+	return parseResponseMeta(r.header, r.trailer)
+}
+
+// streamResponseMeta 读取流 RPC 的响应头和 trailer，trailer 只有在流结束（Recv 返回 io.EOF 或错误）后才可用
+func streamResponseMeta(stream grpc.ClientStream) (responseMeta, error) {
+	header, err := stream.Header()
+	if err != nil {
+		return responseMeta{}, err
+	}
+	return parseResponseMeta(header, stream.Trailer())
+}
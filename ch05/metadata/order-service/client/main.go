@@ -8,6 +8,7 @@ import (
 	"google.golang.org/grpc/status"
 	"io"
 	"log"
+	"mdcodec"
 	pb "ordermgt/client/ecommerce"
 	"time"
 )
@@ -16,6 +17,9 @@ const (
 	address = "localhost:50051"
 )
 
+// 发送请求的时间
+var timestampKey = mdcodec.Time("timestamp")
+
 func main() {
 	conn, err := grpc.Dial(address, grpc.WithInsecure())
 	if err != nil {
@@ -26,9 +30,12 @@ func main() {
 
 	// metadata 创建元数据
 	md := metadata.Pairs( // "key", "value"
-		"timeStamp", time.Now().Format(time.StampNano),
 		"kn", "vn",
 	)
+	// 类型化的 key 负责值的编码和校验
+	if err := timestampKey.Set(md, time.Now()); err != nil {
+		log.Fatalf("invalid metadata: %v", err)
+	}
 	// 基于新的元数据创建新的上下文
 	mdCtx := metadata.NewOutgoingContext(context.Background(), md)
 	// 在现有的上下文中附加更多的元数据
@@ -54,8 +61,10 @@ func main() {
 	}
 
 	// 获取订单
-	retrievedOrder, err := orderMgtClient.GetOrder(ctx, &wrappers.StringValue{Value: "106"})
+	var getOrderMeta unaryResponseMeta
+	retrievedOrder, err := orderMgtClient.GetOrder(ctx, &wrappers.StringValue{Value: "106"}, getOrderMeta.CallOptions()...)
 	log.Print("GetOrder Response -> : ", retrievedOrder)
+	logResponseMeta("GetOrder", getOrderMeta.Meta)
 
 	searchStream, _ := orderMgtClient.SearchOrders(ctx, &wrappers.StringValue{Value: "Google"})
 	for {
@@ -65,6 +74,7 @@ func main() {
 		}
 		log.Print("Search Result: ", searchOrder)
 	}
+	logResponseMeta("SearchOrders", func() (responseMeta, error) { return streamResponseMeta(searchStream) })
 
 	// updateOrders
 	updOrder1 := pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Google Pixel Book"}, Destination: "Mountain View, CA", Price: 1100.00}
@@ -96,6 +106,7 @@ func main() {
 		log.Fatalf("%v.CloseAndRecv() got error %v, want %v", updateStream, err, nil)
 	}
 	log.Printf("Update Orders Res : %s", updateRes)
+	logResponseMeta("UpdateOrders", func() (responseMeta, error) { return streamResponseMeta(updateStream) })
 
 	// 处理订单
 	streamProcOrder, err := orderMgtClient.ProcessOrders(ctx)
@@ -136,5 +147,16 @@ func asyncClientBidirectionalRPC(streamProcOrder pb.OrderManagement_ProcessOrder
 		}
 		log.Print("Combined shipment : ", combinedShipment.OrdersList)
 	}
+	logResponseMeta("ProcessOrders", func() (responseMeta, error) { return streamResponseMeta(streamProcOrder) })
 	<-c
 }
+
+// logResponseMeta 打印服务端通过响应头和 trailer 返回的信息
+func logResponseMeta(method string, meta func() (responseMeta, error)) {
+	m, err := meta()
+	if err != nil {
+		log.Printf("%s response metadata error: %v", method, err)
+		return
+	}
+	log.Printf("%s response metadata: %s", method, m)
+}
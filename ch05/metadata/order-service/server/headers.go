@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"log"
+	"mdcodec"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// 响应头和 trailer 中使用的类型化元数据 key
+var (
+	serverIdHeader      = mdcodec.String("x-server-id")
+	requestIdHeader     = mdcodec.String("x-request-id")
+	serverTimingTrailer = mdcodec.String("server-timing") // 格式与 HTTP Server-Timing 相同：total;dur=<毫秒>
+	recordsRecvTrailer  = mdcodec.Int("x-records-received")
+	recordsSentTrailer  = mdcodec.Int("x-records-sent")
+)
+
+// serverId 标识当前服务实例，多个实例部署时便于区分响应来自哪个实例
+var serverId = func() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}()
+
+// requestId 优先使用客户端传入的请求 ID，没有时生成新的 ID
+func requestId(ctx context.Context) string {
+	if id, err := requestIdHeader.FromIncoming(ctx); err == nil && id != "" {
+		return id
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// responseHeader 包含实例 ID 和请求 ID
+func responseHeader(ctx context.Context) metadata.MD {
+	md := metadata.MD{}
+	if err := serverIdHeader.Set(md, serverId); err != nil {
+		log.Printf("invalid server id: %v", err)
+	}
+	if err := requestIdHeader.Set(md, requestId(ctx)); err != nil {
+		log.Printf("invalid request id: %v", err)
+	}
+	return md
+}
+
+// responseTrailer 汇总处理耗时与收发的记录数
+func responseTrailer(start time.Time, received, sent int64) metadata.MD {
+	md := metadata.MD{}
+	elapsed := float64(time.Since(start)) / float64(time.Millisecond)
+	if err := serverTimingTrailer.Set(md, fmt.Sprintf("total;dur=%.3f", elapsed)); err != nil {
+		log.Printf("invalid server timing: %v", err)
+	}
+	if err := recordsRecvTrailer.Set(md, received); err != nil {
+		log.Printf("invalid records received: %v", err)
+	}
+	if err := recordsSentTrailer.Set(md, sent); err != nil {
+		log.Printf("invalid records sent: %v", err)
+	}
+	return md
+}
+
+// 一元拦截器：在响应头中发送实例 ID 和请求 ID，在 trailer 中发送处理耗时和记录数
+func headerUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	if err := grpc.SetHeader(ctx, responseHeader(ctx)); err != nil {
+		log.Printf("%s : failed to set header: %v", info.FullMethod, err)
+	}
+
+	m, err := handler(ctx, req)
+
+	var sent int64
+	if err == nil {
+		sent = 1
+	}
+	if err := grpc.SetTrailer(ctx, responseTrailer(start, 1, sent)); err != nil {
+		log.Printf("%s : failed to set trailer: %v", info.FullMethod, err)
+	}
+	return m, err
+}
+
+// countingStream 统计流中成功收发的消息数
+type countingStream struct {
+	grpc.ServerStream
+	received int64
+	sent     int64
+}
+
+func (w *countingStream) RecvMsg(m interface{}) error {
+	err := w.ServerStream.RecvMsg(m)
+	if err == nil {
+		atomic.AddInt64(&w.received, 1)
+	}
+	return err
+}
+
+func (w *countingStream) SendMsg(m interface{}) error {
+	err := w.ServerStream.SendMsg(m)
+	if err == nil {
+		atomic.AddInt64(&w.sent, 1)
+	}
+	return err
+}
+
+// 流拦截器：响应头在处理开始前设置，trailer 在流结束时随状态一起发送
+func headerStreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	if err := ss.SetHeader(responseHeader(ss.Context())); err != nil {
+		log.Printf("%s : failed to set header: %v", info.FullMethod, err)
+	}
+
+	cs := &countingStream{ServerStream: ss}
+	err := handler(srv, cs)
+
+	ss.SetTrailer(responseTrailer(start, atomic.LoadInt64(&cs.received), atomic.LoadInt64(&cs.sent)))
+	return err
+}
@@ -2,14 +2,21 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"github.com/golang/protobuf/ptypes/wrappers"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"graceful"
 	"io"
 	"log"
+	"mdcodec"
 	"net"
 	pb "ordermgt/server/ecommerce"
+	"os"
 	"strings"
 	"time"
 )
@@ -19,6 +26,9 @@ const (
 	orderBatchSize = 3
 )
 
+// 客户端发送请求的时间
+var timestampKey = mdcodec.Time("timestamp")
+
 var orderMap = make(map[string]pb.Order)
 
 type server struct {
@@ -29,6 +39,9 @@ func (s *server) AddOrder(ctx context.Context, orderReq *pb.Order) (*wrappers.St
 	// metadata
 	md, metadataAvailable := metadata.FromIncomingContext(ctx)
 	log.Println("metadata: ", md, metadataAvailable)
+	if sentAt, err := timestampKey.FromIncoming(ctx); err == nil {
+		log.Println("request sent at: ", sentAt, ", latency: ", time.Since(sentAt))
+	}
 	orderMap[orderReq.Id] = *orderReq
 
 	sleepDuration := 5
@@ -46,8 +59,20 @@ func (s *server) AddOrder(ctx context.Context, orderReq *pb.Order) (*wrappers.St
 func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) error {
 	batchMarker := 1
 	var combinedShipmentMap = make(map[string]pb.CombinedShipment)
+	orderIds := recvOrderIds(stream)
 	for {
-		orderId, err := stream.Recv()
+		var r graceful.Received
+		var ok bool
+		select {
+		case r, ok = <-orderIds:
+			if !ok { // 流的上下文已取消
+				return status.FromContextError(stream.Context().Err()).Err()
+			}
+		case <-shuttingDown:
+			return flushShipments(stream, combinedShipmentMap, orderIds, lookupOrder) // 服务器退出前发送已缓存的批次
+		}
+		orderId, _ := r.Msg.(*wrappers.StringValue)
+		err := r.Err
 		log.Printf("Reading Proc order ; %s", orderId)
 		if err == io.EOF {
 			log.Printf("EOF : %s", orderId)
@@ -123,6 +148,10 @@ func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) erro
 		if err == io.EOF {          // 检查流是否已经结束
 			return stream.SendAndClose(&wrappers.StringValue{Value: "Orders processed " + ordersStr}) // 服务端发送响应
 		}
+		if err != nil { // 服务器强制停止、流被重置或取消时 Recv 返回其他错误
+			log.Println(err)
+			return err
+		}
 		orderMap[order.Id] = *order
 
 		log.Println("Order ID ", order.Id, ": Updated")
@@ -131,16 +160,20 @@ func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) erro
 }
 
 func main() {
+	flag.Parse()
 	initSampleData()
 	lis, err := net.Listen("tcp", port)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	s := grpc.NewServer()
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(headerUnaryServerInterceptor),   // 发送响应头和 trailer
+		grpc.StreamInterceptor(headerStreamServerInterceptor), // 流 RPC 同样发送响应头和 trailer
+	)
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(s, healthServer)
 	pb.RegisterOrderManagementServer(s, &server{})
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
-	}
+	os.Exit(serve(s, lis, healthServer))
 }
 
 func initSampleData() {
@@ -6,6 +6,7 @@ require (
 	github.com/golang/protobuf v1.5.2
 	google.golang.org/genproto v0.0.0-20200806141610-86f49bd18e98
 	google.golang.org/grpc v1.41.0
+	graceful v0.0.0
 	google.golang.org/grpc/examples v0.0.0-20211018221244-01ed64857e31
 )
 
@@ -15,3 +16,5 @@ require (
 	golang.org/x/text v0.3.0 // indirect
 	google.golang.org/protobuf v1.26.0 // indirect
 )
+
+replace graceful => ../../../graceful
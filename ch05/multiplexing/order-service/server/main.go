@@ -2,17 +2,22 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"github.com/golang/protobuf/ptypes/wrappers"
 	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	hellopb "google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
+	"graceful"
 	"io"
 	"log"
 	"net"
 	pb "ordermgt/server/ecommerce"
+	"os"
 	"strings"
 	"time"
 )
@@ -22,7 +27,7 @@ const (
 	orderBatchSize = 3
 )
 
-type helloServer struct{
+type helloServer struct {
 	*hellopb.UnimplementedGreeterServer
 }
 
@@ -67,8 +72,20 @@ func (s *server) AddOrder(ctx context.Context, orderReq *pb.Order) (*wrappers.St
 func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) error {
 	batchMarker := 1
 	var combinedShipmentMap = make(map[string]pb.CombinedShipment)
+	orderIds := recvOrderIds(stream)
 	for {
-		orderId, err := stream.Recv()
+		var r graceful.Received
+		var ok bool
+		select {
+		case r, ok = <-orderIds:
+			if !ok { // 流的上下文已取消
+				return status.FromContextError(stream.Context().Err()).Err()
+			}
+		case <-shuttingDown:
+			return flushShipments(stream, combinedShipmentMap, orderIds, lookupOrder) // 服务器退出前发送已缓存的批次
+		}
+		orderId, _ := r.Msg.(*wrappers.StringValue)
+		err := r.Err
 		log.Printf("Reading Proc order ; %s", orderId)
 		if err == io.EOF {
 			log.Printf("EOF : %s", orderId)
@@ -144,6 +161,10 @@ func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) erro
 		if err == io.EOF {          // 检查流是否已经结束
 			return stream.SendAndClose(&wrappers.StringValue{Value: "Orders processed " + ordersStr}) // 服务端发送响应
 		}
+		if err != nil { // 服务器强制停止、流被重置或取消时 Recv 返回其他错误
+			log.Println(err)
+			return err
+		}
 		orderMap[order.Id] = *order
 
 		log.Println("Order ID ", order.Id, ": Updated")
@@ -152,6 +173,7 @@ func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) erro
 }
 
 func main() {
+	flag.Parse()
 	initSampleData()
 	lis, err := net.Listen("tcp", port)
 	if err != nil {
@@ -160,11 +182,11 @@ func main() {
 	s := grpc.NewServer()
 
 	// 注册订单管理服务
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(s, healthServer)
 	pb.RegisterOrderManagementServer(s, &server{})
 	hellopb.RegisterGreeterServer(s, &helloServer{})
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
-	}
+	os.Exit(serve(s, lis, healthServer))
 }
 
 func initSampleData() {
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"graceful"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"registry"
+	pb "registry/registrypb"
+	"time"
+)
+
+var (
+	addr           = flag.String("addr", ":50050", "listen address of the registry")
+	drainTimeout   = flag.Duration("drain-timeout", 10*time.Second, "how long to wait for in-flight RPCs on SIGINT/SIGTERM before forcing the server to stop")
+	serviceConfigs = flag.String("service-configs", "", `JSON file mapping service names to service configs, for example {"ordermgt": {"methodConfig": [...]}}`)
+)
+
+// loadServiceConfigs 读取各服务的服务配置
+func loadServiceConfigs(s *registry.Server, path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var configs map[string]json.RawMessage
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	for service, config := range configs {
+		if err := s.SetServiceConfig(service, string(config)); err != nil {
+			return err
+		}
+		log.Printf("service config for %s loaded", service)
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	registryServer := registry.NewServer()
+	if *serviceConfigs != "" {
+		if err := loadServiceConfigs(registryServer, *serviceConfigs); err != nil {
+			log.Fatalf("failed to load service configs: %v", err)
+		}
+	}
+	s := grpc.NewServer()
+	pb.RegisterRegistryServer(s, registryServer)
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(s, healthServer)
+	log.Printf("Starting registry on %s", *addr)
+	os.Exit(graceful.Serve(s, lis, healthServer, *drainTimeout))
+}
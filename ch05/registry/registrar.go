@@ -0,0 +1,91 @@
+package registry
+
+import (
+	"context"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	pb "registry/registrypb"
+	"sync"
+	"time"
+)
+
+// Registrar 将服务实例注册到注册中心，并在后台按 TTL 的三分之一发送心跳，
+// 租约过期（例如注册中心重启）时会自动重新注册
+type Registrar struct {
+	client   pb.RegistryClient
+	instance *pb.Instance
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	leaseId string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Register 注册实例并开始发送心跳，服务退出前应调用 Close 注销。ttl 小于 MinTTL 时使用 MinTTL，与注册中心一致
+func Register(ctx context.Context, cc *grpc.ClientConn, instance *pb.Instance, ttl time.Duration) (*Registrar, error) {
+	if ttl < MinTTL {
+		ttl = MinTTL
+	}
+	r := &Registrar{client: pb.NewRegistryClient(cc), instance: instance, ttl: ttl, done: make(chan struct{})}
+	if err := r.register(ctx); err != nil {
+		return nil, err
+	}
+	heartbeatCtx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	go r.heartbeat(heartbeatCtx)
+	return r, nil
+}
+
+func (r *Registrar) register(ctx context.Context) error {
+	lease, err := r.client.Register(ctx, &pb.RegisterRequest{Instance: r.instance, Ttl: ptypes.DurationProto(r.ttl)})
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.leaseId = lease.GetId()
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Registrar) heartbeat(ctx context.Context) {
+	defer close(r.done)
+	ticker := time.NewTicker(r.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		r.mu.Lock()
+		leaseId := r.leaseId
+		r.mu.Unlock()
+
+		callCtx, cancel := context.WithTimeout(ctx, r.ttl/3)
+		_, err := r.client.Heartbeat(callCtx, &pb.LeaseID{Value: leaseId})
+		if status.Code(err) == codes.NotFound {
+			log.Printf("registrar: lease %s expired, registering again", leaseId)
+			err = r.register(callCtx)
+		}
+		cancel()
+		if err != nil && ctx.Err() == nil {
+			log.Printf("registrar: %s %s heartbeat failed: %v", r.instance.GetService(), r.instance.GetAddr(), err)
+		}
+	}
+}
+
+// Close 停止心跳并注销实例
+func (r *Registrar) Close(ctx context.Context) error {
+	r.cancel()
+	<-r.done
+	r.mu.Lock()
+	leaseId := r.leaseId
+	r.mu.Unlock()
+	_, err := r.client.Deregister(ctx, &pb.LeaseID{Value: leaseId})
+	return err
+}
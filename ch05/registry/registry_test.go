@@ -0,0 +1,145 @@
+package registry
+
+import (
+	"context"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+	pb "registry/registrypb"
+	"testing"
+	"time"
+)
+
+func startRegistry(t *testing.T) (*Embedded, *grpc.ClientConn) {
+	t.Helper()
+	e, err := Start("localhost:0")
+	if err != nil {
+		t.Fatalf("failed to start registry: %v", err)
+	}
+	t.Cleanup(e.Stop)
+	cc, err := grpc.Dial(e.Addr(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return e, cc
+}
+
+// ttl 为 0 时按 MinTTL 发送心跳，实例在超过 MinTTL 后仍然存在，Close 后被移除
+func TestRegistrarZeroTTL(t *testing.T) {
+	e, cc := startRegistry(t)
+	r, err := Register(context.Background(), cc, &pb.Instance{Service: "orders", Addr: "localhost:50051"}, 0)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	time.Sleep(MinTTL + MinTTL/2)
+	if n := len(e.Instances("orders")); n != 1 {
+		t.Fatalf("got %d instances after %v, want 1", n, MinTTL+MinTTL/2)
+	}
+	if err := r.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if n := len(e.Instances("orders")); n != 0 {
+		t.Fatalf("got %d instances after Close, want 0", n)
+	}
+}
+
+// 没有心跳的实例在 TTL 之后过期
+func TestLeaseExpires(t *testing.T) {
+	e, _ := startRegistry(t)
+	req := &pb.RegisterRequest{Instance: &pb.Instance{Service: "orders", Addr: "localhost:50051"}, Ttl: ptypes.DurationProto(MinTTL)}
+	if _, err := e.Register(context.Background(), req); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	deadline := time.Now().Add(MinTTL + time.Second)
+	for len(e.Instances("orders")) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("instance did not expire")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// fakeClientConn 记录解析器推送的状态，服务配置原样保存在 Config 中
+type fakeClientConn struct {
+	resolver.ClientConn
+	states chan resolver.State
+}
+
+type rawConfig struct {
+	serviceconfig.Config
+	js string
+}
+
+func (cc *fakeClientConn) UpdateState(s resolver.State) error {
+	cc.states <- s
+	return nil
+}
+
+func (cc *fakeClientConn) ReportError(error) {}
+
+func (cc *fakeClientConn) ParseServiceConfig(js string) *serviceconfig.ParseResult {
+	return &serviceconfig.ParseResult{Config: rawConfig{js: js}}
+}
+
+// waitState 等待解析器推送满足 ok 的状态
+func waitState(t *testing.T, cc *fakeClientConn, ok func(resolver.State) bool) resolver.State {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case s := <-cc.states:
+			if ok(s) {
+				return s
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for resolver state")
+		}
+	}
+}
+
+func TestResolverWatch(t *testing.T) {
+	e, conn := startRegistry(t)
+	b := NewResolverBuilder(conn)
+	cc := &fakeClientConn{states: make(chan resolver.State, 16)}
+	r, err := b.Build(resolver.Target{Endpoint: "orders"}, cc, resolver.BuildOptions{})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	defer r.Close()
+	waitState(t, cc, func(s resolver.State) bool { return len(s.Addresses) == 0 })
+
+	const config = `{"loadBalancingConfig": [{"round_robin": {}}]}`
+	if err := e.SetServiceConfig("orders", config); err != nil {
+		t.Fatalf("SetServiceConfig: %v", err)
+	}
+	lease, err := e.Register(context.Background(), &pb.RegisterRequest{Instance: &pb.Instance{
+		Service: "orders", Addr: "localhost:50051", Metadata: map[string]string{"zone": "rack-a"},
+	}})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	s := waitState(t, cc, func(s resolver.State) bool { return len(s.Addresses) == 1 })
+	if got := InstanceMetadata(s.Addresses[0])["zone"]; got != "rack-a" {
+		t.Errorf("zone metadata = %q, want rack-a", got)
+	}
+	if s.ServiceConfig == nil || s.ServiceConfig.Config.(rawConfig).js != config {
+		t.Errorf("service config = %+v, want %s", s.ServiceConfig, config)
+	}
+	if got := b.ServiceConfig("orders"); got != config {
+		t.Errorf("ServiceConfig(orders) = %q, want %q", got, config)
+	}
+
+	if _, err := e.Deregister(context.Background(), &pb.LeaseID{Value: lease.GetId()}); err != nil {
+		t.Fatalf("Deregister: %v", err)
+	}
+	waitState(t, cc, func(s resolver.State) bool { return len(s.Addresses) == 0 })
+}
+
+func TestSetServiceConfigInvalid(t *testing.T) {
+	s := NewServer()
+	if err := s.SetServiceConfig("orders", "{"); err == nil {
+		t.Fatal("SetServiceConfig accepted invalid JSON")
+	}
+}
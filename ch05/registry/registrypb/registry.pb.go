@@ -0,0 +1,541 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: registry.proto
+
+package registrypb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	duration "github.com/golang/protobuf/ptypes/duration"
+	empty "github.com/golang/protobuf/ptypes/empty"
+	grpc "google.golang.org/grpc"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type Instance struct {
+	Service              string            `protobuf:"bytes,1,opt,name=service,proto3" json:"service,omitempty"`
+	Addr                 string            `protobuf:"bytes,2,opt,name=addr,proto3" json:"addr,omitempty"`
+	Metadata             map[string]string `protobuf:"bytes,3,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *Instance) Reset()         { *m = Instance{} }
+func (m *Instance) String() string { return proto.CompactTextString(m) }
+func (*Instance) ProtoMessage()    {}
+func (*Instance) Descriptor() ([]byte, []int) {
+	return fileDescriptor_41af05d40a615591, []int{0}
+}
+
+func (m *Instance) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Instance.Unmarshal(m, b)
+}
+func (m *Instance) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Instance.Marshal(b, m, deterministic)
+}
+func (m *Instance) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Instance.Merge(m, src)
+}
+func (m *Instance) XXX_Size() int {
+	return xxx_messageInfo_Instance.Size(m)
+}
+func (m *Instance) XXX_DiscardUnknown() {
+	xxx_messageInfo_Instance.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Instance proto.InternalMessageInfo
+
+func (m *Instance) GetService() string {
+	if m != nil {
+		return m.Service
+	}
+	return ""
+}
+
+func (m *Instance) GetAddr() string {
+	if m != nil {
+		return m.Addr
+	}
+	return ""
+}
+
+func (m *Instance) GetMetadata() map[string]string {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+type RegisterRequest struct {
+	Instance             *Instance          `protobuf:"bytes,1,opt,name=instance,proto3" json:"instance,omitempty"`
+	Ttl                  *duration.Duration `protobuf:"bytes,2,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *RegisterRequest) Reset()         { *m = RegisterRequest{} }
+func (m *RegisterRequest) String() string { return proto.CompactTextString(m) }
+func (*RegisterRequest) ProtoMessage()    {}
+func (*RegisterRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_41af05d40a615591, []int{1}
+}
+
+func (m *RegisterRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RegisterRequest.Unmarshal(m, b)
+}
+func (m *RegisterRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RegisterRequest.Marshal(b, m, deterministic)
+}
+func (m *RegisterRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RegisterRequest.Merge(m, src)
+}
+func (m *RegisterRequest) XXX_Size() int {
+	return xxx_messageInfo_RegisterRequest.Size(m)
+}
+func (m *RegisterRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RegisterRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RegisterRequest proto.InternalMessageInfo
+
+func (m *RegisterRequest) GetInstance() *Instance {
+	if m != nil {
+		return m.Instance
+	}
+	return nil
+}
+
+func (m *RegisterRequest) GetTtl() *duration.Duration {
+	if m != nil {
+		return m.Ttl
+	}
+	return nil
+}
+
+type LeaseID struct {
+	Value                string   `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LeaseID) Reset()         { *m = LeaseID{} }
+func (m *LeaseID) String() string { return proto.CompactTextString(m) }
+func (*LeaseID) ProtoMessage()    {}
+func (*LeaseID) Descriptor() ([]byte, []int) {
+	return fileDescriptor_41af05d40a615591, []int{2}
+}
+
+func (m *LeaseID) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LeaseID.Unmarshal(m, b)
+}
+func (m *LeaseID) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LeaseID.Marshal(b, m, deterministic)
+}
+func (m *LeaseID) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LeaseID.Merge(m, src)
+}
+func (m *LeaseID) XXX_Size() int {
+	return xxx_messageInfo_LeaseID.Size(m)
+}
+func (m *LeaseID) XXX_DiscardUnknown() {
+	xxx_messageInfo_LeaseID.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LeaseID proto.InternalMessageInfo
+
+func (m *LeaseID) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+type Lease struct {
+	Id                   string             `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Ttl                  *duration.Duration `protobuf:"bytes,2,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *Lease) Reset()         { *m = Lease{} }
+func (m *Lease) String() string { return proto.CompactTextString(m) }
+func (*Lease) ProtoMessage()    {}
+func (*Lease) Descriptor() ([]byte, []int) {
+	return fileDescriptor_41af05d40a615591, []int{3}
+}
+
+func (m *Lease) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Lease.Unmarshal(m, b)
+}
+func (m *Lease) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Lease.Marshal(b, m, deterministic)
+}
+func (m *Lease) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Lease.Merge(m, src)
+}
+func (m *Lease) XXX_Size() int {
+	return xxx_messageInfo_Lease.Size(m)
+}
+func (m *Lease) XXX_DiscardUnknown() {
+	xxx_messageInfo_Lease.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Lease proto.InternalMessageInfo
+
+func (m *Lease) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Lease) GetTtl() *duration.Duration {
+	if m != nil {
+		return m.Ttl
+	}
+	return nil
+}
+
+type WatchRequest struct {
+	Service              string   `protobuf:"bytes,1,opt,name=service,proto3" json:"service,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WatchRequest) Reset()         { *m = WatchRequest{} }
+func (m *WatchRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchRequest) ProtoMessage()    {}
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_41af05d40a615591, []int{4}
+}
+
+func (m *WatchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchRequest.Unmarshal(m, b)
+}
+func (m *WatchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchRequest.Marshal(b, m, deterministic)
+}
+func (m *WatchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchRequest.Merge(m, src)
+}
+func (m *WatchRequest) XXX_Size() int {
+	return xxx_messageInfo_WatchRequest.Size(m)
+}
+func (m *WatchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchRequest proto.InternalMessageInfo
+
+func (m *WatchRequest) GetService() string {
+	if m != nil {
+		return m.Service
+	}
+	return ""
+}
+
+type Instances struct {
+	Instances            []*Instance `protobuf:"bytes,1,rep,name=instances,proto3" json:"instances,omitempty"`
+	ServiceConfig        string      `protobuf:"bytes,2,opt,name=service_config,json=serviceConfig,proto3" json:"service_config,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
+}
+
+func (m *Instances) Reset()         { *m = Instances{} }
+func (m *Instances) String() string { return proto.CompactTextString(m) }
+func (*Instances) ProtoMessage()    {}
+func (*Instances) Descriptor() ([]byte, []int) {
+	return fileDescriptor_41af05d40a615591, []int{5}
+}
+
+func (m *Instances) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Instances.Unmarshal(m, b)
+}
+func (m *Instances) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Instances.Marshal(b, m, deterministic)
+}
+func (m *Instances) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Instances.Merge(m, src)
+}
+func (m *Instances) XXX_Size() int {
+	return xxx_messageInfo_Instances.Size(m)
+}
+func (m *Instances) XXX_DiscardUnknown() {
+	xxx_messageInfo_Instances.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Instances proto.InternalMessageInfo
+
+func (m *Instances) GetInstances() []*Instance {
+	if m != nil {
+		return m.Instances
+	}
+	return nil
+}
+
+func (m *Instances) GetServiceConfig() string {
+	if m != nil {
+		return m.ServiceConfig
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*Instance)(nil), "registry.Instance")
+	proto.RegisterMapType((map[string]string)(nil), "registry.Instance.MetadataEntry")
+	proto.RegisterType((*RegisterRequest)(nil), "registry.RegisterRequest")
+	proto.RegisterType((*LeaseID)(nil), "registry.LeaseID")
+	proto.RegisterType((*Lease)(nil), "registry.Lease")
+	proto.RegisterType((*WatchRequest)(nil), "registry.WatchRequest")
+	proto.RegisterType((*Instances)(nil), "registry.Instances")
+}
+
+func init() { proto.RegisterFile("registry.proto", fileDescriptor_41af05d40a615591) }
+
+var fileDescriptor_41af05d40a615591 = []byte{
+	// 421 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x52, 0xc1, 0x6e, 0xd3, 0x40,
+	0x10, 0xd5, 0xc6, 0x84, 0x3a, 0x93, 0x36, 0x85, 0x01, 0x55, 0xae, 0x91, 0x20, 0xb2, 0x84, 0x14,
+	0x09, 0xc9, 0xa9, 0x82, 0x54, 0x10, 0x70, 0x82, 0xf4, 0x10, 0x09, 0x2e, 0xbe, 0x20, 0x71, 0x41,
+	0x1b, 0xef, 0xd4, 0xb5, 0x48, 0xed, 0xb0, 0xbb, 0x2e, 0xca, 0x77, 0xf1, 0x4b, 0x7c, 0x08, 0xf2,
+	0x7a, 0xd7, 0x2e, 0x31, 0x3d, 0xf4, 0xb6, 0x3b, 0xef, 0xcd, 0x7b, 0x33, 0x6f, 0x17, 0x26, 0x92,
+	0xb2, 0x5c, 0x69, 0xb9, 0x8b, 0xb7, 0xb2, 0xd4, 0x25, 0xfa, 0xee, 0x1e, 0x3e, 0xcf, 0xca, 0x32,
+	0xdb, 0xd0, 0xdc, 0xd4, 0xd7, 0xd5, 0xe5, 0x5c, 0x54, 0x92, 0xeb, 0xbc, 0x2c, 0x1a, 0x66, 0xf8,
+	0x6c, 0x1f, 0xa7, 0xeb, 0xad, 0xb6, 0x32, 0xd1, 0x6f, 0x06, 0xfe, 0xaa, 0x50, 0x9a, 0x17, 0x29,
+	0x61, 0x00, 0x07, 0x8a, 0xe4, 0x4d, 0x9e, 0x52, 0xc0, 0xa6, 0x6c, 0x36, 0x4a, 0xdc, 0x15, 0x11,
+	0x1e, 0x70, 0x21, 0x64, 0x30, 0x30, 0x65, 0x73, 0xc6, 0x0f, 0xe0, 0x5f, 0x93, 0xe6, 0x82, 0x6b,
+	0x1e, 0x78, 0x53, 0x6f, 0x36, 0x5e, 0x4c, 0xe3, 0x76, 0x48, 0xa7, 0x19, 0x7f, 0xb1, 0x94, 0x8b,
+	0x42, 0xcb, 0x5d, 0xd2, 0x76, 0x84, 0xef, 0xe1, 0xe8, 0x1f, 0x08, 0x1f, 0x81, 0xf7, 0x83, 0x76,
+	0xd6, 0xb8, 0x3e, 0xe2, 0x53, 0x18, 0xde, 0xf0, 0x4d, 0x45, 0xd6, 0xb5, 0xb9, 0xbc, 0x1b, 0xbc,
+	0x65, 0x51, 0x01, 0xc7, 0x89, 0x71, 0x22, 0x99, 0xd0, 0xcf, 0x8a, 0x94, 0xc6, 0x18, 0xfc, 0xdc,
+	0x7a, 0x1a, 0x8d, 0xf1, 0x02, 0xfb, 0xd3, 0x24, 0x2d, 0x07, 0x5f, 0x81, 0xa7, 0xf5, 0xc6, 0x48,
+	0x8f, 0x17, 0xa7, 0x71, 0x93, 0x51, 0xec, 0x32, 0x8a, 0x97, 0x36, 0xc3, 0xa4, 0x66, 0x45, 0x2f,
+	0xe0, 0xe0, 0x33, 0x71, 0x45, 0xab, 0x65, 0x37, 0x14, 0xbb, 0x35, 0x54, 0xb4, 0x84, 0xa1, 0x21,
+	0xe0, 0x04, 0x06, 0xb9, 0xb0, 0xd8, 0x20, 0x17, 0xf7, 0xb3, 0x99, 0xc1, 0xe1, 0x57, 0xae, 0xd3,
+	0x2b, 0xb7, 0xd3, 0x9d, 0xef, 0x11, 0x09, 0x18, 0xb9, 0x9d, 0x14, 0x9e, 0xc1, 0xc8, 0xad, 0xa5,
+	0x02, 0x36, 0xf5, 0xee, 0xd8, 0xbd, 0x23, 0xe1, 0x4b, 0x98, 0x58, 0xa5, 0xef, 0x69, 0x59, 0x5c,
+	0xe6, 0x99, 0x8d, 0xf8, 0xc8, 0x56, 0x3f, 0x99, 0xe2, 0xe2, 0x0f, 0x03, 0x3f, 0xb1, 0x3a, 0x78,
+	0x0e, 0xf6, 0xcb, 0x91, 0xc4, 0xd3, 0x4e, 0x7e, 0xef, 0x1d, 0xc2, 0xe3, 0x0e, 0x6a, 0x12, 0x99,
+	0xc3, 0xe8, 0x8a, 0xb8, 0xd4, 0x6b, 0xe2, 0x1a, 0x1f, 0xef, 0xa1, 0xab, 0x65, 0xbf, 0xe1, 0x0d,
+	0x80, 0xa0, 0xd6, 0xea, 0x3f, 0x1d, 0x27, 0xbd, 0x18, 0x2f, 0xea, 0x1f, 0x8d, 0xe7, 0x30, 0xfc,
+	0x55, 0xc7, 0x87, 0x27, 0x5d, 0xcf, 0xed, 0x3c, 0xc3, 0x27, 0xfd, 0x54, 0xd4, 0x19, 0xfb, 0x78,
+	0xf8, 0x0d, 0x5c, 0x7d, 0xbb, 0x5e, 0x3f, 0x34, 0xaa, 0xaf, 0xff, 0x0e, 0x00, 0xb1, 0x97, 0xd4,
+	0x86, 0x71, 0x03, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// RegistryClient is the client API for Registry service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type RegistryClient interface {
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*Lease, error)
+	Heartbeat(ctx context.Context, in *LeaseID, opts ...grpc.CallOption) (*Lease, error)
+	Deregister(ctx context.Context, in *LeaseID, opts ...grpc.CallOption) (*empty.Empty, error)
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Registry_WatchClient, error)
+}
+
+type registryClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewRegistryClient(cc *grpc.ClientConn) RegistryClient {
+	return &registryClient{cc}
+}
+
+func (c *registryClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*Lease, error) {
+	out := new(Lease)
+	err := c.cc.Invoke(ctx, "/registry.Registry/register", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryClient) Heartbeat(ctx context.Context, in *LeaseID, opts ...grpc.CallOption) (*Lease, error) {
+	out := new(Lease)
+	err := c.cc.Invoke(ctx, "/registry.Registry/heartbeat", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryClient) Deregister(ctx context.Context, in *LeaseID, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/registry.Registry/deregister", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Registry_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Registry_serviceDesc.Streams[0], "/registry.Registry/watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &registryWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Registry_WatchClient interface {
+	Recv() (*Instances, error)
+	grpc.ClientStream
+}
+
+type registryWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *registryWatchClient) Recv() (*Instances, error) {
+	m := new(Instances)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RegistryServer is the server API for Registry service.
+type RegistryServer interface {
+	Register(context.Context, *RegisterRequest) (*Lease, error)
+	Heartbeat(context.Context, *LeaseID) (*Lease, error)
+	Deregister(context.Context, *LeaseID) (*empty.Empty, error)
+	Watch(*WatchRequest, Registry_WatchServer) error
+}
+
+func RegisterRegistryServer(s *grpc.Server, srv RegistryServer) {
+	s.RegisterService(&_Registry_serviceDesc, srv)
+}
+
+func _Registry_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/registry.Registry/Register",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Registry_Heartbeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeaseID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServer).Heartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/registry.Registry/Heartbeat",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServer).Heartbeat(ctx, req.(*LeaseID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Registry_Deregister_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeaseID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServer).Deregister(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/registry.Registry/Deregister",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServer).Deregister(ctx, req.(*LeaseID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Registry_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RegistryServer).Watch(m, &registryWatchServer{stream})
+}
+
+type Registry_WatchServer interface {
+	Send(*Instances) error
+	grpc.ServerStream
+}
+
+type registryWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *registryWatchServer) Send(m *Instances) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Registry_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "registry.Registry",
+	HandlerType: (*RegistryServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "register",
+			Handler:    _Registry_Register_Handler,
+		},
+		{
+			MethodName: "heartbeat",
+			Handler:    _Registry_Heartbeat_Handler,
+		},
+		{
+			MethodName: "deregister",
+			Handler:    _Registry_Deregister_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "watch",
+			Handler:       _Registry_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "registry.proto",
+}
@@ -0,0 +1,160 @@
+package registry
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/resolver"
+	"log"
+	pb "registry/registrypb"
+	"sync"
+	"time"
+)
+
+// Scheme 是注册中心解析器的 scheme，地址形如 registry:///orders
+const Scheme = "registry"
+
+const (
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 5 * time.Second
+)
+
+type metadataKey struct{}
+
+// InstanceMetadata 返回解析出的地址所携带的实例元数据（例如 weight、zone）
+func InstanceMetadata(addr resolver.Address) map[string]string {
+	md, _ := addr.Attributes.Value(metadataKey{}).(map[string]string)
+	return md
+}
+
+// ResolverBuilder 通过到注册中心的连接 cc 创建 watch 解析器
+type ResolverBuilder struct {
+	client pb.RegistryClient
+
+	mu      sync.Mutex
+	configs map[string]string // 服务名 -> 最近一次收到的服务配置 JSON
+}
+
+// NewResolverBuilder 使用已建立的注册中心连接创建解析器，
+// 通过 grpc.WithResolvers 或 resolver.Register 注册后使用
+func NewResolverBuilder(cc *grpc.ClientConn) *ResolverBuilder {
+	return &ResolverBuilder{client: pb.NewRegistryClient(cc), configs: make(map[string]string)}
+}
+
+// ServiceConfig 返回注册中心为 service 下发的服务配置 JSON。grpc 不支持的策略（例如 hedgingPolicy）
+// 可以由客户端拦截器从这里读取
+func (b *ResolverBuilder) ServiceConfig(service string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.configs[service]
+}
+
+func (b *ResolverBuilder) setServiceConfig(service, config string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.configs[service] = config
+}
+
+func (b *ResolverBuilder) Scheme() string { return Scheme }
+
+func (b *ResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &watchResolver{
+		builder: b,
+		client:  b.client,
+		service: target.Endpoint,
+		cc:      cc,
+		cancel:  cancel,
+		retry:   make(chan struct{}, 1),
+	}
+	r.wg.Add(1)
+	go r.watch(ctx)
+	return r, nil
+}
+
+// watchResolver 保持一个到注册中心的 watch 流，流断开时按指数退避重连
+type watchResolver struct {
+	builder *ResolverBuilder
+	client  pb.RegistryClient
+	service string
+	cc      resolver.ClientConn
+	cancel  context.CancelFunc
+	retry   chan struct{}
+	wg      sync.WaitGroup
+}
+
+func (r *watchResolver) watch(ctx context.Context) {
+	defer r.wg.Done()
+	backoff := minBackoff
+	for {
+		received, err := r.watchOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if received {
+			backoff = minBackoff
+		}
+		log.Printf("registry resolver: watch %s: %v, retrying in %v", r.service, err, backoff)
+		r.cc.ReportError(err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-r.retry:
+			timer.Stop()
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// watchOnce 打开一次 watch 流并持续推送实例列表，返回是否收到过数据以及流结束的原因
+func (r *watchResolver) watchOnce(ctx context.Context) (bool, error) {
+	stream, err := r.client.Watch(ctx, &pb.WatchRequest{Service: r.service})
+	if err != nil {
+		return false, err
+	}
+	received := false
+	for {
+		instances, err := stream.Recv()
+		if err != nil {
+			return received, err
+		}
+		received = true
+		addrs := make([]resolver.Address, 0, len(instances.GetInstances()))
+		for _, inst := range instances.GetInstances() {
+			addrs = append(addrs, resolver.Address{
+				Addr:       inst.GetAddr(),
+				Attributes: attributes.New(metadataKey{}, inst.GetMetadata()),
+			})
+		}
+		state := resolver.State{Addresses: addrs}
+		if config := instances.GetServiceConfig(); config != "" {
+			// 解析失败时 ClientConn 继续使用之前的服务配置
+			state.ServiceConfig = r.cc.ParseServiceConfig(config)
+			if state.ServiceConfig.Err != nil {
+				log.Printf("registry resolver: %s: invalid service config: %v", r.service, state.ServiceConfig.Err)
+			}
+		}
+		r.builder.setServiceConfig(r.service, instances.GetServiceConfig())
+		// 没有实例时 UpdateState 返回 ErrBadResolverState，RPC 会等待实例出现
+		r.cc.UpdateState(state)
+	}
+}
+
+// ResolveNow 在等待重连时立即重试，watch 流正常时不需要额外操作
+func (r *watchResolver) ResolveNow(o resolver.ResolveNowOptions) {
+	select {
+	case r.retry <- struct{}{}:
+	default:
+	}
+}
+
+func (r *watchResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
@@ -0,0 +1,259 @@
+// Package registry 是一个可嵌入的服务注册中心。
+//
+// 服务实例通过 Registrar 注册并定期发送心跳，超过 TTL 没有心跳的实例会被移除；
+// 客户端使用 registry:///<service> 形式的地址，解析器通过 watch 流实时获得实例变化。
+// 注册中心既可以独立运行（见 cmd/registry），也可以通过 Start 在测试进程中启动。
+package registry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"net"
+	pb "registry/registrypb"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultTTL = 10 * time.Second
+	MinTTL     = time.Second
+)
+
+// lease 是一个已注册实例及其过期定时器
+type lease struct {
+	id       string
+	instance *pb.Instance
+	ttl      time.Duration
+	expires  time.Time
+	timer    *time.Timer
+}
+
+// Server 实现 Registry 服务
+type Server struct {
+	mu       sync.Mutex
+	leases   map[string]*lease                 // 租约 ID -> 租约
+	watchers map[string]map[chan struct{}]bool // 服务名 -> watch 流的通知信道
+	configs  map[string]string                 // 服务名 -> 服务配置 JSON
+}
+
+func NewServer() *Server {
+	return &Server{
+		leases:   make(map[string]*lease),
+		watchers: make(map[string]map[chan struct{}]bool),
+		configs:  make(map[string]string),
+	}
+}
+
+// SetServiceConfig 设置服务的服务配置，通过 watch 流下发给客户端，config 为空时删除
+func (s *Server) SetServiceConfig(service, config string) error {
+	if config != "" && !json.Valid([]byte(config)) {
+		return fmt.Errorf("registry: invalid service config for %s", service)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if config == "" {
+		delete(s.configs, service)
+	} else {
+		s.configs[service] = config
+	}
+	s.notifyLocked(service)
+	return nil
+}
+
+func (s *Server) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.Lease, error) {
+	inst := req.GetInstance()
+	if inst.GetService() == "" || inst.GetAddr() == "" {
+		return nil, status.Error(codes.InvalidArgument, "instance service and addr are required")
+	}
+	ttl := DefaultTTL
+	if req.GetTtl() != nil {
+		d, err := ptypes.Duration(req.GetTtl())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid ttl: %v", err)
+		}
+		ttl = d
+	}
+	if ttl < MinTTL {
+		ttl = MinTTL
+	}
+
+	l := &lease{id: newLeaseId(), instance: proto.Clone(inst).(*pb.Instance), ttl: ttl, expires: time.Now().Add(ttl)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 同一地址重复注册时替换旧的租约
+	for id, old := range s.leases {
+		if old.instance.GetService() == inst.GetService() && old.instance.GetAddr() == inst.GetAddr() {
+			old.timer.Stop()
+			delete(s.leases, id)
+		}
+	}
+	l.timer = time.AfterFunc(ttl, func() { s.expire(l.id) })
+	s.leases[l.id] = l
+	s.notifyLocked(inst.GetService())
+	log.Printf("registry: %s %s registered, lease %s ttl %v", inst.GetService(), inst.GetAddr(), l.id, ttl)
+	return &pb.Lease{Id: l.id, Ttl: ptypes.DurationProto(ttl)}, nil
+}
+
+func (s *Server) Heartbeat(ctx context.Context, req *pb.LeaseID) (*pb.Lease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, found := s.leases[req.GetValue()]
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "lease %s not found", req.GetValue())
+	}
+	l.expires = time.Now().Add(l.ttl)
+	l.timer.Reset(l.ttl)
+	return &pb.Lease{Id: l.id, Ttl: ptypes.DurationProto(l.ttl)}, nil
+}
+
+func (s *Server) Deregister(ctx context.Context, req *pb.LeaseID) (*empty.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, found := s.leases[req.GetValue()]; found {
+		l.timer.Stop()
+		delete(s.leases, l.id)
+		s.notifyLocked(l.instance.GetService())
+		log.Printf("registry: %s %s deregistered", l.instance.GetService(), l.instance.GetAddr())
+	}
+	return &empty.Empty{}, nil
+}
+
+// expire 在租约到期时移除实例
+func (s *Server) expire(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, found := s.leases[id]
+	// 定时器触发后、获得锁之前可能刚好收到心跳
+	if !found || time.Now().Before(l.expires) {
+		return
+	}
+	delete(s.leases, id)
+	s.notifyLocked(l.instance.GetService())
+	log.Printf("registry: %s %s expired", l.instance.GetService(), l.instance.GetAddr())
+}
+
+// Watch 先发送当前的实例列表，之后每次变化都发送完整列表，直到客户端取消
+func (s *Server) Watch(req *pb.WatchRequest, stream pb.Registry_WatchServer) error {
+	service := req.GetService()
+	notify := make(chan struct{}, 1) // 容量为 1，连续的变化会合并为一次发送
+	notify <- struct{}{}
+
+	s.mu.Lock()
+	if s.watchers[service] == nil {
+		s.watchers[service] = make(map[chan struct{}]bool)
+	}
+	s.watchers[service][notify] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.watchers[service], notify)
+		if len(s.watchers[service]) == 0 {
+			delete(s.watchers, service)
+		}
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		case <-notify:
+			// 实例列表和服务配置在同一次加锁中读取，客户端不会收到两者不一致的组合
+			s.mu.Lock()
+			instances := &pb.Instances{Instances: s.instancesLocked(service), ServiceConfig: s.configs[service]}
+			s.mu.Unlock()
+			if err := stream.Send(instances); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Instances 返回服务当前的实例，按地址排序
+func (s *Server) Instances(service string) []*pb.Instance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.instancesLocked(service)
+}
+
+func (s *Server) instancesLocked(service string) []*pb.Instance {
+	var instances []*pb.Instance
+	for _, l := range s.leases {
+		if l.instance.GetService() == service {
+			instances = append(instances, proto.Clone(l.instance).(*pb.Instance))
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].GetAddr() < instances[j].GetAddr() })
+	return instances
+}
+
+func (s *Server) notifyLocked(service string) {
+	for notify := range s.watchers[service] {
+		select {
+		case notify <- struct{}{}:
+		default: // 已有未处理的通知
+		}
+	}
+}
+
+// Stop 停止所有租约定时器
+func (s *Server) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, l := range s.leases {
+		l.timer.Stop()
+	}
+}
+
+func newLeaseId() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// Embedded 是在当前进程中运行的注册中心，主要用于测试和示例
+type Embedded struct {
+	*Server
+	grpcServer *grpc.Server
+	lis        net.Listener
+}
+
+// Start 在 addr 上启动注册中心，addr 为 "localhost:0" 时使用随机端口
+func Start(addr string) (*Embedded, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	e := &Embedded{Server: NewServer(), grpcServer: grpc.NewServer(), lis: lis}
+	pb.RegisterRegistryServer(e.grpcServer, e.Server)
+	go func() {
+		if err := e.grpcServer.Serve(lis); err != nil {
+			log.Printf("registry: serve: %v", err)
+		}
+	}()
+	return e, nil
+}
+
+// Addr 返回注册中心实际监听的地址
+func (e *Embedded) Addr() string {
+	return e.lis.Addr().String()
+}
+
+// Stop 关闭注册中心，watch 流会随之结束
+func (e *Embedded) Stop() {
+	e.grpcServer.Stop()
+	e.Server.Stop()
+}
@@ -1,47 +0,0 @@
-package main
-
-import "google.golang.org/grpc/resolver"
-
-type exampleResolverBuilder struct{}
-
-type exampleResolver struct {
-	target     resolver.Target
-	cc         resolver.ClientConn
-	addrsStore map[string][]string
-}
-
-func (*exampleResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
-	r := &exampleResolver{
-		target: target,
-		cc:     cc,
-		addrsStore: map[string][]string{
-			exampleServiceName: addrs,
-		},
-	}
-	r.start()
-	return r, nil
-}
-
-func (*exampleResolverBuilder) Scheme() string {
-	return exampleScheme
-}
-
-func (r *exampleResolver) start() {
-	addrStrs := r.addrsStore[r.target.Endpoint]
-	addrs := make([]resolver.Address, len(addrStrs))
-	for i, s := range addrStrs {
-		addrs[i] = resolver.Address{Addr: s}
-	}
-	err := r.cc.UpdateState(resolver.State{Addresses: addrs})
-	if err != nil {
-		return 
-	}
-}
-
-func (*exampleResolver) ResolveNow(o resolver.ResolveNowOptions) {}
-func (*exampleResolver) Close() {}
-
-
-func init() {
-	resolver.Register(&exampleResolverBuilder{})
-}
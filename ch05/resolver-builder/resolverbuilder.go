@@ -0,0 +1,211 @@
+// Package resolverbuilder 提供可复用的静态名称解析器。
+//
+// 每个 Builder 对应一个 scheme，可以在运行时注册服务名到地址列表的映射并随时更新，
+// 也可以为每个服务附加 ServiceConfig JSON。更新会立即推送给所有使用该服务的 ClientConn。
+//
+//	b := resolverbuilder.Register("example", map[string][]string{
+//		"lb.example.grpc.io": {"localhost:50051", "localhost:50052"},
+//	})
+//	conn, err := grpc.Dial("example:///lb.example.grpc.io", grpc.WithInsecure())
+//	b.SetAddresses("lb.example.grpc.io", "localhost:50053")
+package resolverbuilder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/resolver"
+	"sync"
+)
+
+// ErrUnknownService 表示解析的服务名尚未注册
+var ErrUnknownService = errors.New("resolverbuilder: unknown service")
+
+// service 是一个服务当前的地址列表与服务配置
+type service struct {
+	addrs         []resolver.Address
+	serviceConfig string
+}
+
+// Builder 实现 resolver.Builder，保存一个 scheme 下所有服务的地址
+type Builder struct {
+	scheme string
+
+	mu        sync.Mutex
+	services  map[string]service
+	resolvers map[*staticResolver]struct{} // 仍在使用中的解析器，用于推送更新
+}
+
+// NewBuilder 创建 scheme 对应的 Builder，需要通过 resolver.Register 或 grpc.WithResolvers 注册后使用
+func NewBuilder(scheme string) *Builder {
+	return &Builder{
+		scheme:    scheme,
+		services:  make(map[string]service),
+		resolvers: make(map[*staticResolver]struct{}),
+	}
+}
+
+// Register 创建 Builder，设置初始的服务地址并注册到全局解析器中，
+// 与 resolver.Register 一样应当在 init 中或创建 ClientConn 之前调用
+func Register(scheme string, services map[string][]string) *Builder {
+	b := NewBuilder(scheme)
+	for name, addrs := range services {
+		b.SetAddresses(name, addrs...)
+	}
+	resolver.Register(b)
+	return b
+}
+
+// Scheme 返回 Builder 负责的 scheme
+func (b *Builder) Scheme() string {
+	return b.scheme
+}
+
+// SetAddresses 设置服务的地址列表
+func (b *Builder) SetAddresses(serviceName string, addrs ...string) {
+	resolved := make([]resolver.Address, len(addrs))
+	for i, addr := range addrs {
+		resolved[i] = resolver.Address{Addr: addr}
+	}
+	b.UpdateAddresses(serviceName, resolved)
+}
+
+// UpdateAddresses 使用带属性的地址设置服务的地址列表
+func (b *Builder) UpdateAddresses(serviceName string, addrs []resolver.Address) {
+	b.mu.Lock()
+	s := b.services[serviceName]
+	s.addrs = append([]resolver.Address(nil), addrs...)
+	b.services[serviceName] = s
+	b.mu.Unlock()
+	b.notify(serviceName)
+}
+
+// SetServiceConfig 设置服务的 ServiceConfig JSON，传入空字符串时清除
+func (b *Builder) SetServiceConfig(serviceName, serviceConfig string) error {
+	if serviceConfig != "" && !json.Valid([]byte(serviceConfig)) {
+		return fmt.Errorf("resolverbuilder: invalid service config for %s", serviceName)
+	}
+	b.mu.Lock()
+	s := b.services[serviceName]
+	s.serviceConfig = serviceConfig
+	b.services[serviceName] = s
+	b.mu.Unlock()
+	b.notify(serviceName)
+	return nil
+}
+
+// RemoveService 删除服务，使用该服务的 ClientConn 会收到 ErrUnknownService，并继续使用上一次解析的地址
+func (b *Builder) RemoveService(serviceName string) {
+	b.mu.Lock()
+	delete(b.services, serviceName)
+	b.mu.Unlock()
+	b.notify(serviceName)
+}
+
+// Services 返回当前注册的服务名
+func (b *Builder) Services() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	names := make([]string, 0, len(b.services))
+	for name := range b.services {
+		names = append(names, name)
+	}
+	return names
+}
+
+// notify 让使用 serviceName 的解析器推送最新状态，调用时不能持有 b.mu
+func (b *Builder) notify(serviceName string) {
+	var resolvers []*staticResolver
+	b.mu.Lock()
+	for r := range b.resolvers {
+		if r.serviceName == serviceName {
+			resolvers = append(resolvers, r)
+		}
+	}
+	b.mu.Unlock()
+	for _, r := range resolvers {
+		r.push()
+	}
+}
+
+// lookup 返回解析器对应服务的当前状态，解析器已关闭时 active 为 false
+func (b *Builder) lookup(r *staticResolver) (s service, found, active bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, active = b.resolvers[r]; !active {
+		return service{}, false, false
+	}
+	s, found = b.services[r.serviceName]
+	return s, found, true
+}
+
+// Build 为 target 创建解析器，target.Endpoint 即服务名
+func (b *Builder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	r := &staticResolver{builder: b, serviceName: target.Endpoint, cc: cc}
+	b.mu.Lock()
+	b.resolvers[r] = struct{}{}
+	b.mu.Unlock()
+	r.push()
+	return r, nil
+}
+
+// staticResolver 将 Builder 中服务的当前状态推送给一个 ClientConn
+type staticResolver struct {
+	builder     *Builder
+	serviceName string
+	cc          resolver.ClientConn
+
+	mu      sync.Mutex
+	pushing bool // 有 goroutine 正在向 cc 推送
+	pending bool // 推送期间状态又发生了变化
+}
+
+// push 把服务的最新状态推送给 cc。调用 cc 时不持有任何锁，cc 可以在 UpdateState 中回调 Builder 或 ResolveNow；
+// 同一时间只有一个 goroutine 推送，推送期间的变化由它在结束前再推送一次，保证 cc 收到的更新有序且最后一次是最新状态
+func (r *staticResolver) push() {
+	r.mu.Lock()
+	if r.pushing {
+		r.pending = true
+		r.mu.Unlock()
+		return
+	}
+	r.pushing = true
+	for {
+		r.pending = false
+		r.mu.Unlock()
+		if s, found, active := r.builder.lookup(r); active {
+			r.send(s, found)
+		}
+		r.mu.Lock()
+		if !r.pending {
+			r.pushing = false
+			r.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (r *staticResolver) send(s service, found bool) {
+	if !found {
+		r.cc.ReportError(fmt.Errorf("%w: %s", ErrUnknownService, r.serviceName))
+		return
+	}
+	state := resolver.State{Addresses: s.addrs}
+	if s.serviceConfig != "" {
+		state.ServiceConfig = r.cc.ParseServiceConfig(s.serviceConfig)
+	}
+	// 地址为空或服务配置不合法时 UpdateState 返回 ErrBadResolverState，ClientConn 会随后调用 ResolveNow
+	r.cc.UpdateState(state)
+}
+
+// ResolveNow 重新推送当前状态
+func (r *staticResolver) ResolveNow(o resolver.ResolveNowOptions) {
+	r.push()
+}
+
+// Close 停止向该 ClientConn 推送更新
+func (r *staticResolver) Close() {
+	r.builder.mu.Lock()
+	defer r.builder.mu.Unlock()
+	delete(r.builder.resolvers, r)
+}
@@ -0,0 +1,240 @@
+package resolverbuilder
+
+import (
+	"errors"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClientConn 记录解析器推送的状态和错误，服务配置原样保存在 Config 中
+type fakeClientConn struct {
+	resolver.ClientConn
+
+	mu     sync.Mutex
+	states []resolver.State
+	errs   []error
+	// onUpdate 在 UpdateState 中调用，用于模拟 ClientConn 回调 Builder
+	onUpdate func()
+}
+
+type rawConfig struct {
+	serviceconfig.Config
+	js string
+}
+
+func (cc *fakeClientConn) UpdateState(s resolver.State) error {
+	cc.mu.Lock()
+	cc.states = append(cc.states, s)
+	onUpdate := cc.onUpdate
+	cc.mu.Unlock()
+	if onUpdate != nil {
+		onUpdate()
+	}
+	return nil
+}
+
+func (cc *fakeClientConn) ReportError(err error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.errs = append(cc.errs, err)
+}
+
+func (cc *fakeClientConn) ParseServiceConfig(js string) *serviceconfig.ParseResult {
+	return &serviceconfig.ParseResult{Config: rawConfig{js: js}}
+}
+
+// last 返回最近一次推送的状态和收到的错误数
+func (cc *fakeClientConn) last(t *testing.T) (resolver.State, int) {
+	t.Helper()
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if len(cc.states) == 0 {
+		t.Fatal("no state pushed")
+	}
+	return cc.states[len(cc.states)-1], len(cc.errs)
+}
+
+func addrsOf(s resolver.State) []string {
+	var addrs []string
+	for _, a := range s.Addresses {
+		addrs = append(addrs, a.Addr)
+	}
+	return addrs
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func build(t *testing.T, b *Builder, serviceName string) (*fakeClientConn, resolver.Resolver) {
+	t.Helper()
+	cc := &fakeClientConn{}
+	r, err := b.Build(resolver.Target{Scheme: b.Scheme(), Endpoint: serviceName}, cc, resolver.BuildOptions{})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	return cc, r
+}
+
+func TestRegister(t *testing.T) {
+	b := Register("test-register", map[string][]string{"echo": {"localhost:50051", "localhost:50052"}})
+	if got := resolver.Get("test-register"); got != b {
+		t.Fatalf("resolver.Get returned %v, want the registered Builder", got)
+	}
+	cc, r := build(t, b, "echo")
+	defer r.Close()
+	s, _ := cc.last(t)
+	if want := []string{"localhost:50051", "localhost:50052"}; !equal(addrsOf(s), want) {
+		t.Errorf("addresses = %v, want %v", addrsOf(s), want)
+	}
+}
+
+func TestSetAddresses(t *testing.T) {
+	b := NewBuilder("test")
+	b.SetAddresses("echo", "localhost:50051")
+	cc, r := build(t, b, "echo")
+	defer r.Close()
+
+	b.SetAddresses("echo", "localhost:50052", "localhost:50053")
+	s, _ := cc.last(t)
+	if want := []string{"localhost:50052", "localhost:50053"}; !equal(addrsOf(s), want) {
+		t.Errorf("addresses = %v, want %v", addrsOf(s), want)
+	}
+
+	// 其他服务的变化不推送给该 ClientConn
+	b.SetAddresses("other", "localhost:60000")
+	cc.mu.Lock()
+	n := len(cc.states)
+	cc.mu.Unlock()
+	if n != 2 {
+		t.Errorf("got %d states, want 2", n)
+	}
+}
+
+func TestSetServiceConfig(t *testing.T) {
+	b := NewBuilder("test")
+	b.SetAddresses("echo", "localhost:50051")
+	cc, r := build(t, b, "echo")
+	defer r.Close()
+
+	if err := b.SetServiceConfig("echo", "{"); err == nil {
+		t.Error("SetServiceConfig accepted invalid JSON")
+	}
+	const config = `{"loadBalancingConfig": [{"round_robin": {}}]}`
+	if err := b.SetServiceConfig("echo", config); err != nil {
+		t.Fatalf("SetServiceConfig: %v", err)
+	}
+	s, _ := cc.last(t)
+	if s.ServiceConfig == nil || s.ServiceConfig.Config.(rawConfig).js != config {
+		t.Errorf("service config = %+v, want %s", s.ServiceConfig, config)
+	}
+	if !equal(addrsOf(s), []string{"localhost:50051"}) {
+		t.Errorf("addresses = %v, want [localhost:50051]", addrsOf(s))
+	}
+
+	if err := b.SetServiceConfig("echo", ""); err != nil {
+		t.Fatalf("SetServiceConfig: %v", err)
+	}
+	if s, _ := cc.last(t); s.ServiceConfig != nil {
+		t.Errorf("service config = %+v after clearing, want nil", s.ServiceConfig)
+	}
+}
+
+func TestRemoveService(t *testing.T) {
+	b := NewBuilder("test")
+	b.SetAddresses("echo", "localhost:50051")
+	cc, r := build(t, b, "echo")
+	defer r.Close()
+
+	b.RemoveService("echo")
+	if len(b.Services()) != 0 {
+		t.Errorf("Services() = %v after RemoveService, want none", b.Services())
+	}
+	cc.mu.Lock()
+	errs := append([]error(nil), cc.errs...)
+	cc.mu.Unlock()
+	if len(errs) != 1 || !errors.Is(errs[0], ErrUnknownService) {
+		t.Errorf("errors = %v, want one ErrUnknownService", errs)
+	}
+}
+
+// 服务注册前解析得到 ErrUnknownService，注册后 ResolveNow 推送地址
+func TestResolveNow(t *testing.T) {
+	b := NewBuilder("test")
+	cc, r := build(t, b, "echo")
+	defer r.Close()
+	cc.mu.Lock()
+	errs, states := len(cc.errs), len(cc.states)
+	cc.mu.Unlock()
+	if errs != 1 || states != 0 {
+		t.Fatalf("got %d errors and %d states for an unknown service, want 1 error", errs, states)
+	}
+
+	b.mu.Lock()
+	b.services["echo"] = service{addrs: []resolver.Address{{Addr: "localhost:50051"}}} // 不经过 notify
+	b.mu.Unlock()
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	if s, _ := cc.last(t); !equal(addrsOf(s), []string{"localhost:50051"}) {
+		t.Errorf("addresses = %v, want [localhost:50051]", addrsOf(s))
+	}
+}
+
+func TestClose(t *testing.T) {
+	b := NewBuilder("test")
+	b.SetAddresses("echo", "localhost:50051")
+	cc, r := build(t, b, "echo")
+	r.Close()
+
+	b.SetAddresses("echo", "localhost:50052")
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	cc.mu.Lock()
+	n := len(cc.states)
+	cc.mu.Unlock()
+	if n != 1 {
+		t.Errorf("got %d states, want 1: no updates after Close", n)
+	}
+}
+
+// ClientConn 在 UpdateState 中回调 Builder 和 ResolveNow 时不会死锁，回调中的变化也会推送
+func TestReentrantUpdate(t *testing.T) {
+	b := NewBuilder("test")
+	b.SetAddresses("echo", "localhost:50051")
+	cc, r := build(t, b, "echo")
+	defer r.Close()
+
+	var once sync.Once
+	cc.mu.Lock()
+	cc.onUpdate = func() {
+		b.Services()
+		once.Do(func() {
+			r.ResolveNow(resolver.ResolveNowOptions{})
+			b.SetAddresses("echo", "localhost:50053")
+		})
+	}
+	cc.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.SetAddresses("echo", "localhost:50052")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("SetAddresses deadlocked")
+	}
+	if s, _ := cc.last(t); !equal(addrsOf(s), []string{"localhost:50053"}) {
+		t.Errorf("addresses = %v, want [localhost:50053]", addrsOf(s))
+	}
+}